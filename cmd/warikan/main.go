@@ -0,0 +1,66 @@
+// warikan はSlackを使わずに割り勘を計算したり、warikan-botのデータベースを確かめたりするコマンド
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"github.com/kakudo415/warikan-bot/internal/config"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/cli"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/logging"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	fs := flag.NewFlagSet("warikan", flag.ContinueOnError)
+	defaultURL := os.Getenv("DATABASE_URL")
+	if defaultURL == "" {
+		defaultURL = config.Default().Database.URL
+	}
+	databaseURL := fs.String("database-url", defaultURL, "PostgreSQL URL, SQLite filename or memory")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return 2
+	}
+
+	// 結果だけを標準出力に出したいので、ユースケースのログは警告以上に絞る
+	logger, err := logging.New(os.Stderr, config.LogFormatText, "warn")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// split などデータベースを使わないコマンドでは、データベースのファイルを作らないように開かずにおく
+	var store repository.Database
+	if cli.NeedsStore(fs.Args()) {
+		store, err = repository.Open(*databaseURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open database: %v\n", err)
+			return 1
+		}
+		defer store.Close()
+	}
+
+	c := cli.NewCLI(store, usecase.NewPayment(store, nil), usecase.NewAPIToken(store), os.Stdout, os.Stderr)
+	if err := c.Run(ctx, fs.Args()); err != nil {
+		if errors.Is(err, cli.ErrorUsage) {
+			return 2
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,312 @@
+// Package config は起動時の設定を、既定値・設定ファイル・環境変数・コマンドライン引数の順に読み込む
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SlackModeHTTP   = "http"
+	SlackModeSocket = "socket"
+
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+type Config struct {
+	Slack    SlackConfig    `yaml:"slack"`
+	Discord  DiscordConfig  `yaml:"discord"`
+	LINE     LINEConfig     `yaml:"line"`
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Bot      BotConfig      `yaml:"bot"`
+	Receipt  ReceiptConfig  `yaml:"receipt"`
+	Worker   WorkerConfig   `yaml:"worker"`
+	Log      LogConfig      `yaml:"log"`
+}
+
+type SlackConfig struct {
+	// 1つのワークスペースだけで動かすときのボットトークン。ClientIDを指定したときは使わない
+	BotToken      string `yaml:"bot_token"`
+	SigningSecret string `yaml:"signing_secret"`
+	AppToken      string `yaml:"app_token"`
+	// http か socket
+	Mode string `yaml:"mode"`
+	// 指定するとOAuthで複数のワークスペースに追加できるようになる
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// 空のときはSlackアプリに設定したリダイレクトURLを使う
+	RedirectURL string `yaml:"redirect_url"`
+}
+
+// Distributed は複数のワークスペースに追加できる設定かどうかを返す
+func (c SlackConfig) Distributed() bool {
+	return c.ClientID != ""
+}
+
+type DiscordConfig struct {
+	// 指定するとDiscordのスラッシュコマンドを受け付ける。Developer Portalに表示される公開鍵
+	PublicKey string `yaml:"public_key"`
+	// 両方指定すると、起動時に /warikan コマンドを登録する
+	ApplicationID string `yaml:"application_id"`
+	BotToken      string `yaml:"bot_token"`
+}
+
+// Enabled はDiscordからのリクエストを受け付ける設定かどうかを返す
+func (c DiscordConfig) Enabled() bool {
+	return c.PublicKey != ""
+}
+
+type LINEConfig struct {
+	// 指定するとLINEのWebhookを受け付ける。LINE Developersコンソールのチャネルの設定に表示される
+	ChannelSecret      string `yaml:"channel_secret"`
+	ChannelAccessToken string `yaml:"channel_access_token"`
+}
+
+// Enabled はLINEからのWebhookを受け付ける設定かどうかを返す
+func (c LINEConfig) Enabled() bool {
+	return c.ChannelSecret != ""
+}
+
+type ServerConfig struct {
+	Addr string `yaml:"addr"`
+	// 終了するときに処理中のリクエストを待つ時間
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	// PostgreSQLのURL、SQLiteのファイル名、または memory
+	URL string `yaml:"url"`
+}
+
+type BotConfig struct {
+	Username  string `yaml:"username"`
+	IconEmoji string `yaml:"icon_emoji"`
+}
+
+type ReceiptConfig struct {
+	TesseractCommand   string `yaml:"tesseract_command"`
+	TesseractLanguages string `yaml:"tesseract_languages"`
+}
+
+type WorkerConfig struct {
+	Count     int `yaml:"count"`
+	QueueSize int `yaml:"queue_size"`
+}
+
+type LogConfig struct {
+	// text か json
+	Format string `yaml:"format"`
+	// debug, info, warn, error のいずれか
+	Level string `yaml:"level"`
+}
+
+func Default() *Config {
+	return &Config{
+		Slack: SlackConfig{
+			Mode: SlackModeHTTP,
+		},
+		Server: ServerConfig{
+			Addr:            "0.0.0.0:5272", // U+5272 = 割
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			URL: "database.db",
+		},
+		Bot: BotConfig{
+			Username:  "割り勘",
+			IconEmoji: ":money_with_wings:",
+		},
+		Receipt: ReceiptConfig{
+			TesseractCommand:   "tesseract",
+			TesseractLanguages: "jpn+eng",
+		},
+		Worker: WorkerConfig{
+			Count:     8,
+			QueueSize: 64,
+		},
+		Log: LogConfig{
+			Format: LogFormatText,
+			Level:  "info",
+		},
+	}
+}
+
+// Load は既定値、設定ファイル、環境変数、コマンドライン引数の順に上書きして設定を読み込み、検証する
+func Load(args []string, getenv func(key string) string) (*Config, error) {
+	fs := flag.NewFlagSet("warikan-bot", flag.ContinueOnError)
+	configPath := fs.String("config", getenv("WARIKAN_CONFIG"), "path to a YAML config file")
+	addr := fs.String("addr", "", "address to listen on")
+	databaseURL := fs.String("database-url", "", "PostgreSQL URL, SQLite filename or memory")
+	slackMode := fs.String("slack-mode", "", "how to receive requests from Slack (http or socket)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	config := Default()
+	if *configPath != "" {
+		if err := config.loadFile(*configPath); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.loadEnv(getenv); err != nil {
+		return nil, err
+	}
+	// 指定されたフラグだけで上書きする
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			config.Server.Addr = *addr
+		case "database-url":
+			config.Database.URL = *databaseURL
+		case "slack-mode":
+			config.Slack.Mode = *slackMode
+		}
+	})
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv(getenv func(key string) string) error {
+	stringValues := map[string]*string{
+		"SLACK_BOT_TOKEN":             &c.Slack.BotToken,
+		"SLACK_SIGNING_SECRET":        &c.Slack.SigningSecret,
+		"SLACK_APP_TOKEN":             &c.Slack.AppToken,
+		"SLACK_MODE":                  &c.Slack.Mode,
+		"SLACK_CLIENT_ID":             &c.Slack.ClientID,
+		"SLACK_CLIENT_SECRET":         &c.Slack.ClientSecret,
+		"SLACK_REDIRECT_URL":          &c.Slack.RedirectURL,
+		"DISCORD_PUBLIC_KEY":          &c.Discord.PublicKey,
+		"DISCORD_APPLICATION_ID":      &c.Discord.ApplicationID,
+		"DISCORD_BOT_TOKEN":           &c.Discord.BotToken,
+		"LINE_CHANNEL_SECRET":         &c.LINE.ChannelSecret,
+		"LINE_CHANNEL_ACCESS_TOKEN":   &c.LINE.ChannelAccessToken,
+		"WARIKAN_ADDR":                &c.Server.Addr,
+		"DATABASE_URL":                &c.Database.URL,
+		"WARIKAN_BOT_USERNAME":        &c.Bot.Username,
+		"WARIKAN_BOT_ICON_EMOJI":      &c.Bot.IconEmoji,
+		"WARIKAN_TESSERACT_COMMAND":   &c.Receipt.TesseractCommand,
+		"WARIKAN_TESSERACT_LANGUAGES": &c.Receipt.TesseractLanguages,
+		"WARIKAN_LOG_FORMAT":          &c.Log.Format,
+		"WARIKAN_LOG_LEVEL":           &c.Log.Level,
+	}
+	for key, value := range stringValues {
+		if v := getenv(key); v != "" {
+			*value = v
+		}
+	}
+
+	intValues := map[string]*int{
+		"WARIKAN_WORKERS":    &c.Worker.Count,
+		"WARIKAN_QUEUE_SIZE": &c.Worker.QueueSize,
+	}
+	for key, value := range intValues {
+		v := getenv(key)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", key, err)
+		}
+		*value = n
+	}
+
+	if v := getenv("WARIKAN_SHUTDOWN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("failed to parse WARIKAN_SHUTDOWN_TIMEOUT: %w", err)
+		}
+		c.Server.ShutdownTimeout = d
+	}
+	return nil
+}
+
+// Validate は起動できない設定をまとめてエラーにする
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Slack.Distributed() {
+		if c.Slack.ClientSecret == "" {
+			errs = append(errs, errors.New("SLACK_CLIENT_SECRET is required with SLACK_CLIENT_ID"))
+		}
+	} else if c.Slack.BotToken == "" {
+		errs = append(errs, errors.New("SLACK_BOT_TOKEN or SLACK_CLIENT_ID is required"))
+	}
+	switch c.Slack.Mode {
+	case SlackModeHTTP:
+		if c.Slack.SigningSecret == "" {
+			errs = append(errs, errors.New("SLACK_SIGNING_SECRET is required"))
+		}
+		if c.Server.Addr == "" {
+			errs = append(errs, errors.New("listen address is required"))
+		}
+	case SlackModeSocket:
+		if c.Slack.AppToken == "" {
+			errs = append(errs, errors.New("SLACK_APP_TOKEN is required in socket mode"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown slack mode: %q", c.Slack.Mode))
+	}
+	if c.Discord.Enabled() {
+		if key, err := hex.DecodeString(c.Discord.PublicKey); err != nil || len(key) != ed25519.PublicKeySize {
+			errs = append(errs, errors.New("DISCORD_PUBLIC_KEY must be a hex-encoded Ed25519 public key"))
+		}
+	}
+	if c.Discord.BotToken != "" && c.Discord.ApplicationID == "" {
+		errs = append(errs, errors.New("DISCORD_APPLICATION_ID is required with DISCORD_BOT_TOKEN"))
+	}
+	if c.LINE.Enabled() && c.LINE.ChannelAccessToken == "" {
+		errs = append(errs, errors.New("LINE_CHANNEL_ACCESS_TOKEN is required with LINE_CHANNEL_SECRET"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown timeout must be positive: %s", c.Server.ShutdownTimeout))
+	}
+	if c.Database.URL == "" {
+		errs = append(errs, errors.New("database URL is required"))
+	}
+	if c.Bot.Username == "" {
+		errs = append(errs, errors.New("bot username is required"))
+	}
+	if c.Worker.Count <= 0 {
+		errs = append(errs, fmt.Errorf("worker count must be positive: %d", c.Worker.Count))
+	}
+	if c.Worker.QueueSize < 0 {
+		errs = append(errs, fmt.Errorf("worker queue size cannot be negative: %d", c.Worker.QueueSize))
+	}
+	if c.Log.Format != LogFormatText && c.Log.Format != LogFormatJSON {
+		errs = append(errs, fmt.Errorf("unknown log format: %q", c.Log.Format))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("unknown log level: %q", c.Log.Level))
+	}
+	return errors.Join(errs...)
+}
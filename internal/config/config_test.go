@@ -0,0 +1,163 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+server:
+  addr: 127.0.0.1:8080
+  shutdown_timeout: 10s
+bot:
+  username: 精算くん
+worker:
+  count: 2
+`), 0o600))
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		// 既定値からの差分
+		expected    func(config *Config)
+		expectedErr bool
+	}{
+		{
+			name: "OK: defaults",
+			env:  map[string]string{"SLACK_BOT_TOKEN": "xoxb-token", "SLACK_SIGNING_SECRET": "secret"},
+			expected: func(config *Config) {
+				config.Slack.BotToken = "xoxb-token"
+				config.Slack.SigningSecret = "secret"
+			},
+		},
+		{
+			name: "OK: flags override environment which overrides the file",
+			args: []string{"-config", configFile, "-addr", ":5272"},
+			env: map[string]string{
+				"SLACK_BOT_TOKEN": "xoxb-token", "SLACK_SIGNING_SECRET": "secret",
+				"WARIKAN_ADDR": ":9999", "WARIKAN_WORKERS": "4",
+			},
+			expected: func(config *Config) {
+				config.Slack.BotToken = "xoxb-token"
+				config.Slack.SigningSecret = "secret"
+				config.Server.Addr = ":5272"
+				config.Server.ShutdownTimeout = 10 * time.Second
+				config.Bot.Username = "精算くん"
+				config.Worker.Count = 4
+			},
+		},
+		{
+			name: "OK: socket mode does not need the signing secret",
+			args: []string{"-slack-mode", "socket"},
+			env:  map[string]string{"SLACK_BOT_TOKEN": "xoxb-token", "SLACK_APP_TOKEN": "xapp-token"},
+			expected: func(config *Config) {
+				config.Slack.BotToken = "xoxb-token"
+				config.Slack.AppToken = "xapp-token"
+				config.Slack.Mode = SlackModeSocket
+			},
+		},
+		{
+			name: "OK: json logs at debug level",
+			env:  map[string]string{"SLACK_BOT_TOKEN": "xoxb-token", "SLACK_SIGNING_SECRET": "secret", "WARIKAN_LOG_FORMAT": "json", "WARIKAN_LOG_LEVEL": "debug"},
+			expected: func(config *Config) {
+				config.Slack.BotToken = "xoxb-token"
+				config.Slack.SigningSecret = "secret"
+				config.Log.Format = LogFormatJSON
+				config.Log.Level = "debug"
+			},
+		},
+		{
+			name: "OK: oauth does not need the bot token",
+			env:  map[string]string{"SLACK_CLIENT_ID": "123.456", "SLACK_CLIENT_SECRET": "client-secret", "SLACK_SIGNING_SECRET": "secret"},
+			expected: func(config *Config) {
+				config.Slack.ClientID = "123.456"
+				config.Slack.ClientSecret = "client-secret"
+				config.Slack.SigningSecret = "secret"
+			},
+		},
+		{
+			name: "OK: discord alongside slack",
+			env: map[string]string{
+				"SLACK_BOT_TOKEN": "xoxb-token", "SLACK_SIGNING_SECRET": "secret",
+				"DISCORD_PUBLIC_KEY": "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+			},
+			expected: func(config *Config) {
+				config.Slack.BotToken = "xoxb-token"
+				config.Slack.SigningSecret = "secret"
+				config.Discord.PublicKey = "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+			},
+		},
+		{
+			name:        "NG: missing tokens",
+			expectedErr: true,
+		},
+		{
+			name:        "NG: socket mode without app token",
+			env:         map[string]string{"SLACK_BOT_TOKEN": "xoxb-token", "SLACK_MODE": "socket"},
+			expectedErr: true,
+		},
+		{
+			name:        "NG: oauth without client secret",
+			env:         map[string]string{"SLACK_CLIENT_ID": "123.456", "SLACK_SIGNING_SECRET": "secret"},
+			expectedErr: true,
+		},
+		{
+			name:        "NG: discord public key is not hex",
+			env:         map[string]string{"SLACK_BOT_TOKEN": "xoxb-token", "SLACK_SIGNING_SECRET": "secret", "DISCORD_PUBLIC_KEY": "not-a-key"},
+			expectedErr: true,
+		},
+		{
+			name:        "NG: line without access token",
+			env:         map[string]string{"SLACK_BOT_TOKEN": "xoxb-token", "SLACK_SIGNING_SECRET": "secret", "LINE_CHANNEL_SECRET": "line-secret"},
+			expectedErr: true,
+		},
+		{
+			name:        "NG: unknown slack mode",
+			env:         map[string]string{"SLACK_BOT_TOKEN": "xoxb-token", "SLACK_SIGNING_SECRET": "secret", "SLACK_MODE": "rtm"},
+			expectedErr: true,
+		},
+		{
+			name:        "NG: unknown log format",
+			env:         map[string]string{"SLACK_BOT_TOKEN": "xoxb-token", "SLACK_SIGNING_SECRET": "secret", "WARIKAN_LOG_FORMAT": "xml"},
+			expectedErr: true,
+		},
+		{
+			name:        "NG: worker count is not a number",
+			env:         map[string]string{"SLACK_BOT_TOKEN": "xoxb-token", "SLACK_SIGNING_SECRET": "secret", "WARIKAN_WORKERS": "many"},
+			expectedErr: true,
+		},
+		{
+			name:        "NG: config file does not exist",
+			args:        []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")},
+			env:         map[string]string{"SLACK_BOT_TOKEN": "xoxb-token", "SLACK_SIGNING_SECRET": "secret"},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			config, err := Load(test.args, func(key string) string { return test.env[key] })
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			expected := Default()
+			test.expected(expected)
+			assert.Equal(t, expected, config)
+		})
+	}
+}
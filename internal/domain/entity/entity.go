@@ -1,11 +1,15 @@
 package entity
 
 import (
+	"time"
+
 	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
 )
 
 type Event struct {
 	ID valueobject.EventID
+	// 最初に割り勘を始めた人が幹事になる
+	OrganizerID valueobject.PayerID
 }
 
 type Payer struct {
@@ -19,4 +23,50 @@ type Payment struct {
 	EventID valueobject.EventID
 	PayerID valueobject.PayerID
 	Amount  valueobject.Yen
+	Memo    string
+	// 空のときは全員で割り勘する
+	Beneficiaries []valueobject.PayerID
+}
+
+func (p *Payment) IsSharedBy(payerID valueobject.PayerID) bool {
+	if len(p.Beneficiaries) == 0 {
+		return true
+	}
+	for _, beneficiary := range p.Beneficiaries {
+		if beneficiary == payerID {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditLog は割り勘への変更履歴で、追記のみ行う
+type AuditLog struct {
+	EventID valueobject.EventID
+	// 操作した人
+	ActorID valueobject.PayerID
+	// 変更された立替えや参加の持ち主
+	PayerID   valueobject.PayerID
+	Action    valueobject.AuditAction
+	Before    string
+	After     string
+	CreatedAt time.Time
+}
+
+// APIToken はAPIから1つの割り勘を操作するためのトークン。漏れても悪用されないように、トークンそのものではなくハッシュを保存する
+type APIToken struct {
+	Hash      string
+	EventID   valueobject.EventID
+	CreatedAt time.Time
+}
+
+// Installation はワークスペースにwarikan-botを追加したときに受け取った情報
+type Installation struct {
+	TeamID    valueobject.TeamID
+	TeamName  string
+	BotUserID string
+	BotToken  string
+	// 追加した人
+	InstallerID valueobject.PayerID
+	InstalledAt time.Time
 }
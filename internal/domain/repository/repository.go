@@ -1,22 +1,91 @@
 package repository
 
 import (
+	"context"
+	"time"
+
 	"github.com/kakudo415/warikan-bot/internal/domain/entity"
 	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
 )
 
 type EventRepository interface {
-	CreateIfNotExists(event *entity.Event) error
+	CreateIfNotExists(ctx context.Context, event *entity.Event) error
+	FindByID(ctx context.Context, eventID valueobject.EventID) (*entity.Event, error)
+	// FindAll はすべての割り勘をIDの順に返す
+	FindAll(ctx context.Context) ([]*entity.Event, error)
+	// AssignTeam はワークスペースを区別する前に作られた割り勘を、teamIDのワークスペースのものに付け替えて、その件数を返す
+	AssignTeam(ctx context.Context, teamID valueobject.TeamID) (int, error)
+	// Move はfromの割り勘の記録を、まだ使われていないtoのIDに付け替える
+	Move(ctx context.Context, from valueobject.EventID, to valueobject.EventID) error
 }
 
 type PayerRepository interface {
-	Create(payer *entity.Payer) error
-	CreateIfNotExists(payer *entity.Payer) error
-	FindByEventID(eventID valueobject.EventID) ([]*entity.Payer, error)
+	Create(ctx context.Context, payer *entity.Payer) error
+	CreateIfNotExists(ctx context.Context, payer *entity.Payer) error
+	Update(ctx context.Context, payer *entity.Payer) error
+	Delete(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID) error
+	FindByID(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID) (*entity.Payer, error)
+	FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.Payer, error)
+	// Rename はfromの利用者の記録を、Slackのすべての割り勘でtoのIDに付け替える
+	Rename(ctx context.Context, from valueobject.PayerID, to valueobject.PayerID) error
 }
 
 type PaymentRepository interface {
-	Create(payment *entity.Payment) error
-	Delete(paymentID valueobject.PaymentID) error
-	FindByEventID(eventID valueobject.EventID) ([]*entity.Payment, error)
+	Create(ctx context.Context, payment *entity.Payment) error
+	Update(ctx context.Context, payment *entity.Payment) error
+	Delete(ctx context.Context, paymentID valueobject.PaymentID) error
+	Restore(ctx context.Context, paymentID valueobject.PaymentID) error
+	FindByID(ctx context.Context, paymentID valueobject.PaymentID) (*entity.Payment, error)
+	// since以降に削除された立替えのうち、最後に削除されたものを返す
+	FindLastDeleted(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID, since time.Time) (*entity.Payment, error)
+	// since以降に登録された立替えのうち、立て替えた人・金額・メモが同じで最後に登録されたものを返す
+	FindLastSimilar(ctx context.Context, payment *entity.Payment, since time.Time) (*entity.Payment, error)
+	FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.Payment, error)
+}
+
+type AuditLogRepository interface {
+	Append(ctx context.Context, log *entity.AuditLog) error
+	FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.AuditLog, error)
+}
+
+// DeliveryRepository はSlackから受け取ったリクエストを記録し、再送されたものを見分けられるようにする
+type DeliveryRepository interface {
+	// 同じキーがすでに記録されているときは ErrorAlreadyExists を返す
+	Create(ctx context.Context, key string, receivedAt time.Time) error
+	// Complete はkeyのリクエストを処理し終えたことを記録する
+	Complete(ctx context.Context, key string) error
+	// IsCompleted はkeyのリクエストを処理し終えていればtrueを返す。記録がなければ ErrorNotFound を返す
+	IsCompleted(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+	// before より前に受け取った記録を削除する
+	DeleteBefore(ctx context.Context, before time.Time) error
+}
+
+// InstallationRepository はワークスペースごとのボットトークンを保存する
+type InstallationRepository interface {
+	// 同じワークスペースに追加し直したときは上書きする
+	Save(ctx context.Context, installation *entity.Installation) error
+	FindByTeamID(ctx context.Context, teamID valueobject.TeamID) (*entity.Installation, error)
+	Delete(ctx context.Context, teamID valueobject.TeamID) error
+}
+
+// APITokenRepository は割り勘ごとに発行したAPIのトークンを保存する
+type APITokenRepository interface {
+	Create(ctx context.Context, token *entity.APIToken) error
+	FindByHash(ctx context.Context, hash string) (*entity.APIToken, error)
+	// DeleteByEventID は割り勘に発行したトークンをすべて無効にする
+	DeleteByEventID(ctx context.Context, eventID valueobject.EventID) error
+}
+
+// Store は各リポジトリをまとめ、複数の操作を1つのトランザクションで実行できるようにする
+type Store interface {
+	Events() EventRepository
+	Payers() PayerRepository
+	Payments() PaymentRepository
+	AuditLogs() AuditLogRepository
+	Deliveries() DeliveryRepository
+	Installations() InstallationRepository
+	APITokens() APITokenRepository
+	// fnがエラーを返したときは、fnの中で行った変更をすべて取り消す
+	Transaction(ctx context.Context, fn func(store Store) error) error
 }
@@ -0,0 +1,12 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+type ReceiptReader interface {
+	ReadTotal(ctx context.Context, image io.Reader) (valueobject.Yen, error)
+}
@@ -0,0 +1,17 @@
+package valueobject
+
+type AuditAction string
+
+const (
+	AuditActionPaymentCreated     AuditAction = "payment_created"
+	AuditActionPaymentUpdated     AuditAction = "payment_updated"
+	AuditActionPaymentDeleted     AuditAction = "payment_deleted"
+	AuditActionPaymentRestored    AuditAction = "payment_restored"
+	AuditActionPayerJoined        AuditAction = "payer_joined"
+	AuditActionPayerLeft          AuditAction = "payer_left"
+	AuditActionPayerWeightChanged AuditAction = "payer_weight_changed"
+)
+
+func (a AuditAction) String() string {
+	return string(a)
+}
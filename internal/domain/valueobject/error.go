@@ -44,3 +44,59 @@ type ErrorInvalid struct {
 	message string
 	err     error
 }
+
+func NewErrorInvalid(message string, err error) *ErrorInvalid {
+	return &ErrorInvalid{message, err}
+}
+
+func (e *ErrorInvalid) Error() string {
+	if e.err != nil {
+		return e.message + " (" + e.err.Error() + ")"
+	}
+	return e.message
+}
+
+func (e *ErrorInvalid) Unwrap() error {
+	return e.err
+}
+
+type ErrorForbidden struct {
+	message string
+	err     error
+}
+
+func NewErrorForbidden(message string, err error) *ErrorForbidden {
+	return &ErrorForbidden{message, err}
+}
+
+func (e *ErrorForbidden) Error() string {
+	if e.err != nil {
+		return e.message + " (" + e.err.Error() + ")"
+	}
+	return e.message
+}
+
+func (e *ErrorForbidden) Unwrap() error {
+	return e.err
+}
+
+// ErrorDuplicatePayment は直前に同じ内容の立替えが登録されていることを表す
+type ErrorDuplicatePayment struct {
+	message string
+	err     error
+}
+
+func NewErrorDuplicatePayment(message string, err error) *ErrorDuplicatePayment {
+	return &ErrorDuplicatePayment{message, err}
+}
+
+func (e *ErrorDuplicatePayment) Error() string {
+	if e.err != nil {
+		return e.message + " (" + e.err.Error() + ")"
+	}
+	return e.message
+}
+
+func (e *ErrorDuplicatePayment) Unwrap() error {
+	return e.err
+}
@@ -1,35 +1,162 @@
 package valueobject
 
-import "github.com/google/uuid"
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
 
 type (
-	EventID   struct{ value string }
-	PayerID   struct{ value string }
+	TeamID    struct{ enterpriseID, teamID string }
+	EventID   struct{ namespace, channelID string }
+	PayerID   struct{ namespace, userID string }
 	PaymentID struct{ value uuid.UUID }
 )
 
+// Slackのワークスペースと重ならない、Discord・LINEの名前空間
+const (
+	discordNamespace = "discord"
+	lineNamespace    = "line"
+)
+
+func NewTeamID(teamID string) TeamID {
+	return TeamID{teamID: teamID}
+}
+
+// NewEnterpriseTeamID はEnterprise Gridの組織に属するワークスペースを表す。teamIDが空のときは組織全体を表す
+func NewEnterpriseTeamID(enterpriseID string, teamID string) TeamID {
+	return TeamID{enterpriseID: enterpriseID, teamID: teamID}
+}
+
+func (t TeamID) EnterpriseID() string {
+	return t.enterpriseID
+}
+
+// String はワークスペースのIDを返す。組織全体を表すときは組織のIDを返す
+func (t TeamID) String() string {
+	if t.teamID == "" {
+		return t.enterpriseID
+	}
+	return t.teamID
+}
+
+func (t TeamID) IsUnknown() bool {
+	return t.enterpriseID == "" && t.teamID == ""
+}
+
+// Organization は組織全体を表すIDを返す。Enterprise Gridでなければワークスペースのままにする
+func (t TeamID) Organization() TeamID {
+	if t.enterpriseID == "" {
+		return t
+	}
+	return TeamID{enterpriseID: t.enterpriseID}
+}
+
+// Namespace はチャンネルIDが重ならない範囲を返す
+// Enterprise Gridでは組織の中の複数のワークスペースで同じチャンネルを共有できるので、組織のIDになる
+func (t TeamID) Namespace() string {
+	if t.enterpriseID != "" {
+		return t.enterpriseID
+	}
+	return t.teamID
+}
+
+// NewEventID は保存しておいたIDから割り勘のIDを戻す
 func NewEventID(value string) EventID {
-	return EventID{value: value}
+	namespace, channelID, ok := strings.Cut(value, ":")
+	if !ok {
+		// ワークスペースを区別する前のIDはチャンネルIDだけ
+		return EventID{channelID: value}
+	}
+	return EventID{namespace: namespace, channelID: channelID}
+}
+
+// NewChannelEventID はチャンネルで行う割り勘のIDを作る
+// チャンネルIDはワークスペースをまたぐと重なりうるので、チャンネルを共有できる範囲で区別する
+func NewChannelEventID(teamID TeamID, channelID string) EventID {
+	return EventID{namespace: teamID.Namespace(), channelID: channelID}
+}
+
+// NewDiscordEventID はDiscordのチャンネルで行う割り勘のIDを作る。Slackのワークスペースと重ならない名前空間にする
+// ダイレクトメッセージのようにサーバーがないときは、guildIDを空にする
+func NewDiscordEventID(guildID string, channelID string) EventID {
+	namespace := discordNamespace
+	if guildID != "" {
+		namespace += "-" + guildID
+	}
+	return EventID{namespace: namespace, channelID: channelID}
+}
+
+// NewLINEEventID はLINEのグループやトークルームで行う割り勘のIDを作る。IDはLINEの中で重ならないので、LINEの名前空間にまとめる
+func NewLINEEventID(sourceID string) EventID {
+	return EventID{namespace: lineNamespace, channelID: sourceID}
+}
+
+func (e EventID) ChannelID() string {
+	return e.channelID
+}
+
+// IsSlack はSlackのチャンネルで行う割り勘かどうかを返す。ワークスペースを区別する前のIDもSlackのもの
+func (e EventID) IsSlack() bool {
+	return e.namespace != discordNamespace && !strings.HasPrefix(e.namespace, discordNamespace+"-") && e.namespace != lineNamespace
+}
+
+// IsLegacy はワークスペースを区別する前に作られたIDかどうかを返す
+func (e EventID) IsLegacy() bool {
+	return e.namespace == ""
 }
 
 func (e EventID) String() string {
-	return e.value
+	if e.namespace == "" {
+		return e.channelID
+	}
+	return e.namespace + ":" + e.channelID
 }
 
 func (e EventID) IsUnknown() bool {
-	return e.value == ""
+	return e.namespace == "" && e.channelID == ""
 }
 
+// NewPayerID は保存しておいたIDから参加者のIDを戻す
 func NewPayerID(value string) PayerID {
-	return PayerID{value: value}
+	namespace, userID, ok := strings.Cut(value, ":")
+	if !ok {
+		// 組織を区別する前のSlackのIDはユーザーIDだけ
+		return PayerID{userID: value}
+	}
+	return PayerID{namespace: namespace, userID: userID}
+}
+
+// NewSlackPayerID はSlackの利用者のIDを、利用者が所属する組織で区別して作る
+// Slackコネクトで共有したチャンネルには他の組織の利用者もいるので、チャンネルの割り勘とは別に所属を持たせる
+func NewSlackPayerID(teamID TeamID, userID string) PayerID {
+	return PayerID{namespace: teamID.Namespace(), userID: userID}
+}
+
+// NewDiscordPayerID はDiscordの利用者のIDを作る。IDはDiscordの中で重ならないので、Discordの名前空間にまとめる
+func NewDiscordPayerID(userID string) PayerID {
+	return PayerID{namespace: discordNamespace, userID: userID}
+}
+
+// NewLINEPayerID はLINEの利用者のIDを作る。IDはLINEの中で重ならないので、LINEの名前空間にまとめる
+func NewLINEPayerID(userID string) PayerID {
+	return PayerID{namespace: lineNamespace, userID: userID}
+}
+
+// UserID はメンションやAPIの呼び出しに使う、プラットフォームのユーザーIDを返す
+func (p PayerID) UserID() string {
+	return p.userID
 }
 
 func (p PayerID) String() string {
-	return p.value
+	if p.namespace == "" {
+		return p.userID
+	}
+	return p.namespace + ":" + p.userID
 }
 
 func (p PayerID) IsUnknown() bool {
-	return p.value == ""
+	return p.namespace == "" && p.userID == ""
 }
 
 func NewPaymentID() PaymentID {
@@ -1,6 +1,9 @@
 package valueobject
 
-import "errors"
+import (
+	"errors"
+	"strconv"
+)
 
 type Percent uint
 
@@ -11,6 +14,18 @@ func NewPercent(value int) (Percent, error) {
 	return Percent(value), nil
 }
 
+func ParsePercent(text string) (Percent, error) {
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		return Percent(0), NewErrorInvalid("failed to parse percent", err)
+	}
+	percent, err := NewPercent(value)
+	if err != nil {
+		return Percent(0), NewErrorInvalid("invalid percent", err)
+	}
+	return percent, nil
+}
+
 func (p Percent) Int() int {
 	return int(p)
 }
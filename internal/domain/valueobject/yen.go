@@ -3,6 +3,8 @@ package valueobject
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
@@ -18,6 +20,19 @@ func NewYen(amount int) (Yen, error) {
 	return Yen(amount), nil
 }
 
+// ParseYen は "3,000" のようにカンマで区切ってもよい金額を読み取る
+func ParseYen(text string) (Yen, error) {
+	amount, err := strconv.Atoi(strings.ReplaceAll(text, ",", ""))
+	if err != nil {
+		return Yen(0), NewErrorInvalid("failed to parse amount", err)
+	}
+	yen, err := NewYen(amount)
+	if err != nil {
+		return Yen(0), NewErrorInvalid("invalid amount", err)
+	}
+	return yen, nil
+}
+
 func (y Yen) Int64() int64 {
 	return int64(y)
 }
@@ -0,0 +1,423 @@
+// Package cli はSlackを使わずに割り勘を計算したり、データベースの中身を確かめたりするためのコマンド
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/repository"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/presenter"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/memory"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+)
+
+// ErrorUsage はコマンドの使い方が正しくないときに返す
+var ErrorUsage = errors.New("invalid usage")
+
+const usage = `使い方:
+  warikan [-database-url URL] <コマンド>
+
+コマンド:
+  split [-format text|markdown] 名前[:割合%][=金額] ...
+      データベースを使わずに割り勘を計算する
+      例: warikan split 太郎=3,000 花子=1000 次郎:50%
+  events list
+      割り勘の一覧を表示する
+  event show <割り勘のID>
+      割り勘の参加者と立替えを表示する
+  settle [-format text|markdown] <割り勘のID>
+      割り勘の清算方法を表示する
+  export <割り勘のID>
+      割り勘の参加者・立替え・変更履歴をJSONで書き出す
+  token issue <割り勘のID>
+      割り勘をAPIから操作するためのトークンを発行する
+  token revoke <割り勘のID>
+      割り勘に発行したトークンをすべて無効にする
+`
+
+// 利用者はIDのまま表示する
+func rawName(payerID valueobject.PayerID) string {
+	return payerID.String()
+}
+
+type CLI struct {
+	store           repository.Store
+	paymentUsecase  *usecase.PaymentUsecase
+	apiTokenUsecase *usecase.APITokenUsecase
+	stdout          io.Writer
+	stderr          io.Writer
+}
+
+func NewCLI(store repository.Store, paymentUsecase *usecase.PaymentUsecase, apiTokenUsecase *usecase.APITokenUsecase, stdout io.Writer, stderr io.Writer) *CLI {
+	return &CLI{
+		store:           store,
+		paymentUsecase:  paymentUsecase,
+		apiTokenUsecase: apiTokenUsecase,
+		stdout:          stdout,
+		stderr:          stderr,
+	}
+}
+
+// NeedsStore はargsのコマンドがデータベースを使うときにtrueを返す
+// データベースを使わないコマンドや使い方が正しくないときは、データベースを開かずに実行できる
+func NeedsStore(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "events", "event", "settle", "export", "token":
+		return true
+	default:
+		return false
+	}
+}
+
+// Usage はコマンドの使い方を表示する
+func (c *CLI) Usage() {
+	fmt.Fprint(c.stderr, usage)
+}
+
+// Run はargsで指定されたコマンドを実行する。使い方が正しくないときは使い方を表示して ErrorUsage を返す
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	err := c.run(ctx, args)
+	if errors.Is(err, ErrorUsage) {
+		c.Usage()
+	}
+	return err
+}
+
+func (c *CLI) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return ErrorUsage
+	}
+	switch args[0] {
+	case "split":
+		return c.split(ctx, args[1:])
+	case "events":
+		if len(args) != 2 || args[1] != "list" {
+			return ErrorUsage
+		}
+		return c.listEvents(ctx)
+	case "event":
+		if len(args) != 3 || args[1] != "show" {
+			return ErrorUsage
+		}
+		return c.showEvent(ctx, valueobject.NewEventID(args[2]))
+	case "settle":
+		return c.settle(ctx, args[1:])
+	case "export":
+		if len(args) != 2 {
+			return ErrorUsage
+		}
+		return c.export(ctx, valueobject.NewEventID(args[1]))
+	case "token":
+		if len(args) != 3 {
+			return ErrorUsage
+		}
+		eventID := valueobject.NewEventID(args[2])
+		switch args[1] {
+		case "issue":
+			return c.issueToken(ctx, eventID)
+		case "revoke":
+			return c.apiTokenUsecase.Revoke(ctx, eventID)
+		default:
+			return ErrorUsage
+		}
+	default:
+		return ErrorUsage
+	}
+}
+
+// parseFormat は -format だけを受け付けるフラグを読み、残りの引数を返す
+func (c *CLI) parseFormat(name string, args []string) (string, []string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", FormatText, "text or markdown")
+	if err := fs.Parse(args); err != nil {
+		return "", nil, ErrorUsage
+	}
+	if *format != FormatText && *format != FormatMarkdown {
+		return "", nil, ErrorUsage
+	}
+	return *format, fs.Args(), nil
+}
+
+func (c *CLI) printSettlement(settlement *usecase.Settlement, format string) error {
+	view := presenter.NewSettlementView(settlement)
+	text := presenter.RenderSettlementText(view, rawName)
+	if format == FormatMarkdown {
+		text = presenter.RenderSettlementMarkdown(view, rawName)
+	}
+	_, err := fmt.Fprint(c.stdout, text)
+	return err
+}
+
+// splitMember は split に渡された1人分の引数
+type splitMember struct {
+	payerID valueobject.PayerID
+	weight  valueobject.Percent
+	amount  valueobject.Yen
+}
+
+// parseSplitMember は "名前[:割合%][=金額]" を読み取る
+func parseSplitMember(arg string) (*splitMember, error) {
+	rawMember, rawAmount, hasAmount := strings.Cut(arg, "=")
+	rawName, rawWeight, hasWeight := strings.Cut(rawMember, ":")
+	if rawName == "" {
+		return nil, valueobject.NewErrorInvalid(fmt.Sprintf("name is empty: %s", arg), nil)
+	}
+	member := &splitMember{payerID: valueobject.NewPayerID(rawName), weight: valueobject.Percent(100)}
+	if hasWeight {
+		weight, err := valueobject.ParsePercent(strings.TrimSuffix(rawWeight, "%"))
+		if err != nil {
+			return nil, err
+		}
+		member.weight = weight
+	}
+	if hasAmount {
+		amount, err := valueobject.ParseYen(strings.TrimSuffix(rawAmount, "円"))
+		if err != nil {
+			return nil, err
+		}
+		member.amount = amount
+	}
+	return member, nil
+}
+
+// split はメモリ上に一時的な割り勘を作り、ボットと同じ計算で清算方法を求める
+func (c *CLI) split(ctx context.Context, args []string) error {
+	format, args, err := c.parseFormat("split", args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return ErrorUsage
+	}
+
+	members := make([]*splitMember, 0, len(args))
+	for _, arg := range args {
+		member, err := parseSplitMember(arg)
+		if err != nil {
+			return fmt.Errorf("failed to parse %q: %w", arg, err)
+		}
+		members = append(members, member)
+	}
+
+	paymentUsecase := usecase.NewPayment(memory.NewStore(), nil)
+	eventID := valueobject.NewEventID("split")
+	for _, member := range members {
+		if _, err := paymentUsecase.Join(ctx, eventID, member.payerID, member.weight); err != nil {
+			return fmt.Errorf("failed to join %s: %w", member.payerID, err)
+		}
+	}
+	for _, member := range members {
+		if member.amount <= 0 {
+			continue
+		}
+		if _, err := paymentUsecase.CreateConfirmed(ctx, eventID, member.payerID, member.payerID, member.amount, "", nil); err != nil {
+			return fmt.Errorf("failed to create payment: %w", err)
+		}
+	}
+
+	settlement, err := paymentUsecase.Settle(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to settle: %w", err)
+	}
+	return c.printSettlement(settlement, format)
+}
+
+func (c *CLI) listEvents(ctx context.Context) error {
+	events, err := c.store.Events().FindAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to find events: %w", err)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t幹事\t参加者\t立替え\t合計")
+	for _, event := range events {
+		payers, err := c.store.Payers().FindByEventID(ctx, event.ID)
+		if err != nil {
+			return fmt.Errorf("failed to find payers: %w", err)
+		}
+		payments, err := c.store.Payments().FindByEventID(ctx, event.ID)
+		if err != nil {
+			return fmt.Errorf("failed to find payments: %w", err)
+		}
+		total := valueobject.Yen(0)
+		for _, payment := range payments {
+			total += payment.Amount
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d人\t%d件\t%s\n", event.ID, event.OrganizerID, len(payers), len(payments), total)
+	}
+	return w.Flush()
+}
+
+func (c *CLI) showEvent(ctx context.Context, eventID valueobject.EventID) error {
+	event, err := c.store.Events().FindByID(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to find event: %w", err)
+	}
+	payers, err := c.store.Payers().FindByEventID(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to find payers: %w", err)
+	}
+	payments, err := c.store.Payments().FindByEventID(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to find payments: %w", err)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\t%s\n", event.ID)
+	fmt.Fprintf(w, "幹事\t%s\n", event.OrganizerID)
+
+	fmt.Fprintf(w, "\n参加者 %d人\n", len(payers))
+	for _, payer := range payers {
+		fmt.Fprintf(w, "  %s\t%d%%\n", payer.ID, payer.Weight.Int())
+	}
+
+	fmt.Fprintf(w, "\n立替え %d件\n", len(payments))
+	for _, payment := range payments {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", payment.ID, payment.PayerID, payment.Amount, describeBeneficiaries(payment))
+	}
+	return w.Flush()
+}
+
+// describeBeneficiaries は立替えのメモと、割り勘する相手が限られているときはその相手を表示する
+func describeBeneficiaries(payment *entity.Payment) string {
+	description := payment.Memo
+	if len(payment.Beneficiaries) == 0 {
+		return description
+	}
+	beneficiaries := make([]string, 0, len(payment.Beneficiaries))
+	for _, beneficiary := range payment.Beneficiaries {
+		beneficiaries = append(beneficiaries, beneficiary.String())
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s（%s）", description, strings.Join(beneficiaries, ", ")))
+}
+
+func (c *CLI) settle(ctx context.Context, args []string) error {
+	format, args, err := c.parseFormat("settle", args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return ErrorUsage
+	}
+	settlement, err := c.paymentUsecase.Settle(ctx, valueobject.NewEventID(args[0]))
+	if err != nil {
+		return fmt.Errorf("failed to settle: %w", err)
+	}
+	return c.printSettlement(settlement, format)
+}
+
+type exportedEvent struct {
+	ID          string             `json:"id"`
+	OrganizerID string             `json:"organizer_id"`
+	Payers      []exportedPayer    `json:"payers"`
+	Payments    []exportedPayment  `json:"payments"`
+	AuditLogs   []exportedAuditLog `json:"audit_logs"`
+}
+
+type exportedPayer struct {
+	ID     string `json:"id"`
+	Weight int    `json:"weight"`
+}
+
+type exportedPayment struct {
+	ID            string   `json:"id"`
+	PayerID       string   `json:"payer_id"`
+	Amount        int64    `json:"amount"`
+	Memo          string   `json:"memo"`
+	Beneficiaries []string `json:"beneficiaries"`
+}
+
+type exportedAuditLog struct {
+	ActorID   string    `json:"actor_id"`
+	PayerID   string    `json:"payer_id"`
+	Action    string    `json:"action"`
+	Before    string    `json:"before"`
+	After     string    `json:"after"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// export は割り勘の中身をJSONで書き出す。削除された立替えは含めない
+func (c *CLI) export(ctx context.Context, eventID valueobject.EventID) error {
+	event, err := c.store.Events().FindByID(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to find event: %w", err)
+	}
+	payers, err := c.store.Payers().FindByEventID(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to find payers: %w", err)
+	}
+	payments, err := c.store.Payments().FindByEventID(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to find payments: %w", err)
+	}
+	logs, err := c.store.AuditLogs().FindByEventID(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to find audit logs: %w", err)
+	}
+
+	exported := exportedEvent{
+		ID:          event.ID.String(),
+		OrganizerID: event.OrganizerID.String(),
+		Payers:      make([]exportedPayer, 0, len(payers)),
+		Payments:    make([]exportedPayment, 0, len(payments)),
+		AuditLogs:   make([]exportedAuditLog, 0, len(logs)),
+	}
+	for _, payer := range payers {
+		exported.Payers = append(exported.Payers, exportedPayer{ID: payer.ID.String(), Weight: payer.Weight.Int()})
+	}
+	for _, payment := range payments {
+		beneficiaries := make([]string, 0, len(payment.Beneficiaries))
+		for _, beneficiary := range payment.Beneficiaries {
+			beneficiaries = append(beneficiaries, beneficiary.String())
+		}
+		exported.Payments = append(exported.Payments, exportedPayment{
+			ID:            payment.ID.String(),
+			PayerID:       payment.PayerID.String(),
+			Amount:        payment.Amount.Int64(),
+			Memo:          payment.Memo,
+			Beneficiaries: beneficiaries,
+		})
+	}
+	for _, log := range logs {
+		exported.AuditLogs = append(exported.AuditLogs, exportedAuditLog{
+			ActorID:   log.ActorID.String(),
+			PayerID:   log.PayerID.String(),
+			Action:    log.Action.String(),
+			Before:    log.Before,
+			After:     log.After,
+			CreatedAt: log.CreatedAt,
+		})
+	}
+
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(exported)
+}
+
+// issueToken は発行したトークンだけを出力して、スクリプトから受け取りやすくする
+func (c *CLI) issueToken(ctx context.Context, eventID valueobject.EventID) error {
+	token, err := c.apiTokenUsecase.Issue(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(c.stdout, token)
+	return err
+}
@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/memory"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+func TestCLI_Run(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		args           []string
+		expectedStdout string
+		// 使い方が正しくないときは使い方を表示する
+		expectedUsage   bool
+		expectedInvalid bool
+	}{
+		{
+			name: "OK: split",
+			args: []string{"split", "太郎=3,000", "花子=1000円", "次郎"},
+			expectedStdout: "集計結果\n\n" +
+				"合計4,000円が立て替えられています\n  太郎 3,000円\n  花子 1,000円\n\n" +
+				"3人で割り勘します\n  太郎 100%\n  花子 100%\n  次郎 100%\n\n" +
+				"次のように清算してください\n  次郎 → 1,334円 → 太郎\n  花子 → 332円 → 太郎\n",
+		},
+		{
+			name: "OK: split in markdown",
+			args: []string{"split", "-format", "markdown", "太郎", "花子"},
+			expectedStdout: "## 集計結果\n\n" +
+				"### 合計0円が立て替えられています\n\nまだ立替えはありません\n\n" +
+				"### 2人で割り勘します\n\n- 太郎 100%\n- 花子 100%\n\n" +
+				"### 次のように清算してください\n\n清算は必要ありません\n",
+		},
+		{
+			name: "OK: settle",
+			args: []string{"settle", "T0001:C0001"},
+			expectedStdout: "集計結果\n\n" +
+				"合計3,000円が立て替えられています\n  U0001 3,000円\n\n" +
+				"2人で割り勘します\n  U0001 100%\n  U0002 100%\n\n" +
+				"次のように清算してください\n  U0002 → 1,500円 → U0001\n",
+		},
+		{
+			name:          "NG: token for an unknown subcommand",
+			args:          []string{"token", "show", "T0001:C0001"},
+			expectedUsage: true,
+		},
+		{
+			name:          "NG: unknown command",
+			args:          []string{"pay"},
+			expectedUsage: true,
+		},
+		{
+			name:          "NG: unknown format",
+			args:          []string{"settle", "-format", "html", "T0001:C0001"},
+			expectedUsage: true,
+		},
+		{
+			name:            "NG: invalid amount",
+			args:            []string{"split", "太郎=三千"},
+			expectedInvalid: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, stdout, stderr := newTestCLI(t)
+			err := c.Run(t.Context(), test.args)
+			if test.expectedUsage {
+				assert.ErrorIs(t, err, ErrorUsage)
+				assert.Contains(t, stderr.String(), "使い方")
+				return
+			}
+			if test.expectedInvalid {
+				e := new(valueobject.ErrorInvalid)
+				assert.ErrorAs(t, err, &e)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedStdout, stdout.String())
+		})
+	}
+}
+
+func TestCLI_Export(t *testing.T) {
+	t.Parallel()
+
+	c, stdout, _ := newTestCLI(t)
+	require.NoError(t, c.Run(t.Context(), []string{"export", "T0001:C0001"}))
+
+	var exported exportedEvent
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &exported))
+	assert.Equal(t, "T0001:C0001", exported.ID)
+	assert.Equal(t, "U0001", exported.OrganizerID)
+	assert.Len(t, exported.Payers, 2)
+	require.Len(t, exported.Payments, 1)
+	assert.Equal(t, int64(3000), exported.Payments[0].Amount)
+	assert.Equal(t, "居酒屋", exported.Payments[0].Memo)
+	assert.Len(t, exported.AuditLogs, 3)
+}
+
+func TestNeedsStore(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected bool
+	}{
+		{name: "OK: split", args: []string{"split", "太郎=3000", "花子"}, expected: false},
+		{name: "OK: no command", args: nil, expected: false},
+		{name: "OK: unknown command", args: []string{"unknown"}, expected: false},
+		{name: "OK: events list", args: []string{"events", "list"}, expected: true},
+		{name: "OK: settle", args: []string{"settle", "T0001:C0001"}, expected: true},
+		{name: "OK: token issue", args: []string{"token", "issue", "T0001:C0001"}, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, NeedsStore(test.args))
+		})
+	}
+}
+
+func TestCLI_Run_WithoutStore(t *testing.T) {
+	t.Parallel()
+
+	// データベースを開かなくても割り勘を計算できる
+	var stdout, stderr bytes.Buffer
+	c := NewCLI(nil, usecase.NewPayment(nil, nil), usecase.NewAPIToken(nil), &stdout, &stderr)
+	require.NoError(t, c.Run(t.Context(), []string{"split", "太郎=3000", "花子"}))
+	assert.Contains(t, stdout.String(), "花子 → 1,500円 → 太郎")
+}
+
+// newTestCLI は2人が参加し、1人が立て替えた割り勘を用意する
+func newTestCLI(t *testing.T) (*CLI, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	ctx := t.Context()
+	store := memory.NewStore()
+	paymentUsecase := usecase.NewPayment(store, nil)
+	eventID := valueobject.NewChannelEventID(valueobject.NewTeamID("T0001"), "C0001")
+	taro := valueobject.NewPayerID("U0001")
+	hanako := valueobject.NewPayerID("U0002")
+	_, err := paymentUsecase.Join(ctx, eventID, taro, valueobject.Percent(100))
+	require.NoError(t, err)
+	_, err = paymentUsecase.Join(ctx, eventID, hanako, valueobject.Percent(100))
+	require.NoError(t, err)
+	_, err = paymentUsecase.Create(ctx, eventID, taro, taro, valueobject.Yen(3000), "居酒屋", nil)
+	require.NoError(t, err)
+
+	var stdout, stderr bytes.Buffer
+	return NewCLI(store, paymentUsecase, usecase.NewAPIToken(store), &stdout, &stderr), &stdout, &stderr
+}
@@ -0,0 +1,363 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+const maxAPIRequestBodySize = 1 << 20
+
+// apiRoute はAPIの1つの操作。同じ定義からルーティングとOpenAPIの仕様書を作る
+type apiRoute struct {
+	method  string
+	path    string
+	summary string
+	// リクエストとレスポンスのボディの型を表すゼロ値。ボディがないときはnil
+	request  any
+	response any
+	status   int
+	handle   func(h *APIHandler, ctx context.Context, eventID valueobject.EventID, r *http.Request) (any, error)
+}
+
+var apiRoutes = []apiRoute{
+	{
+		method:   http.MethodGet,
+		path:     "/api/events/{id}/payments",
+		summary:  "立替えの一覧を登録順に返す",
+		response: apiPaymentList{},
+		status:   http.StatusOK,
+		handle:   (*APIHandler).listPayments,
+	},
+	{
+		method:   http.MethodPost,
+		path:     "/api/events/{id}/payments",
+		summary:  "立替えを登録する",
+		request:  apiCreatePaymentRequest{},
+		response: apiPayment{},
+		status:   http.StatusCreated,
+		handle:   (*APIHandler).createPayment,
+	},
+	{
+		method:   http.MethodGet,
+		path:     "/api/events/{id}/payers",
+		summary:  "割り勘の参加者を返す",
+		response: apiPayerList{},
+		status:   http.StatusOK,
+		handle:   (*APIHandler).listPayers,
+	},
+	{
+		method:   http.MethodPost,
+		path:     "/api/events/{id}/payers",
+		summary:  "割り勘に参加する",
+		request:  apiJoinRequest{},
+		response: apiPayer{},
+		status:   http.StatusCreated,
+		handle:   (*APIHandler).join,
+	},
+	{
+		method:   http.MethodPost,
+		path:     "/api/events/{id}/settle",
+		summary:  "清算方法を計算する",
+		response: apiSettlement{},
+		status:   http.StatusOK,
+		handle:   (*APIHandler).settle,
+	},
+}
+
+type apiPayment struct {
+	ID            string   `json:"id"`
+	PayerID       string   `json:"payer_id" doc:"立て替えた人"`
+	Amount        int64    `json:"amount" doc:"金額（円）"`
+	Memo          string   `json:"memo"`
+	Beneficiaries []string `json:"beneficiaries" doc:"割り勘する相手。空のときは参加者全員"`
+}
+
+type apiPaymentList struct {
+	Payments []apiPayment `json:"payments"`
+}
+
+type apiCreatePaymentRequest struct {
+	PayerID        string   `json:"payer_id" doc:"立て替えた人"`
+	Amount         int64    `json:"amount" doc:"金額（円）"`
+	Memo           string   `json:"memo,omitempty"`
+	Beneficiaries  []string `json:"beneficiaries,omitempty" doc:"割り勘する相手。参加者から選ぶ。省略すると参加者全員"`
+	AllowDuplicate bool     `json:"allow_duplicate,omitempty" doc:"直前に同じ人が同じ金額とメモで登録していても、二重登録とみなさずに登録する"`
+}
+
+type apiPayer struct {
+	ID     string `json:"id"`
+	Weight int    `json:"weight" doc:"負担割合（%）"`
+}
+
+type apiPayerList struct {
+	Payers []apiPayer `json:"payers"`
+}
+
+type apiJoinRequest struct {
+	PayerID string `json:"payer_id"`
+	Weight  *int   `json:"weight,omitempty" doc:"負担割合（%）。省略すると100"`
+}
+
+type apiAmountAdvanced struct {
+	PayerID string `json:"payer_id"`
+	Amount  int64  `json:"amount" doc:"立て替えた金額の合計（円）"`
+}
+
+type apiInstruction struct {
+	From   string `json:"from" doc:"支払う人"`
+	To     string `json:"to" doc:"受け取る人"`
+	Amount int64  `json:"amount" doc:"金額（円）"`
+}
+
+type apiSettlement struct {
+	Total           int64               `json:"total" doc:"立替えの合計（円）"`
+	AmountsAdvanced []apiAmountAdvanced `json:"amounts_advanced" doc:"立て替えた人ごとの合計。利用者IDの順"`
+	Payers          []apiPayer          `json:"payers"`
+	Instructions    []apiInstruction    `json:"instructions"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func newAPIPayment(payment *entity.Payment) apiPayment {
+	beneficiaries := make([]string, 0, len(payment.Beneficiaries))
+	for _, beneficiary := range payment.Beneficiaries {
+		beneficiaries = append(beneficiaries, beneficiary.String())
+	}
+	return apiPayment{
+		ID:            payment.ID.String(),
+		PayerID:       payment.PayerID.String(),
+		Amount:        payment.Amount.Int64(),
+		Memo:          payment.Memo,
+		Beneficiaries: beneficiaries,
+	}
+}
+
+func newAPIPayer(payer *entity.Payer) apiPayer {
+	return apiPayer{ID: payer.ID.String(), Weight: payer.Weight.Int()}
+}
+
+// APIHandler はダッシュボードなどから割り勘を操作するためのJSONのAPI
+// リクエストには、操作する割り勘に発行したトークンを Authorization: Bearer で付ける
+type APIHandler struct {
+	paymentUsecase  *usecase.PaymentUsecase
+	apiTokenUsecase *usecase.APITokenUsecase
+	mux             *http.ServeMux
+}
+
+func NewAPIHandler(paymentUsecase *usecase.PaymentUsecase, apiTokenUsecase *usecase.APITokenUsecase) *APIHandler {
+	h := &APIHandler{
+		paymentUsecase:  paymentUsecase,
+		apiTokenUsecase: apiTokenUsecase,
+		mux:             http.NewServeMux(),
+	}
+	for _, route := range apiRoutes {
+		h.mux.Handle(route.method+" "+route.path, h.wrap(route))
+	}
+	// 仕様書はルートの定義から決まるので、起動時に1度だけ作る
+	spec, _ := json.MarshalIndent(openAPISpec(), "", "  ")
+	h.mux.HandleFunc("GET /api/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	})
+	return h
+}
+
+func (h *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// wrap は認証とエラーの変換をまとめて行う
+func (h *APIHandler) wrap(route apiRoute) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		eventID := valueobject.NewEventID(r.PathValue("id"))
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			writeAPIError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+		if err := h.apiTokenUsecase.Authorize(ctx, token, eventID); err != nil {
+			if e := new(valueobject.ErrorForbidden); errors.As(err, &e) {
+				writeAPIError(w, http.StatusForbidden, "token is not issued for this event")
+				return
+			}
+			if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
+				writeAPIError(w, http.StatusUnauthorized, "invalid token")
+				return
+			}
+			slog.ErrorContext(ctx, "failed to authorize api request", slog.Any("error", err))
+			writeAPIError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+
+		if route.request != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxAPIRequestBodySize)
+		}
+		response, err := route.handle(h, ctx, eventID, r)
+		if err != nil {
+			status, message := apiErrorStatus(err)
+			if status == http.StatusInternalServerError {
+				slog.ErrorContext(ctx, "failed to handle api request", slog.String("event_id", eventID.String()), slog.Any("error", err))
+			}
+			writeAPIError(w, status, message)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(route.status)
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+// apiErrorStatus はユースケースのエラーをHTTPのステータスと、利用者に見せてよいメッセージにする
+func apiErrorStatus(err error) (int, string) {
+	if e := new(valueobject.ErrorDuplicatePayment); errors.As(err, &e) {
+		return http.StatusConflict, "similar payment was created just before; set allow_duplicate to create it anyway"
+	}
+	if e := new(valueobject.ErrorAlreadyExists); errors.As(err, &e) {
+		return http.StatusConflict, "already exists"
+	}
+	if e := new(valueobject.ErrorInvalid); errors.As(err, &e) {
+		return http.StatusBadRequest, err.Error()
+	}
+	if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
+		return http.StatusNotFound, "not found"
+	}
+	if e := new(valueobject.ErrorForbidden); errors.As(err, &e) {
+		return http.StatusForbidden, "forbidden"
+	}
+	return http.StatusInternalServerError, "internal error"
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiError{Error: message})
+}
+
+// decodeAPIRequest は知らないフィールドを含むボディを、書き間違いとして受け付けない
+func decodeAPIRequest(r *http.Request, v any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return valueobject.NewErrorInvalid("invalid request body", err)
+	}
+	return nil
+}
+
+func (h *APIHandler) listPayments(ctx context.Context, eventID valueobject.EventID, r *http.Request) (any, error) {
+	payments, err := h.paymentUsecase.Payments(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	response := apiPaymentList{Payments: make([]apiPayment, 0, len(payments))}
+	for _, payment := range payments {
+		response.Payments = append(response.Payments, newAPIPayment(payment))
+	}
+	return response, nil
+}
+
+func (h *APIHandler) createPayment(ctx context.Context, eventID valueobject.EventID, r *http.Request) (any, error) {
+	var request apiCreatePaymentRequest
+	if err := decodeAPIRequest(r, &request); err != nil {
+		return nil, err
+	}
+	if request.PayerID == "" {
+		return nil, valueobject.NewErrorInvalid("payer_id is required", nil)
+	}
+	if request.Amount <= 0 {
+		return nil, valueobject.NewErrorInvalid("amount must be positive", nil)
+	}
+	var beneficiaries []valueobject.PayerID
+	for _, beneficiary := range request.Beneficiaries {
+		beneficiaries = append(beneficiaries, valueobject.NewPayerID(beneficiary))
+	}
+
+	// APIでは立て替えた人の代わりに登録するので、操作した人も立て替えた人にする
+	payerID := valueobject.NewPayerID(request.PayerID)
+	create := h.paymentUsecase.Create
+	if request.AllowDuplicate {
+		create = h.paymentUsecase.CreateConfirmed
+	}
+	payment, err := create(ctx, eventID, payerID, payerID, valueobject.Yen(request.Amount), request.Memo, beneficiaries)
+	if err != nil {
+		return nil, err
+	}
+	return newAPIPayment(payment), nil
+}
+
+func (h *APIHandler) listPayers(ctx context.Context, eventID valueobject.EventID, r *http.Request) (any, error) {
+	payers, err := h.paymentUsecase.Payers(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	response := apiPayerList{Payers: make([]apiPayer, 0, len(payers))}
+	for _, payer := range payers {
+		response.Payers = append(response.Payers, newAPIPayer(payer))
+	}
+	return response, nil
+}
+
+func (h *APIHandler) join(ctx context.Context, eventID valueobject.EventID, r *http.Request) (any, error) {
+	var request apiJoinRequest
+	if err := decodeAPIRequest(r, &request); err != nil {
+		return nil, err
+	}
+	if request.PayerID == "" {
+		return nil, valueobject.NewErrorInvalid("payer_id is required", nil)
+	}
+	weight := valueobject.Percent(100)
+	if request.Weight != nil {
+		var err error
+		weight, err = valueobject.NewPercent(*request.Weight)
+		if err != nil {
+			return nil, valueobject.NewErrorInvalid("invalid weight", err)
+		}
+	}
+	payer, err := h.paymentUsecase.Join(ctx, eventID, valueobject.NewPayerID(request.PayerID), weight)
+	if err != nil {
+		return nil, err
+	}
+	return newAPIPayer(payer), nil
+}
+
+func (h *APIHandler) settle(ctx context.Context, eventID valueobject.EventID, r *http.Request) (any, error) {
+	settlement, err := h.paymentUsecase.Settle(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	response := apiSettlement{
+		Total:           settlement.Total.Int64(),
+		AmountsAdvanced: make([]apiAmountAdvanced, 0, len(settlement.AmountsAdvanced)),
+		Payers:          make([]apiPayer, 0, len(settlement.Payers)),
+		Instructions:    make([]apiInstruction, 0, len(settlement.Instructions)),
+	}
+	for payerID, amount := range settlement.AmountsAdvanced {
+		response.AmountsAdvanced = append(response.AmountsAdvanced, apiAmountAdvanced{PayerID: payerID.String(), Amount: amount.Int64()})
+	}
+	// マップの順番は毎回変わるので、利用者IDの順に並べる
+	sort.Slice(response.AmountsAdvanced, func(i, j int) bool {
+		return response.AmountsAdvanced[i].PayerID < response.AmountsAdvanced[j].PayerID
+	})
+	for _, payer := range settlement.Payers {
+		response.Payers = append(response.Payers, newAPIPayer(payer))
+	}
+	for _, instruction := range settlement.Instructions {
+		response.Instructions = append(response.Instructions, apiInstruction{
+			From:   instruction.From.String(),
+			To:     instruction.To.String(),
+			Amount: instruction.Amount.Int64(),
+		})
+	}
+	return response, nil
+}
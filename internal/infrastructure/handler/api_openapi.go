@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// openAPISpec はapiRoutesとリクエスト・レスポンスの型から、OpenAPI 3.0の仕様書を作る
+// 構造体はjsonタグでフィールド名を、docタグで説明を決め、omitemptyのないフィールドを必須にする
+func openAPISpec() map[string]any {
+	schemas := map[string]any{}
+	errorResponse := map[string]any{
+		"description": "エラー",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": openAPISchema(reflect.TypeOf(apiError{}), schemas)},
+		},
+	}
+
+	paths := map[string]any{}
+	for _, route := range apiRoutes {
+		operation := map[string]any{
+			"summary":  route.summary,
+			"security": []any{map[string]any{"bearerAuth": []any{}}},
+			"parameters": []any{
+				map[string]any{
+					"name":        "id",
+					"in":          "path",
+					"required":    true,
+					"description": "割り勘のID",
+					"schema":      map[string]any{"type": "string"},
+				},
+			},
+			"responses": map[string]any{
+				strconv.Itoa(route.status): map[string]any{
+					"description": http.StatusText(route.status),
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": openAPISchema(reflect.TypeOf(route.response), schemas)},
+					},
+				},
+				"default": errorResponse,
+			},
+		}
+		if route.request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": openAPISchema(reflect.TypeOf(route.request), schemas)},
+				},
+			}
+		}
+		item, ok := paths[route.path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[route.path] = item
+		}
+		item[strings.ToLower(route.method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "warikan-bot API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "割り勘ごとに warikan token issue で発行したトークン",
+				},
+			},
+		},
+	}
+}
+
+// openAPISchema は型をスキーマにする。構造体はcomponentsに登録して参照を返す
+func openAPISchema(t reflect.Type, schemas map[string]any) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return openAPISchema(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": openAPISchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		// apiPayment なら Payment として登録する
+		name := strings.TrimPrefix(t.Name(), "api")
+		ref := map[string]any{"$ref": "#/components/schemas/" + name}
+		if _, ok := schemas[name]; ok {
+			return ref
+		}
+		properties := map[string]any{}
+		required := []string{}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			fieldName, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			property := openAPISchema(field.Type, schemas)
+			if doc := field.Tag.Get("doc"); doc != "" {
+				property["description"] = doc
+			}
+			properties[fieldName] = property
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, fieldName)
+			}
+		}
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		schemas[name] = schema
+		return ref
+	default:
+		panic("unsupported type for openapi schema: " + t.String())
+	}
+}
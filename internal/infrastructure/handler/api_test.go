@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/memory"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+// APIを変えたときは go test ./internal/infrastructure/handler -run TestOpenAPISpec -update で仕様書を作り直す
+var update = flag.Bool("update", false, "update golden files")
+
+// newAPITestServer は2人が参加した割り勘と、そのトークン、別の割り勘のトークンを用意する
+func newAPITestServer(t *testing.T) (*httptest.Server, string, string) {
+	t.Helper()
+	ctx := t.Context()
+	store := memory.NewStore()
+	paymentUsecase := usecase.NewPayment(store, nil)
+	apiTokenUsecase := usecase.NewAPIToken(store)
+
+	eventID := valueobject.NewChannelEventID(valueobject.NewTeamID("T0001"), "C0001")
+	otherEventID := valueobject.NewChannelEventID(valueobject.NewTeamID("T0001"), "C0002")
+	for _, payerID := range []string{"U0001", "U0002"} {
+		_, err := paymentUsecase.Join(ctx, eventID, valueobject.NewPayerID(payerID), valueobject.Percent(100))
+		require.NoError(t, err)
+	}
+	_, err := paymentUsecase.Join(ctx, otherEventID, valueobject.NewPayerID("U0009"), valueobject.Percent(100))
+	require.NoError(t, err)
+	token, err := apiTokenUsecase.Issue(ctx, eventID)
+	require.NoError(t, err)
+	otherToken, err := apiTokenUsecase.Issue(ctx, otherEventID)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(NewAPIHandler(paymentUsecase, apiTokenUsecase))
+	t.Cleanup(server.Close)
+	return server, token, otherToken
+}
+
+func doAPIRequest(t *testing.T, server *httptest.Server, method string, path string, token string, body string) (int, string) {
+	t.Helper()
+	r, err := http.NewRequestWithContext(t.Context(), method, server.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	response, err := server.Client().Do(r)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	var raw json.RawMessage
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&raw))
+	return response.StatusCode, string(raw)
+}
+
+func TestAPIHandler_Authorize(t *testing.T) {
+	t.Parallel()
+
+	server, token, otherToken := newAPITestServer(t)
+	tests := []struct {
+		name           string
+		token          string
+		expectedStatus int
+	}{
+		{name: "OK: token for the event", token: token, expectedStatus: http.StatusOK},
+		{name: "NG: missing token", token: "", expectedStatus: http.StatusUnauthorized},
+		{name: "NG: unknown token", token: "unknown", expectedStatus: http.StatusUnauthorized},
+		{name: "NG: token for another event", token: otherToken, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			status, _ := doAPIRequest(t, server, http.MethodGet, "/api/events/T0001:C0001/payers", test.token, "")
+			assert.Equal(t, test.expectedStatus, status)
+		})
+	}
+}
+
+func TestAPIHandler_Settle(t *testing.T) {
+	t.Parallel()
+
+	server, token, _ := newAPITestServer(t)
+	const path = "/api/events/T0001:C0001"
+
+	status, body := doAPIRequest(t, server, http.MethodPost, path+"/payers", token, `{"payer_id":"U0003","weight":50}`)
+	require.Equal(t, http.StatusCreated, status)
+	assert.JSONEq(t, `{"id":"U0003","weight":50}`, body)
+	status, _ = doAPIRequest(t, server, http.MethodPost, path+"/payers", token, `{"payer_id":"U0003"}`)
+	assert.Equal(t, http.StatusConflict, status)
+
+	status, _ = doAPIRequest(t, server, http.MethodPost, path+"/payments", token, `{"payer_id":"U0001","amount":4999,"memo":"居酒屋"}`)
+	require.Equal(t, http.StatusCreated, status)
+	// 二重登録は確認してから登録する
+	status, _ = doAPIRequest(t, server, http.MethodPost, path+"/payments", token, `{"payer_id":"U0001","amount":4999,"memo":"居酒屋"}`)
+	assert.Equal(t, http.StatusConflict, status)
+	status, _ = doAPIRequest(t, server, http.MethodPost, path+"/payments", token, `{"payer_id":"U0001","amount":-1}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = doAPIRequest(t, server, http.MethodPost, path+"/payments", token, `{"payer_id":"U0001","amount":100,"currency":"USD"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, body = doAPIRequest(t, server, http.MethodGet, path+"/payments", token, "")
+	require.Equal(t, http.StatusOK, status)
+	var payments apiPaymentList
+	require.NoError(t, json.Unmarshal([]byte(body), &payments))
+	require.Len(t, payments.Payments, 1)
+	assert.Equal(t, apiPayment{ID: payments.Payments[0].ID, PayerID: "U0001", Amount: 4999, Memo: "居酒屋", Beneficiaries: []string{}}, payments.Payments[0])
+
+	status, body = doAPIRequest(t, server, http.MethodPost, path+"/settle", token, "")
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{
+		"total": 4999,
+		"amounts_advanced": [{"payer_id": "U0001", "amount": 4999}],
+		"payers": [{"id": "U0001", "weight": 100}, {"id": "U0002", "weight": 100}, {"id": "U0003", "weight": 50}],
+		"instructions": [{"from": "U0002", "to": "U0001", "amount": 2000}, {"from": "U0003", "to": "U0001", "amount": 1000}]
+	}`, body)
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	t.Parallel()
+
+	server, _, _ := newAPITestServer(t)
+	status, body := doAPIRequest(t, server, http.MethodGet, "/api/openapi.json", "", "")
+	require.Equal(t, http.StatusOK, status)
+
+	var spec struct {
+		Paths map[string]map[string]any `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &spec))
+	for _, route := range apiRoutes {
+		assert.Contains(t, spec.Paths[route.path], strings.ToLower(route.method))
+	}
+
+	actual, err := json.MarshalIndent(openAPISpec(), "", "  ")
+	require.NoError(t, err)
+	path := filepath.Join("testdata", "openapi.json")
+	if *update {
+		require.NoError(t, os.WriteFile(path, append(actual, '\n'), 0o644))
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual)+"\n")
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+// errChannelUnavailable はボットがチャンネルに参加していないなどで、チャンネルを調べられなかったことを表す
+var errChannelUnavailable = errors.New("channel is unavailable")
+
+// チャンネルを共有しているかどうかを覚えておく時間。共有が始まったり終わったりしたら、この時間が過ぎてから反映される
+const channelEventTTL = time.Hour
+
+type channelEvent struct {
+	eventID   valueobject.EventID
+	expiresAt time.Time
+}
+
+// SlackChannels はSlackコネクトで他の組織と共有したチャンネルを見分けて、どの組織から使っても同じ割り勘になるようにする
+// 共有されたチャンネルのリクエストは、利用者が所属するワークスペースのIDで届くので、チャンネルを作ったワークスペースでまとめる
+type SlackChannels struct {
+	paymentUsecase *usecase.PaymentUsecase
+	now            func() time.Time
+
+	mu     sync.Mutex
+	events map[string]channelEvent
+}
+
+func NewSlackChannels(paymentUsecase *usecase.PaymentUsecase) *SlackChannels {
+	return &SlackChannels{
+		paymentUsecase: paymentUsecase,
+		now:            time.Now,
+		events:         make(map[string]channelEvent),
+	}
+}
+
+// EventID はteamIDのワークスペースから届いたリクエストについて、チャンネルの割り勘のIDを返す
+// チャンネルを調べられないときは、別の割り勘として記録しないようにエラーを返す
+func (c *SlackChannels) EventID(ctx context.Context, client *slack.Client, teamID valueobject.TeamID, channelID string) (valueobject.EventID, error) {
+	key := valueobject.NewChannelEventID(teamID, channelID).String()
+	c.mu.Lock()
+	cached, ok := c.events[key]
+	c.mu.Unlock()
+	if ok && !c.now().After(cached.expiresAt) {
+		return cached.eventID, nil
+	}
+
+	eventID, err := c.lookup(ctx, client, teamID, channelID)
+	if err != nil {
+		return valueobject.EventID{}, err
+	}
+	c.mu.Lock()
+	c.events[key] = channelEvent{eventID: eventID, expiresAt: c.now().Add(channelEventTTL)}
+	c.mu.Unlock()
+	return eventID, nil
+}
+
+func (c *SlackChannels) lookup(ctx context.Context, client *slack.Client, teamID valueobject.TeamID, channelID string) (valueobject.EventID, error) {
+	channel, err := client.GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{ChannelID: channelID})
+	if err != nil {
+		return valueobject.EventID{}, fmt.Errorf("%w: failed to get conversation info: %w", errChannelUnavailable, err)
+	}
+
+	// 共有されていなければ、リクエストが届いたワークスペースのチャンネルとして扱う
+	localEventID := valueobject.NewChannelEventID(teamID, channelID)
+	eventID := localEventID
+	previousEventIDs := []valueobject.EventID{localEventID}
+	if channel.ConversationHostID != "" {
+		hostEventID := valueobject.NewChannelEventID(valueobject.NewTeamID(channel.ConversationHostID), channelID)
+		if channel.IsExtShared {
+			eventID = hostEventID
+		}
+		previousEventIDs = append(previousEventIDs, hostEventID)
+	}
+
+	// 共有が始まったり終わったりしてIDが変わったときは、それまでの記録を引き継ぐ
+	for _, previousEventID := range previousEventIDs {
+		if previousEventID == eventID {
+			continue
+		}
+		moved, err := c.paymentUsecase.MoveEvent(ctx, previousEventID, eventID)
+		if err != nil {
+			return valueobject.EventID{}, err
+		}
+		if moved {
+			break
+		}
+	}
+	return eventID, nil
+}
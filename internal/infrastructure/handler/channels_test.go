@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/memory"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+func TestSlackChannels_EventID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		teamID valueobject.TeamID
+		// conversations.info の応答
+		response string
+		// すでに記録されている割り勘
+		existingEventID valueobject.EventID
+		expectedEventID valueobject.EventID
+		expectedErr     bool
+	}{
+		{
+			name:            "OK: channel in the workspace",
+			teamID:          valueobject.NewTeamID("T0001"),
+			response:        `{"ok":true,"channel":{"id":"C0001","is_ext_shared":false,"conversation_host_id":"T0001"}}`,
+			expectedEventID: valueobject.NewEventID("T0001:C0001"),
+		},
+		{
+			name:            "OK: channel in the Enterprise Grid organization",
+			teamID:          valueobject.NewEnterpriseTeamID("E0001", "T0002"),
+			response:        `{"ok":true,"channel":{"id":"C0001","is_ext_shared":false,"conversation_host_id":"T0001"}}`,
+			expectedEventID: valueobject.NewEventID("E0001:C0001"),
+		},
+		{
+			name:            "OK: channel shared with Slack Connect",
+			teamID:          valueobject.NewTeamID("T0002"),
+			response:        `{"ok":true,"channel":{"id":"C0001","is_ext_shared":true,"conversation_host_id":"T0001"}}`,
+			expectedEventID: valueobject.NewEventID("T0001:C0001"),
+		},
+		{
+			name:            "OK: moves the event when the channel starts to be shared",
+			teamID:          valueobject.NewEnterpriseTeamID("E0001", "T0001"),
+			response:        `{"ok":true,"channel":{"id":"C0001","is_ext_shared":true,"conversation_host_id":"T0001"}}`,
+			existingEventID: valueobject.NewEventID("E0001:C0001"),
+			expectedEventID: valueobject.NewEventID("T0001:C0001"),
+		},
+		{
+			name:            "OK: moves the event when the channel stops being shared",
+			teamID:          valueobject.NewEnterpriseTeamID("E0001", "T0001"),
+			response:        `{"ok":true,"channel":{"id":"C0001","is_ext_shared":false,"conversation_host_id":"T0001"}}`,
+			existingEventID: valueobject.NewEventID("T0001:C0001"),
+			expectedEventID: valueobject.NewEventID("E0001:C0001"),
+		},
+		{
+			name:        "NG: channel cannot be looked up",
+			teamID:      valueobject.NewTeamID("T0002"),
+			response:    `{"ok":false,"error":"missing_scope"}`,
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(test.response))
+			}))
+			defer server.Close()
+			client := slack.New("xoxb-1", slack.OptionAPIURL(server.URL+"/"))
+
+			paymentUsecase := usecase.NewPayment(memory.NewStore(), nil)
+			if !test.existingEventID.IsUnknown() {
+				_, err := paymentUsecase.Join(t.Context(), test.existingEventID, valueobject.NewPayerID("U0001"), valueobject.Percent(100))
+				require.NoError(t, err)
+			}
+			channels := NewSlackChannels(paymentUsecase)
+			now := time.Date(2025, 5, 1, 19, 0, 0, 0, time.UTC)
+			channels.now = func() time.Time { return now }
+
+			eventID, err := channels.EventID(t.Context(), client, test.teamID, "C0001")
+			if test.expectedErr {
+				assert.ErrorIs(t, err, errChannelUnavailable)
+				// 調べられなかった結果は覚えずに、次のリクエストで調べ直す
+				_, err = channels.EventID(t.Context(), client, test.teamID, "C0001")
+				assert.ErrorIs(t, err, errChannelUnavailable)
+				assert.Equal(t, int32(2), calls.Load())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedEventID, eventID)
+			// 2回目は覚えておいた結果を使う
+			eventID, err = channels.EventID(t.Context(), client, test.teamID, "C0001")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedEventID, eventID)
+			assert.Equal(t, int32(1), calls.Load())
+
+			if !test.existingEventID.IsUnknown() {
+				payers, err := paymentUsecase.Payers(t.Context(), test.expectedEventID)
+				require.NoError(t, err)
+				assert.Len(t, payers, 1)
+			}
+
+			// 期限が過ぎたら調べ直す
+			now = now.Add(channelEventTTL + time.Second)
+			eventID, err = channels.EventID(t.Context(), client, test.teamID, "C0001")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedEventID, eventID)
+			assert.Equal(t, int32(2), calls.Load())
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/slack-go/slack"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+// SlackClients はリクエストが届いたワークスペースのボットトークンで、Slack APIを呼び出すクライアントを返す
+type SlackClients interface {
+	Client(ctx context.Context, teamID valueobject.TeamID) (*slack.Client, error)
+}
+
+// StaticSlackClients は1つのワークスペースだけで動かすときに、設定したトークンのクライアントを常に返す
+type StaticSlackClients struct {
+	client *slack.Client
+}
+
+func NewStaticSlackClients(client *slack.Client) *StaticSlackClients {
+	return &StaticSlackClients{
+		client: client,
+	}
+}
+
+func (c *StaticSlackClients) Client(ctx context.Context, teamID valueobject.TeamID) (*slack.Client, error) {
+	return c.client, nil
+}
+
+// InstalledSlackClients はOAuthで追加されたワークスペースごとに保存したトークンを使う
+// トークンは削除や再追加で変わるので、リクエストのたびに読み直す
+type InstalledSlackClients struct {
+	installationUsecase *usecase.InstallationUsecase
+	options             []slack.Option
+}
+
+func NewInstalledSlackClients(installationUsecase *usecase.InstallationUsecase, options ...slack.Option) *InstalledSlackClients {
+	return &InstalledSlackClients{
+		installationUsecase: installationUsecase,
+		options:             options,
+	}
+}
+
+func (c *InstalledSlackClients) Client(ctx context.Context, teamID valueobject.TeamID) (*slack.Client, error) {
+	installation, err := c.installationUsecase.Find(ctx, teamID)
+	if e := new(valueobject.ErrorNotFound); errors.As(err, &e) && teamID.EnterpriseID() != "" {
+		// Enterprise Gridの組織全体に追加されているときは、組織のトークンを使う
+		installation, err = c.installationUsecase.Find(ctx, teamID.Organization())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return slack.New(installation.BotToken, c.options...), nil
+}
@@ -1,155 +1,281 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/slack-go/slack"
 
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
 	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/logging"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/metrics"
 	"github.com/kakudo415/warikan-bot/internal/usecase"
 )
 
 const SlackMetadataEventType = "warikan"
 
+// commandTimeout はSlackに応答した後、スラッシュコマンドの処理にかけてよい時間
+const commandTimeout = 30 * time.Second
+
+// /warikan のサブコマンド。メトリクスのラベルにも使う
+const (
+	subcommandModal   = "modal"
+	subcommandJoin    = "join"
+	subcommandPay     = "pay"
+	subcommandSettle  = "settle"
+	subcommandUndo    = "undo"
+	subcommandHistory = "history"
+	subcommandHelp    = "help"
+	subcommandInvalid = "invalid"
+)
+
 type SlackCommandHandler struct {
-	signingSecret  string
-	client         *slack.Client
-	paymentUsecase *usecase.PaymentUsecase
-	amountPattern  *regexp.Regexp
-	joinPattern    *regexp.Regexp
-	percentPattern *regexp.Regexp
-	settlePattern  *regexp.Regexp
-	helpPattern    *regexp.Regexp
+	clients         SlackClients
+	channels        *SlackChannels
+	users           *SlackUsers
+	paymentUsecase  *usecase.PaymentUsecase
+	deliveryUsecase *usecase.DeliveryUsecase
+	workers         *WorkerPool
+	metrics         *metrics.Metrics
+	amountPattern   *regexp.Regexp
+	joinPattern     *regexp.Regexp
+	percentPattern  *regexp.Regexp
+	settlePattern   *regexp.Regexp
+	historyPattern  *regexp.Regexp
+	undoPattern     *regexp.Regexp
+	helpPattern     *regexp.Regexp
+	botProfile      BotProfile
 }
 
-func NewSlackCommandHandler(token string, signingSecret string, paymentUsecase *usecase.PaymentUsecase) *SlackCommandHandler {
+func NewSlackCommandHandler(clients SlackClients, channels *SlackChannels, users *SlackUsers, paymentUsecase *usecase.PaymentUsecase, deliveryUsecase *usecase.DeliveryUsecase, workers *WorkerPool, metrics *metrics.Metrics, botProfile BotProfile) *SlackCommandHandler {
+	// RE2の \b は日本語を単語の文字として扱わないので、日本語の履歴や元に戻すは空白か端で区切る
 	return &SlackCommandHandler{
-		client:         slack.New(token),
-		signingSecret:  signingSecret,
-		paymentUsecase: paymentUsecase,
-		amountPattern:  regexp.MustCompile(`\b((?:\d{1,3}(?:,\d{3})+|\d+))円?\b`),
-		joinPattern:    regexp.MustCompile(`\b(?:(?i:join)|参加|払う|払います)\b`),
-		percentPattern: regexp.MustCompile(`\b(\d+)(?:%)?\b`),
-		settlePattern:  regexp.MustCompile(`\b(?:(?i:settle)|集計|集金|合計)\b`),
-		helpPattern:    regexp.MustCompile(`\b(?:(?i:help)|(?i:h)|ヘルプ|使い方)\b`),
+		clients:         clients,
+		channels:        channels,
+		users:           users,
+		paymentUsecase:  paymentUsecase,
+		deliveryUsecase: deliveryUsecase,
+		workers:         workers,
+		metrics:         metrics,
+		amountPattern:   regexp.MustCompile(`\b((?:\d{1,3}(?:,\d{3})+|\d+))円?\b`),
+		joinPattern:     regexp.MustCompile(`\b(?:(?i:join)|参加|払う|払います)\b`),
+		percentPattern:  regexp.MustCompile(`\b(\d+)(?:%)?\b`),
+		settlePattern:   regexp.MustCompile(`\b(?:(?i:settle)|集計|集金|合計)\b`),
+		historyPattern:  regexp.MustCompile(`\b(?i:history)\b|(?:^|\s)履歴(?:\s|$)`),
+		undoPattern:     regexp.MustCompile(`\b(?i:undo)\b|(?:^|\s)元に戻す(?:\s|$)`),
+		helpPattern:     regexp.MustCompile(`\b(?:(?i:help)|(?i:h)|ヘルプ|使い方)\b`),
+		botProfile:      botProfile,
 	}
 }
 
 func (h *SlackCommandHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	verifier, err := slack.NewSecretsVerifier(r.Header, h.signingSecret)
-	if err != nil {
-		http.Error(w, "Failed to create secrets verifier", http.StatusBadRequest)
-		return
-	}
-	r.Body = io.NopCloser(io.TeeReader(r.Body, &verifier))
 	slash, err := slack.SlashCommandParse(r)
 	if err != nil {
 		http.Error(w, "Failed to parse slash command", http.StatusBadRequest)
 		return
 	}
-	if verifier.Ensure() != nil {
-		http.Error(w, "Invalid request signature", http.StatusUnauthorized)
+
+	if !h.submit(r.Context(), slash) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buildBusyResponse())
 		return
 	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// submit はコマンドの処理を予約する。Slackは3秒以内に応答しないとエラーにするので、結果はresponse_urlで返す
+func (h *SlackCommandHandler) submit(ctx context.Context, slash slack.SlashCommand) bool {
+	ctx = logging.With(ctx,
+		slog.String("enterprise", slash.EnterpriseID),
+		slog.String("team", slash.TeamID),
+		slog.String("channel", slash.ChannelID),
+		slog.String("user", slash.UserID),
+		slog.String("command", slash.Command),
+		slog.String("subcommand", h.subcommand(slash.Text)),
+	)
+	if !h.workers.Submit(func() { h.processSlashCommand(ctx, slash) }) {
+		slog.WarnContext(ctx, "rejected slash command because the queue is full")
+		return false
+	}
+	return true
+}
 
-	err = h.handleSlashCommand(slash)
-	if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
-		http.Error(w, e.Error(), http.StatusNotFound)
+func (h *SlackCommandHandler) processSlashCommand(ctx context.Context, slash slack.SlashCommand) {
+	// 応答済みのリクエストのコンテキストはすでにキャンセルされているので、ログの属性だけを引き継ぐ
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commandTimeout)
+	defer cancel()
+
+	client, err := h.clients.Client(ctx, valueobject.NewEnterpriseTeamID(slash.EnterpriseID, slash.TeamID))
+	if err != nil {
+		// トークンがなければ結果も返せない
+		h.metrics.CommandHandled(h.subcommand(slash.Text), err)
+		slog.ErrorContext(ctx, "failed to get slack client", slog.Any("error", err))
 		return
 	}
-	if e := new(valueobject.ErrorAlreadyExists); errors.As(err, &e) {
-		http.Error(w, e.Error(), http.StatusConflict)
+
+	start := time.Now()
+	// Socket Mode では再接続したときに同じコマンドが届くことがある
+	err = processOnce(ctx, h.deliveryUsecase, deliveryKey("command", slash.TriggerID), func() error {
+		return h.handleSlashCommand(ctx, client, slash)
+	})
+	if errors.Is(err, errDeliveryInProgress) {
+		// 結果は先に受け取ったほうで返す
 		return
 	}
-	if err != nil {
-		http.Error(w, "Failed to handle slash command", http.StatusInternalServerError)
+	h.metrics.CommandHandled(h.subcommand(slash.Text), err)
+	if err == nil {
+		slog.InfoContext(ctx, "handled slash command", slog.Duration("duration", time.Since(start)))
+		return
+	}
+	slog.ErrorContext(ctx, "failed to handle slash command", slog.Any("error", err))
+	if err := h.respond(ctx, client, slash, buildErrorMessage(err)); err != nil {
+		slog.ErrorContext(ctx, "failed to respond to slash command", slog.Any("error", err))
 	}
 }
 
-func (h *SlackCommandHandler) handleSlashCommand(slash slack.SlashCommand) error {
+// respond はコマンドを実行した本人だけに見えるメッセージをresponse_urlで返す
+func (h *SlackCommandHandler) respond(ctx context.Context, client *slack.Client, slash slack.SlashCommand, message slack.MsgOption) error {
+	_, _, err := client.PostMessageContext(ctx, slash.ChannelID, message, slack.MsgOptionResponseURL(slash.ResponseURL, slack.ResponseTypeEphemeral))
+	return err
+}
+
+func (h *SlackCommandHandler) handleSlashCommand(ctx context.Context, client *slack.Client, slash slack.SlashCommand) error {
 	switch slash.Command {
 	case "/warikan":
-		return h.handleWarikanCommand(slash)
+		return h.handleWarikanCommand(ctx, client, slash)
 	default:
 		return fmt.Errorf("unsupported command: %s", slash.Command)
 	}
 }
 
-func (h *SlackCommandHandler) handleWarikanCommand(slash slack.SlashCommand) error {
-	eventID := valueobject.NewEventID(slash.ChannelID)
-	payerID := valueobject.NewPayerID(slash.UserID)
+func (h *SlackCommandHandler) handleWarikanCommand(ctx context.Context, client *slack.Client, slash slack.SlashCommand) error {
+	eventID, err := h.channels.EventID(ctx, client, valueobject.NewEnterpriseTeamID(slash.EnterpriseID, slash.TeamID), slash.ChannelID)
+	if err != nil {
+		return err
+	}
+	payerID, err := h.users.PayerID(ctx, client, slash.UserID)
+	if err != nil {
+		return err
+	}
+
+	switch h.subcommand(slash.Text) {
+	case subcommandModal:
+		_, err := client.OpenViewContext(ctx, slash.TriggerID, buildPaymentModal(slash.ChannelID, slash.UserID, valueobject.Yen(0)))
+		return err
 
-	if h.joinPattern.MatchString(slash.Text) {
+	case subcommandJoin:
 		weight := valueobject.Percent(100)
 		percentMatch := h.percentPattern.FindStringSubmatch(slash.Text)
 		if percentMatch != nil {
-			w, err := parsePercent(percentMatch[1])
+			w, err := valueobject.ParsePercent(percentMatch[1])
 			if err != nil {
 				return err
 			}
 			weight = w
 		}
-		_, err := h.paymentUsecase.Join(eventID, payerID, weight)
+		payer, err := h.paymentUsecase.Join(ctx, eventID, payerID, weight)
 		if e := new(valueobject.ErrorAlreadyExists); errors.As(err, &e) {
-			_, _, err = h.client.PostMessage(slash.ChannelID, buildPayerAlreadyJoinedMessage(slash.UserID), botProfiles())
+			if percentMatch == nil {
+				return h.respond(ctx, client, slash, buildPayerAlreadyJoinedMessage(slash.UserID))
+			}
+			// 参加済みで重みが指定された場合は重みを変更する
+			payer, err := h.paymentUsecase.ChangeWeight(ctx, eventID, payerID, weight)
+			if err != nil {
+				return err
+			}
+			_, _, err = client.PostMessageContext(ctx, slash.ChannelID, buildPayerWeightChangedMessage(payer), h.botProfile.msgOption())
 			return err
 		}
 		if err != nil {
 			return err
 		}
 
-		_, _, err = h.client.PostMessage(slash.ChannelID, buildPayerJoinedMessage(slash.UserID), botProfiles())
+		_, _, err = client.PostMessageContext(ctx, slash.ChannelID, buildPayerJoinedMessage(slash.UserID), payerMetadata(payer), h.botProfile.msgOption())
 		return err
-	}
 
-	match := h.amountPattern.FindStringSubmatch(slash.Text)
-	if match != nil {
-		amount, err := parseYen(match[1])
+	case subcommandPay:
+		match := h.amountPattern.FindStringSubmatch(slash.Text)
+		amount, err := valueobject.ParseYen(match[1])
 		if err != nil {
 			return err
 		}
 
-		payment, err := h.paymentUsecase.Create(eventID, payerID, amount)
+		payment, err := h.paymentUsecase.Create(ctx, eventID, payerID, payerID, amount, "", nil)
+		if e := new(valueobject.ErrorDuplicatePayment); errors.As(err, &e) {
+			message, err := buildDuplicatePaymentMessage(&entity.Payment{PayerID: payerID, Amount: amount})
+			if err != nil {
+				return err
+			}
+			return h.respond(ctx, client, slash, message)
+		}
 		if err != nil {
 			return err
 		}
 
-		_, _, err = h.client.PostMessage(slash.ChannelID, buildPaymentCreatedMessage(slash.UserID, amount),
-			slack.MsgOptionMetadata(slack.SlackMetadata{
-				EventType: SlackMetadataEventType,
-				EventPayload: map[string]any{
-					"payment_id": payment.ID.String(),
-				},
-			}),
-			botProfiles(),
-		)
-
+		_, _, err = client.PostMessageContext(ctx, slash.ChannelID, buildPaymentCreatedMessage(payment), paymentMetadata(payment), h.botProfile.msgOption())
 		return err
-	}
 
-	if h.settlePattern.MatchString(slash.Text) {
-		settlement, err := h.paymentUsecase.Settle(eventID)
+	case subcommandSettle:
+		settlement, err := h.paymentUsecase.Settle(ctx, eventID)
 		if err != nil {
-			log.Println(err)
 			return err
 		}
-		_, _, err = h.client.PostMessage(slash.ChannelID, buildSettlementMessage(settlement), botProfiles())
+		_, _, err = client.PostMessageContext(ctx, slash.ChannelID, buildSettlementMessage(settlement), h.botProfile.msgOption())
+		return err
+
+	case subcommandUndo:
+		payment, err := h.paymentUsecase.Undo(ctx, eventID, payerID)
+		if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
+			return h.respond(ctx, client, slash, buildNothingToUndoMessage(slash.UserID))
+		}
 		if err != nil {
-			log.Println(err)
+			return err
 		}
+		_, _, err = client.PostMessageContext(ctx, slash.ChannelID, buildPaymentCreatedMessage(payment), paymentMetadata(payment), h.botProfile.msgOption())
 		return err
-	}
 
-	if h.helpPattern.MatchString(slash.Text) {
-		_, _, err := h.client.PostMessage(slash.ChannelID, buildHelpMessage(), botProfiles())
+	case subcommandHistory:
+		logs, err := h.paymentUsecase.History(ctx, eventID)
+		if err != nil {
+			return err
+		}
+		return h.respond(ctx, client, slash, buildHistoryMessage(logs))
+
+	case subcommandHelp:
+		_, _, err := client.PostMessageContext(ctx, slash.ChannelID, buildHelpMessage(), h.botProfile.msgOption())
 		return err
+
+	default:
+		return h.respond(ctx, client, slash, buildInvalidCommandMessage(slash.UserID))
 	}
+}
 
-	_, _, err := h.client.PostMessage(slash.ChannelID, buildInvalidCommandMessage(slash.UserID), botProfiles())
-	return err
+// subcommand はコマンドの本文がどの操作かを判定する。先に判定したものが優先される
+func (h *SlackCommandHandler) subcommand(text string) string {
+	switch {
+	case strings.TrimSpace(text) == "":
+		return subcommandModal
+	case h.joinPattern.MatchString(text):
+		return subcommandJoin
+	case h.amountPattern.MatchString(text):
+		return subcommandPay
+	case h.settlePattern.MatchString(text):
+		return subcommandSettle
+	case h.undoPattern.MatchString(text):
+		return subcommandUndo
+	case h.historyPattern.MatchString(text):
+		return subcommandHistory
+	case h.helpPattern.MatchString(text):
+		return subcommandHelp
+	default:
+		return subcommandInvalid
+	}
 }
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlackCommandHandler_Subcommand(t *testing.T) {
+	t.Parallel()
+
+	h := NewSlackCommandHandler(nil, nil, nil, nil, nil, nil, nil, BotProfile{})
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{text: "", expected: subcommandModal},
+		{text: "join", expected: subcommandJoin},
+		{text: "join 150%", expected: subcommandJoin},
+		{text: "3000", expected: subcommandPay},
+		{text: "3,000円 ランチ", expected: subcommandPay},
+		{text: "settle", expected: subcommandSettle},
+		{text: "history", expected: subcommandHistory},
+		{text: "History", expected: subcommandHistory},
+		{text: "履歴", expected: subcommandHistory},
+		{text: " 履歴 ", expected: subcommandHistory},
+		{text: "undo", expected: subcommandUndo},
+		{text: "元に戻す", expected: subcommandUndo},
+		{text: "help", expected: subcommandHelp},
+		{text: "履歴書", expected: subcommandInvalid},
+		{text: "hello", expected: subcommandInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, h.subcommand(test.text))
+		})
+	}
+}
@@ -1,61 +1,280 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
 	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/presenter"
 	"github.com/kakudo415/warikan-bot/internal/usecase"
 	"github.com/slack-go/slack"
 )
 
-func parseYen(text string) (valueobject.Yen, error) {
-	rawYen := strings.ReplaceAll(text, ",", "")
-	amount, err := strconv.Atoi(rawYen)
-	if err != nil {
-		return valueobject.Yen(0), fmt.Errorf("failed to parse amount: %w", err)
+// BotProfile はボットがメッセージを投稿するときの名前とアイコン
+type BotProfile struct {
+	Username  string
+	IconEmoji string
+}
+
+func (p BotProfile) msgOption() slack.MsgOption {
+	return slack.MsgOptionCompose(
+		slack.MsgOptionIconEmoji(p.IconEmoji),
+		slack.MsgOptionUsername(p.Username),
+	)
+}
+
+func paymentMetadata(payment *entity.Payment) slack.MsgOption {
+	return slack.MsgOptionMetadata(slack.SlackMetadata{
+		EventType: SlackMetadataEventType,
+		EventPayload: map[string]any{
+			"payment_id": payment.ID.String(),
+		},
+	})
+}
+
+func payerMetadata(payer *entity.Payer) slack.MsgOption {
+	return slack.MsgOptionMetadata(slack.SlackMetadata{
+		EventType: SlackMetadataEventType,
+		EventPayload: map[string]any{
+			"payer_id": payer.ID.String(),
+		},
+	})
+}
+
+func buildPaymentCreatedMessage(payment *entity.Payment) slack.MsgOption {
+	text := fmt.Sprintf(":receipt: <@%s>さんが%s立て替えました！", payment.PayerID.UserID(), payment.Amount.String())
+	if payment.Memo != "" {
+		text += fmt.Sprintf("\n%s", payment.Memo)
+	}
+	blocks := []slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", text, false, false),
+			nil,
+			slack.NewAccessory(
+				slack.NewOverflowBlockElement(SlackActionPaymentMenu,
+					slack.NewOptionBlockObject(buildPaymentMenuValue(SlackPaymentMenuEdit, payment.ID),
+						slack.NewTextBlockObject("plain_text", "編集", false, false),
+						nil,
+					),
+				),
+			),
+		),
+	}
+	if len(payment.Beneficiaries) > 0 {
+		mentions := make([]string, 0, len(payment.Beneficiaries))
+		for _, beneficiary := range payment.Beneficiaries {
+			mentions = append(mentions, presenter.Mention(beneficiary))
+		}
+		blocks = append(blocks,
+			slack.NewContextBlock("",
+				slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("対象: %s", strings.Join(mentions, " ")), false, false),
+			),
+		)
+	}
+	return slack.MsgOptionBlocks(blocks...)
+}
+
+func buildPaymentModal(channelID string, userID string, amount valueobject.Yen) slack.ModalViewRequest {
+	amountElement := slack.NewPlainTextInputBlockElement(
+		slack.NewTextBlockObject("plain_text", "3,000", false, false),
+		SlackActionPaymentAmount,
+	)
+	if amount > 0 {
+		amountElement = amountElement.WithInitialValue(strconv.FormatInt(amount.Int64(), 10))
+	}
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      SlackCallbackPaymentModal,
+		PrivateMetadata: channelID,
+		Title:           slack.NewTextBlockObject("plain_text", "立替えを登録", false, false),
+		Submit:          slack.NewTextBlockObject("plain_text", "登録する", false, false),
+		Close:           slack.NewTextBlockObject("plain_text", "キャンセル", false, false),
+		Blocks: slack.Blocks{
+			BlockSet: []slack.Block{
+				slack.NewInputBlock(
+					SlackBlockPaymentAmount,
+					slack.NewTextBlockObject("plain_text", "金額（円）", false, false),
+					nil,
+					amountElement,
+				),
+				slack.NewInputBlock(
+					SlackBlockPaymentMemo,
+					slack.NewTextBlockObject("plain_text", "内容", false, false),
+					nil,
+					slack.NewPlainTextInputBlockElement(
+						slack.NewTextBlockObject("plain_text", "1次会の居酒屋代", false, false),
+						SlackActionPaymentMemo,
+					),
+				).WithOptional(true),
+				slack.NewInputBlock(
+					SlackBlockPaymentPaidBy,
+					slack.NewTextBlockObject("plain_text", "立て替えた人", false, false),
+					nil,
+					slack.NewOptionsSelectBlockElement(slack.OptTypeUser, nil, SlackActionPaymentPaidBy).WithInitialUser(userID),
+				),
+				slack.NewInputBlock(
+					SlackBlockPaymentBeneficiaries,
+					slack.NewTextBlockObject("plain_text", "割り勘の対象者", false, false),
+					slack.NewTextBlockObject("plain_text", "指定しない場合は参加者全員で割り勘します", false, false),
+					slack.NewOptionsMultiSelectBlockElement(slack.MultiOptTypeUser, nil, SlackActionPaymentBeneficiaries),
+				).WithOptional(true),
+			},
+		},
+	}
+}
+
+func buildPaymentEditModal(payment *entity.Payment, metadata string) slack.ModalViewRequest {
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      SlackCallbackPaymentEditModal,
+		PrivateMetadata: metadata,
+		Title:           slack.NewTextBlockObject("plain_text", "立替えを編集", false, false),
+		Submit:          slack.NewTextBlockObject("plain_text", "保存する", false, false),
+		Close:           slack.NewTextBlockObject("plain_text", "キャンセル", false, false),
+		Blocks: slack.Blocks{
+			BlockSet: []slack.Block{
+				slack.NewInputBlock(
+					SlackBlockPaymentAmount,
+					slack.NewTextBlockObject("plain_text", "金額（円）", false, false),
+					nil,
+					slack.NewPlainTextInputBlockElement(nil, SlackActionPaymentAmount).
+						WithInitialValue(strconv.FormatInt(payment.Amount.Int64(), 10)),
+				),
+				slack.NewInputBlock(
+					SlackBlockPaymentMemo,
+					slack.NewTextBlockObject("plain_text", "内容", false, false),
+					nil,
+					slack.NewPlainTextInputBlockElement(nil, SlackActionPaymentMemo).
+						WithInitialValue(payment.Memo),
+				).WithOptional(true),
+			},
+		},
+	}
+}
+
+func buildReceiptScannedMessage(amount valueobject.Yen) slack.MsgOption {
+	return slack.MsgOptionBlocks(
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf(":camera_with_flash: レシートから合計%sを読み取りました！\n`/warikan %d` で立替えを登録しますか？", amount.String(), amount.Int64()), false, false),
+			nil,
+			nil,
+		),
+		slack.NewActionBlock(
+			"",
+			slack.NewButtonBlockElement(SlackActionReceiptConfirm, strconv.FormatInt(amount.Int64(), 10),
+				slack.NewTextBlockObject("plain_text", "登録する", false, false),
+			).WithStyle(slack.StylePrimary),
+			slack.NewButtonBlockElement(SlackActionReceiptEdit, strconv.FormatInt(amount.Int64(), 10),
+				slack.NewTextBlockObject("plain_text", "修正する", false, false),
+			),
+		),
+	)
+}
+
+// duplicatePaymentValue は二重登録かどうか確かめている間、まだ登録していない立替えをボタンに持たせる
+type duplicatePaymentValue struct {
+	PayerID       string   `json:"payer_id"`
+	Amount        int64    `json:"amount"`
+	Memo          string   `json:"memo,omitempty"`
+	Beneficiaries []string `json:"beneficiaries,omitempty"`
+}
+
+func buildDuplicatePaymentValue(payment *entity.Payment) (string, error) {
+	value := duplicatePaymentValue{
+		PayerID: payment.PayerID.String(),
+		Amount:  payment.Amount.Int64(),
+		Memo:    payment.Memo,
+	}
+	for _, beneficiary := range payment.Beneficiaries {
+		value.Beneficiaries = append(value.Beneficiaries, beneficiary.String())
 	}
-	yen, err := valueobject.NewYen(amount)
+	raw, err := json.Marshal(value)
 	if err != nil {
-		return valueobject.Yen(0), err
+		return "", err
 	}
-	return yen, nil
+	return string(raw), nil
 }
 
-func parsePercent(text string) (valueobject.Percent, error) {
-	percent, err := strconv.Atoi(text)
+func parseDuplicatePaymentValue(raw string) (*entity.Payment, error) {
+	var value duplicatePaymentValue
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return nil, valueobject.NewErrorInvalid("failed to parse pending payment", err)
+	}
+	amount, err := valueobject.NewYen(int(value.Amount))
 	if err != nil {
-		return valueobject.Percent(0), fmt.Errorf("failed to parse percent: %w", err)
+		return nil, valueobject.NewErrorInvalid("invalid amount", err)
+	}
+	payment := &entity.Payment{
+		PayerID: valueobject.NewPayerID(value.PayerID),
+		Amount:  amount,
+		Memo:    value.Memo,
 	}
-	percentValue, err := valueobject.NewPercent(percent)
+	for _, beneficiary := range value.Beneficiaries {
+		payment.Beneficiaries = append(payment.Beneficiaries, valueobject.NewPayerID(beneficiary))
+	}
+	return payment, nil
+}
+
+// buildDuplicatePaymentMessage は直前に同じ立替えが登録されていたときに、本当に登録するか確かめる
+func buildDuplicatePaymentMessage(pending *entity.Payment) (slack.MsgOption, error) {
+	value, err := buildDuplicatePaymentValue(pending)
 	if err != nil {
-		return valueobject.Percent(0), err
+		return nil, err
+	}
+	text := fmt.Sprintf(":thinking_face: <@%s>さんの%sの立替えは、直前にも登録されています！\n二重に送信されたのでなければ、もう一度登録してください", pending.PayerID.UserID(), pending.Amount.String())
+	if pending.Memo != "" {
+		text += fmt.Sprintf("\n%s", pending.Memo)
 	}
-	return percentValue, nil
+	return slack.MsgOptionBlocks(
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", text, false, false),
+			nil,
+			nil,
+		),
+		slack.NewActionBlock(
+			"",
+			slack.NewButtonBlockElement(SlackActionDuplicateConfirm, value,
+				slack.NewTextBlockObject("plain_text", "もう一度登録する", false, false),
+			).WithStyle(slack.StylePrimary),
+			slack.NewButtonBlockElement(SlackActionDuplicateCancel, "",
+				slack.NewTextBlockObject("plain_text", "やめる", false, false),
+			),
+		),
+	), nil
 }
 
-func botProfiles() slack.MsgOption {
+func buildNothingToUndoMessage(userID string) slack.MsgOption {
 	return slack.MsgOptionCompose(
-		slack.MsgOptionIconEmoji(":money_with_wings:"),
-		slack.MsgOptionUsername("割り勘"),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject("mrkdwn", ":warning: 元に戻せる立替えがありません！\n削除してから10分以内の立替えだけ戻せます", false, false),
+				nil,
+				nil,
+			),
+		),
+		slack.MsgOptionPostEphemeral(userID),
 	)
 }
 
-func buildPaymentCreatedMessage(userID string, amount valueobject.Yen) slack.MsgOption {
+func buildPayerJoinedMessage(userID string) slack.MsgOption {
 	return slack.MsgOptionBlocks(
 		slack.NewSectionBlock(
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf(":receipt: <@%s>さんが%s立て替えました！", userID, amount.String()), false, false),
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf(":purse: <@%s>さんが割り勘に参加します！", userID), false, false),
 			nil,
 			nil,
 		),
 	)
 }
 
-func buildPayerJoinedMessage(userID string) slack.MsgOption {
+func buildPayerWeightChangedMessage(payer *entity.Payer) slack.MsgOption {
 	return slack.MsgOptionBlocks(
 		slack.NewSectionBlock(
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf(":purse: <@%s>さんが割り勘に参加します！", userID), false, false),
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf(":scales: <@%s>さんの負担割合を%d%%に変更しました！", payer.ID.UserID(), payer.Weight.Int()), false, false),
 			nil,
 			nil,
 		),
@@ -76,59 +295,64 @@ func buildPayerAlreadyJoinedMessage(userID string) slack.MsgOption {
 }
 
 func buildSettlementMessage(settlement *usecase.Settlement) slack.MsgOption {
+	return slack.MsgOptionBlocks(presenter.RenderSettlementSlackBlocks(presenter.NewSettlementView(settlement), presenter.Mention)...)
+}
+
+// 1メッセージに載せるブロック数の上限に収まるよう、新しい履歴から表示する
+const maxHistoryEntries = 40
+
+func buildHistoryMessage(logs []*entity.AuditLog) slack.MsgOption {
 	blocks := []slack.Block{
 		slack.NewHeaderBlock(
-			slack.NewTextBlockObject("plain_text", ":moneybag: 集計結果", false, false),
+			slack.NewTextBlockObject("plain_text", ":scroll: 変更履歴", false, false),
 		),
 	}
-	payerAmountFields := []*slack.TextBlockObject{}
-	for payerID, amount := range settlement.AmountsAdvanced {
-		payerAmountFields = append(payerAmountFields,
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("<@%s> %s", payerID.String(), amount.String()), false, false),
-		)
-	}
-	payerFields := []*slack.TextBlockObject{}
-	for _, payer := range settlement.Payers {
-		payerFields = append(payerFields,
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("<@%s> %d%%", payer.ID.String(), payer.Weight.Int()), false, false),
-		)
-	}
-	blocks = append(blocks,
-		slack.NewSectionBlock(
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf(":receipt: 合計%sが立て替えられています", settlement.Total.String()), false, false),
-			payerAmountFields,
-			nil,
-		),
-		slack.NewDividerBlock(),
-		slack.NewSectionBlock(
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf(":purse: %d人で割り勘します", len(settlement.Payers)), false, false),
-			payerFields,
-			nil,
-		),
-		slack.NewDividerBlock(),
-		slack.NewSectionBlock(
-			slack.NewTextBlockObject("mrkdwn", ":money_with_wings: 次のように清算してください", false, false),
-			nil,
-			nil,
-		),
-	)
-	for _, instruction := range settlement.Instructions {
+	if len(logs) == 0 {
 		blocks = append(blocks,
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject(
-					"mrkdwn",
-					fmt.Sprintf("<@%s> → %s → <@%s>", instruction.From.String(), instruction.Amount.String(), instruction.To.String()),
-					false,
-					false,
-				),
+				slack.NewTextBlockObject("mrkdwn", "まだ履歴はありません", false, false),
 				nil,
 				nil,
 			),
 		)
+		return slack.MsgOptionBlocks(blocks...)
+	}
+	if len(logs) > maxHistoryEntries {
+		logs = logs[len(logs)-maxHistoryEntries:]
+	}
+	for _, log := range logs {
+		blocks = append(blocks,
+			slack.NewContextBlock("",
+				slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("%s <@%s> %s", log.CreatedAt.Format("01/02 15:04"), log.ActorID.UserID(), describeAuditLog(log, presenter.Mention)), false, false),
+			),
+		)
 	}
 	return slack.MsgOptionBlocks(blocks...)
 }
 
+// describeAuditLog は履歴の1行を作る。mentionで利用者の表し方をプラットフォームに合わせる
+func describeAuditLog(log *entity.AuditLog, mention presenter.Namer) string {
+	payer := mention(log.PayerID)
+	switch log.Action {
+	case valueobject.AuditActionPaymentCreated:
+		return fmt.Sprintf("が%sさんの立替え %s を登録", payer, log.After)
+	case valueobject.AuditActionPaymentUpdated:
+		return fmt.Sprintf("が%sさんの立替えを %s → %s に編集", payer, log.Before, log.After)
+	case valueobject.AuditActionPaymentDeleted:
+		return fmt.Sprintf("が%sさんの立替え %s を削除", payer, log.Before)
+	case valueobject.AuditActionPaymentRestored:
+		return fmt.Sprintf("が%sさんの立替え %s を元に戻した", payer, log.After)
+	case valueobject.AuditActionPayerJoined:
+		return fmt.Sprintf("が%sさんを %s で参加登録", payer, log.After)
+	case valueobject.AuditActionPayerLeft:
+		return fmt.Sprintf("が%sさんの参加を取り消し", payer)
+	case valueobject.AuditActionPayerWeightChanged:
+		return fmt.Sprintf("が%sさんの負担割合を %s → %s に変更", payer, log.Before, log.After)
+	default:
+		return log.Action.String()
+	}
+}
+
 func buildHelpMessage() slack.MsgOption {
 	return slack.MsgOptionBlocks(
 		slack.NewSectionBlock(
@@ -140,8 +364,10 @@ func buildHelpMessage() slack.MsgOption {
 		slack.NewSectionBlock(
 			slack.NewTextBlockObject("mrkdwn", ":receipt: *立替え登録*", false, false),
 			[]*slack.TextBlockObject{
-				slack.NewTextBlockObject("mrkdwn", "*登録する*\n`/warikan [金額]円`", false, false),
+				slack.NewTextBlockObject("mrkdwn", "*登録する*\n`/warikan [金額]円`\n`/warikan` だけ入力すると、内容や対象者も指定できます", false, false),
 				slack.NewTextBlockObject("mrkdwn", "*取り消す*\n登録メッセージを削除してください", false, false),
+				slack.NewTextBlockObject("mrkdwn", "*削除を元に戻す*\n`/warikan undo`", false, false),
+				slack.NewTextBlockObject("mrkdwn", "*編集する*\n登録メッセージのメニューから「編集」を選んでください", false, false),
 			},
 			nil,
 		),
@@ -149,7 +375,7 @@ func buildHelpMessage() slack.MsgOption {
 		slack.NewSectionBlock(
 			slack.NewTextBlockObject("mrkdwn", ":purse: *支払者登録*", false, false),
 			[]*slack.TextBlockObject{
-				slack.NewTextBlockObject("mrkdwn", "*登録する*\n`/warikan join ([重み]%)`", false, false),
+				slack.NewTextBlockObject("mrkdwn", "*登録する*\n`/warikan join ([重み]%)`\n参加後に重みを付けて実行すると重みを変更できます", false, false),
 				slack.NewTextBlockObject("mrkdwn", "*取り消す*\n登録メッセージを削除してください", false, false),
 			},
 			nil,
@@ -163,6 +389,14 @@ func buildHelpMessage() slack.MsgOption {
 			nil,
 		),
 		slack.NewDividerBlock(),
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", ":scroll: *履歴*", false, false),
+			[]*slack.TextBlockObject{
+				slack.NewTextBlockObject("mrkdwn", "*変更履歴を表示する*\n`/warikan history`", false, false),
+			},
+			nil,
+		),
+		slack.NewDividerBlock(),
 		slack.NewSectionBlock(
 			slack.NewTextBlockObject("mrkdwn", ":beginner: *ヘルプ*", false, false),
 			[]*slack.TextBlockObject{
@@ -185,3 +419,37 @@ func buildInvalidCommandMessage(userID string) slack.MsgOption {
 		slack.MsgOptionPostEphemeral(userID),
 	)
 }
+
+// buildBusyResponse はコマンドを受け付けられないときにSlackへ直接返す応答
+func buildBusyResponse() *slack.Msg {
+	return &slack.Msg{
+		ResponseType: slack.ResponseTypeEphemeral,
+		Text:         ":hourglass: ただいま混み合っています。少し待ってからもう一度お試しください",
+	}
+}
+
+func buildErrorMessage(err error) slack.MsgOption {
+	text := ":warning: エラーが発生しました (´・ω・`)\nしばらくしてからもう一度お試しください"
+	if e := new(valueobject.ErrorInvalid); errors.As(err, &e) {
+		text = ":warning: 金額や割合の書き方が正しくありません！\n使い方は `/warikan help` をご覧ください"
+	}
+	if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
+		text = ":warning: 対象の立替えや参加者が見つかりませんでした"
+	}
+	if e := new(valueobject.ErrorForbidden); errors.As(err, &e) {
+		text = ":warning: この操作は立替えた本人か幹事だけができます"
+	}
+	if errors.Is(err, errChannelUnavailable) {
+		text = ":warning: チャンネルの情報を取得できませんでした\nwarikan-botをチャンネルに追加してから、もう一度お試しください"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		text = ":warning: 処理に時間がかかりすぎたので中断しました\nもう一度お試しください"
+	}
+	return slack.MsgOptionBlocks(
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", text, false, false),
+			nil,
+			nil,
+		),
+	)
+}
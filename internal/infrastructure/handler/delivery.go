@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+// errDeliveryInProgress は同じリクエストをまだ処理している途中であることを表す
+var errDeliveryInProgress = errors.New("delivery is in progress")
+
+// deliveryKey はSlackが振ったIDから再送を見分けるキーを作る。IDがなければ見分けない
+func deliveryKey(kind string, id string) string {
+	if id == "" {
+		return ""
+	}
+	return kind + ":" + id
+}
+
+// processOnce はkeyのリクエストをまだ処理していなければfnを実行する
+// Slackは応答が遅いと同じリクエストを再送するので、処理し終えたものは読み飛ばす
+// 処理している途中のものは、先の処理が失敗したときに備えて errDeliveryInProgress を返し、もう一度送ってもらう。fnが失敗したときは再送を受け付ける
+func processOnce(ctx context.Context, deliveryUsecase *usecase.DeliveryUsecase, key string, fn func() error) error {
+	if key == "" {
+		return fn()
+	}
+	first, err := deliveryUsecase.Begin(ctx, key)
+	if e := new(valueobject.ErrorAlreadyExists); errors.As(err, &e) {
+		slog.InfoContext(ctx, "redelivered request is still in progress", slog.String("delivery", key))
+		return errDeliveryInProgress
+	}
+	if err != nil {
+		return err
+	}
+	if !first {
+		slog.InfoContext(ctx, "skipped redelivered request", slog.String("delivery", key))
+		return nil
+	}
+
+	if err := fn(); err != nil {
+		// 処理がタイムアウトしていても記録は消す
+		if abortErr := deliveryUsecase.Abort(context.WithoutCancel(ctx), key); abortErr != nil {
+			slog.ErrorContext(ctx, "failed to abort delivery", slog.String("delivery", key), slog.Any("error", abortErr))
+		}
+		return err
+	}
+	if err := deliveryUsecase.Complete(context.WithoutCancel(ctx), key); err != nil {
+		// 処理は終わっているので、記録できなくても成功として扱う
+		slog.ErrorContext(ctx, "failed to complete delivery", slog.String("delivery", key), slog.Any("error", err))
+	}
+	return nil
+}
@@ -0,0 +1,377 @@
+package handler
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/logging"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/metrics"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+const (
+	// リクエストのタイムスタンプと現在時刻のずれの上限。Slackと同じにする
+	discordTimestampTolerance = 5 * time.Minute
+	// Discordから届くリクエストの本文の上限
+	maxDiscordRequestBodySize = 1 << 20
+)
+
+// https://discord.com/developers/docs/interactions/receiving-and-responding
+const (
+	discordInteractionPing               = 1
+	discordInteractionApplicationCommand = 2
+	discordInteractionMessageComponent   = 3
+
+	discordResponsePong           = 1
+	discordResponseChannelMessage = 4
+	discordResponseUpdateMessage  = 7
+
+	// 実行した本人にだけ見えるメッセージ
+	discordFlagEphemeral = 1 << 6
+)
+
+// ボタンのcustom_id。後ろに":"区切りで値を続ける
+const (
+	DiscordActionPaymentDelete    = "warikan:delete"
+	DiscordActionDuplicateConfirm = "warikan:duplicate"
+	DiscordActionDuplicateCancel  = "warikan:cancel"
+)
+
+type discordUser struct {
+	ID string `json:"id"`
+}
+
+type discordMember struct {
+	User discordUser `json:"user"`
+}
+
+type discordCommandOption struct {
+	Name    string                 `json:"name"`
+	Type    int                    `json:"type"`
+	Value   json.RawMessage        `json:"value,omitempty"`
+	Options []discordCommandOption `json:"options,omitempty"`
+}
+
+type discordInteractionData struct {
+	// スラッシュコマンドのとき
+	Name    string                 `json:"name"`
+	Options []discordCommandOption `json:"options"`
+	// ボタンのとき
+	CustomID string `json:"custom_id"`
+}
+
+type discordInteraction struct {
+	ID        string                 `json:"id"`
+	Type      int                    `json:"type"`
+	GuildID   string                 `json:"guild_id"`
+	ChannelID string                 `json:"channel_id"`
+	Member    *discordMember         `json:"member"`
+	User      *discordUser           `json:"user"`
+	Data      discordInteractionData `json:"data"`
+}
+
+// userID は操作した人を返す。サーバーではmemberに、ダイレクトメッセージではuserに入っている
+func (i *discordInteraction) userID() string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+type discordInteractionResponse struct {
+	Type int             `json:"type"`
+	Data *discordMessage `json:"data,omitempty"`
+}
+
+// DiscordInteractionHandler はDiscordのスラッシュコマンドとボタンを、Slackと同じユースケースで処理する
+// Discordは3秒以内の応答を求めるが、データベースの操作だけで済むのでその場で結果を返す
+type DiscordInteractionHandler struct {
+	publicKey      ed25519.PublicKey
+	paymentUsecase *usecase.PaymentUsecase
+	metrics        *metrics.Metrics
+	now            func() time.Time
+}
+
+func NewDiscordInteractionHandler(publicKey ed25519.PublicKey, paymentUsecase *usecase.PaymentUsecase, metrics *metrics.Metrics) *DiscordInteractionHandler {
+	return &DiscordInteractionHandler{
+		publicKey:      publicKey,
+		paymentUsecase: paymentUsecase,
+		metrics:        metrics,
+		now:            time.Now,
+	}
+}
+
+func (h *DiscordInteractionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDiscordRequestBodySize))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	// 署名が正しくないリクエストには401を返すよう、Discordに求められている
+	if !h.verify(r.Header.Get("X-Signature-Ed25519"), r.Header.Get("X-Signature-Timestamp"), body) {
+		slog.WarnContext(ctx, "rejected discord request with invalid signature")
+		http.Error(w, "Invalid request", http.StatusUnauthorized)
+		return
+	}
+
+	var interaction discordInteraction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		http.Error(w, "Failed to parse Discord interaction", http.StatusBadRequest)
+		return
+	}
+
+	var response *discordInteractionResponse
+	switch interaction.Type {
+	case discordInteractionPing:
+		response = &discordInteractionResponse{Type: discordResponsePong}
+	case discordInteractionApplicationCommand:
+		response = h.handleCommand(ctx, &interaction)
+	case discordInteractionMessageComponent:
+		response = h.handleComponent(ctx, &interaction)
+	default:
+		http.Error(w, "Unsupported interaction type", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// verify はDiscordの公開鍵で、タイムスタンプと本文への署名を確かめる
+// https://discord.com/developers/docs/interactions/overview#setting-up-an-endpoint-validating-security-request-headers
+func (h *DiscordInteractionHandler) verify(rawSignature string, rawTimestamp string, body []byte) bool {
+	signature, err := hex.DecodeString(rawSignature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return false
+	}
+	unix, err := strconv.ParseInt(rawTimestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := h.now().Sub(time.Unix(unix, 0)).Abs(); skew > discordTimestampTolerance {
+		return false
+	}
+	message := append([]byte(rawTimestamp), body...)
+	return ed25519.Verify(h.publicKey, message, signature)
+}
+
+func (h *DiscordInteractionHandler) handleCommand(ctx context.Context, interaction *discordInteraction) *discordInteractionResponse {
+	subcommand := subcommandInvalid
+	var options []discordCommandOption
+	if interaction.Data.Name == "warikan" && len(interaction.Data.Options) > 0 {
+		subcommand = interaction.Data.Options[0].Name
+		options = interaction.Data.Options[0].Options
+	}
+	ctx = logging.With(ctx,
+		slog.String("platform", "discord"),
+		slog.String("guild", interaction.GuildID),
+		slog.String("channel", interaction.ChannelID),
+		slog.String("user", interaction.userID()),
+		slog.String("subcommand", subcommand),
+	)
+
+	start := time.Now()
+	response, err := h.handleWarikanCommand(ctx, interaction, subcommand, options)
+	h.metrics.CommandHandled(subcommand, err)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to handle discord command", slog.Any("error", err))
+		return discordReply(buildDiscordErrorMessage(err))
+	}
+	slog.InfoContext(ctx, "handled discord command", slog.Duration("duration", time.Since(start)))
+	return response
+}
+
+func (h *DiscordInteractionHandler) handleWarikanCommand(ctx context.Context, interaction *discordInteraction, subcommand string, options []discordCommandOption) (*discordInteractionResponse, error) {
+	eventID := valueobject.NewDiscordEventID(interaction.GuildID, interaction.ChannelID)
+	payerID := valueobject.NewDiscordPayerID(interaction.userID())
+
+	switch subcommand {
+	case subcommandJoin:
+		weight := valueobject.Percent(100)
+		rawWeight, hasWeight := discordIntOption(options, "weight")
+		if hasWeight {
+			w, err := valueobject.NewPercent(int(rawWeight))
+			if err != nil {
+				return nil, valueobject.NewErrorInvalid("invalid percent", err)
+			}
+			weight = w
+		}
+		payer, err := h.paymentUsecase.Join(ctx, eventID, payerID, weight)
+		if e := new(valueobject.ErrorAlreadyExists); errors.As(err, &e) {
+			if !hasWeight {
+				return discordReply(buildDiscordPayerAlreadyJoinedMessage(payerID)), nil
+			}
+			// 参加済みで重みが指定された場合は重みを変更する
+			payer, err := h.paymentUsecase.ChangeWeight(ctx, eventID, payerID, weight)
+			if err != nil {
+				return nil, err
+			}
+			return discordReply(buildDiscordPayerWeightChangedMessage(payer)), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return discordReply(buildDiscordPayerJoinedMessage(payer)), nil
+
+	case subcommandPay:
+		rawAmount, _ := discordIntOption(options, "amount")
+		amount, err := valueobject.NewYen(int(rawAmount))
+		if err != nil {
+			return nil, valueobject.NewErrorInvalid("invalid amount", err)
+		}
+		memo, _ := discordStringOption(options, "memo")
+
+		payment, err := h.paymentUsecase.Create(ctx, eventID, payerID, payerID, amount, memo, nil)
+		if e := new(valueobject.ErrorDuplicatePayment); errors.As(err, &e) {
+			return discordReply(buildDiscordDuplicatePaymentMessage(payerID, amount, memo)), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return discordReply(buildDiscordPaymentCreatedMessage(payment)), nil
+
+	case subcommandSettle:
+		settlement, err := h.paymentUsecase.Settle(ctx, eventID)
+		if err != nil {
+			return nil, err
+		}
+		return discordReply(buildDiscordSettlementMessage(settlement)), nil
+
+	case subcommandUndo:
+		payment, err := h.paymentUsecase.Undo(ctx, eventID, payerID)
+		if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
+			return discordReply(buildDiscordNothingToUndoMessage()), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return discordReply(buildDiscordPaymentCreatedMessage(payment)), nil
+
+	case subcommandHistory:
+		logs, err := h.paymentUsecase.History(ctx, eventID)
+		if err != nil {
+			return nil, err
+		}
+		return discordReply(buildDiscordHistoryMessage(logs)), nil
+
+	case subcommandHelp:
+		return discordReply(buildDiscordHelpMessage()), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported discord command: %s %s", interaction.Data.Name, subcommand)
+	}
+}
+
+func (h *DiscordInteractionHandler) handleComponent(ctx context.Context, interaction *discordInteraction) *discordInteractionResponse {
+	ctx = logging.With(ctx,
+		slog.String("platform", "discord"),
+		slog.String("guild", interaction.GuildID),
+		slog.String("channel", interaction.ChannelID),
+		slog.String("user", interaction.userID()),
+		slog.String("custom_id", interaction.Data.CustomID),
+	)
+	response, err := h.handleButton(ctx, interaction)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to handle discord component", slog.Any("error", err))
+		return discordReply(buildDiscordErrorMessage(err))
+	}
+	return response
+}
+
+func (h *DiscordInteractionHandler) handleButton(ctx context.Context, interaction *discordInteraction) (*discordInteractionResponse, error) {
+	eventID := valueobject.NewDiscordEventID(interaction.GuildID, interaction.ChannelID)
+	actorID := valueobject.NewDiscordPayerID(interaction.userID())
+	action, value := cutDiscordCustomID(interaction.Data.CustomID)
+
+	switch action {
+	case DiscordActionPaymentDelete:
+		paymentID, err := valueobject.NewPaymentIDFromString(value)
+		if err != nil {
+			return nil, err
+		}
+		// ボタンは誰でも押せるので、立て替えた本人か幹事かを確かめる
+		payment, err := h.paymentUsecase.DeleteByEditor(ctx, paymentID, actorID)
+		if err != nil {
+			return nil, err
+		}
+		return discordUpdate(buildDiscordPaymentDeletedMessage(payment)), nil
+
+	case DiscordActionDuplicateConfirm:
+		rawAmount, memo, _ := strings.Cut(value, ":")
+		amount, err := valueobject.ParseYen(rawAmount)
+		if err != nil {
+			return nil, err
+		}
+		payment, err := h.paymentUsecase.CreateConfirmed(ctx, eventID, actorID, actorID, amount, memo, nil)
+		if err != nil {
+			return nil, err
+		}
+		return discordReply(buildDiscordPaymentCreatedMessage(payment)), nil
+
+	case DiscordActionDuplicateCancel:
+		return discordUpdate(buildDiscordDuplicateCanceledMessage()), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported discord component: %s", interaction.Data.CustomID)
+	}
+}
+
+// discordReply はメッセージを新しく投稿する応答を作る
+func discordReply(message *discordMessage) *discordInteractionResponse {
+	return &discordInteractionResponse{Type: discordResponseChannelMessage, Data: message}
+}
+
+// discordUpdate はボタンが押されたメッセージを書き換える応答を作る
+func discordUpdate(message *discordMessage) *discordInteractionResponse {
+	return &discordInteractionResponse{Type: discordResponseUpdateMessage, Data: message}
+}
+
+func discordIntOption(options []discordCommandOption, name string) (int64, bool) {
+	for _, option := range options {
+		if option.Name != name {
+			continue
+		}
+		var value int64
+		if err := json.Unmarshal(option.Value, &value); err != nil {
+			return 0, false
+		}
+		return value, true
+	}
+	return 0, false
+}
+
+func discordStringOption(options []discordCommandOption, name string) (string, bool) {
+	for _, option := range options {
+		if option.Name != name {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(option.Value, &value); err != nil {
+			return "", false
+		}
+		return strings.TrimSpace(value), true
+	}
+	return "", false
+}
+
+// cutDiscordCustomID はcustom_idを操作と値に分ける。操作は"warikan:"で始まる2つ目の区切りまで
+func cutDiscordCustomID(customID string) (action string, value string) {
+	prefix, rest, _ := strings.Cut(customID, ":")
+	name, value, _ := strings.Cut(rest, ":")
+	return prefix + ":" + name, value
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const discordAPIURL = "https://discord.com/api/v10"
+
+// https://discord.com/developers/docs/interactions/application-commands
+const (
+	discordOptionSubcommand = 1
+	discordOptionString     = 3
+	discordOptionInteger    = 4
+)
+
+type discordCommand struct {
+	Name        string                    `json:"name"`
+	Description string                    `json:"description"`
+	Options     []discordCommandOptionDef `json:"options,omitempty"`
+}
+
+type discordCommandOptionDef struct {
+	Type        int                       `json:"type"`
+	Name        string                    `json:"name"`
+	Description string                    `json:"description"`
+	Required    bool                      `json:"required,omitempty"`
+	MinValue    *int                      `json:"min_value,omitempty"`
+	MaxLength   int                       `json:"max_length,omitempty"`
+	Options     []discordCommandOptionDef `json:"options,omitempty"`
+}
+
+// 重複の確認ボタンのcustom_idに、金額と一緒に収まる長さ
+const maxDiscordMemoLength = 50
+
+// discordCommands は登録する /warikan コマンド。サブコマンドの名前はSlackのものと合わせる
+func discordCommands() []discordCommand {
+	minAmount := 1
+	minWeight := 1
+	return []discordCommand{
+		{
+			Name:        "warikan",
+			Description: "割り勘の計算をします",
+			Options: []discordCommandOptionDef{
+				{
+					Type:        discordOptionSubcommand,
+					Name:        subcommandPay,
+					Description: "立替えを登録します",
+					Options: []discordCommandOptionDef{
+						{Type: discordOptionInteger, Name: "amount", Description: "金額（円）", Required: true, MinValue: &minAmount},
+						{Type: discordOptionString, Name: "memo", Description: "内容", MaxLength: maxDiscordMemoLength},
+					},
+				},
+				{
+					Type:        discordOptionSubcommand,
+					Name:        subcommandJoin,
+					Description: "割り勘に参加します。参加後に重みを付けると重みを変更します",
+					Options: []discordCommandOptionDef{
+						{Type: discordOptionInteger, Name: "weight", Description: "負担割合（%）", MinValue: &minWeight},
+					},
+				},
+				{Type: discordOptionSubcommand, Name: subcommandSettle, Description: "清算方法を表示します"},
+				{Type: discordOptionSubcommand, Name: subcommandUndo, Description: "削除した立替えを元に戻します"},
+				{Type: discordOptionSubcommand, Name: subcommandHistory, Description: "変更履歴を表示します"},
+				{Type: discordOptionSubcommand, Name: subcommandHelp, Description: "使い方を表示します"},
+			},
+		},
+	}
+}
+
+// RegisterDiscordCommands はアプリケーションのコマンドを /warikan だけに置き換える。何度実行しても同じ結果になる
+func RegisterDiscordCommands(ctx context.Context, httpClient *http.Client, applicationID string, botToken string) error {
+	body, err := json.Marshal(discordCommands())
+	if err != nil {
+		return fmt.Errorf("failed to marshal discord commands: %w", err)
+	}
+	url := fmt.Sprintf("%s/applications/%s/commands", discordAPIURL, applicationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bot "+botToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to register discord commands: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to register discord commands: %s: %s", resp.Status, message)
+	}
+	return nil
+}
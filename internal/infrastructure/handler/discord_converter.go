@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/presenter"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+// https://discord.com/developers/docs/resources/message
+type discordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []discordEmbed `json:"embeds"`
+	// ボタンを消すときに空の配列を送る必要があるので、省略しない
+	Components []discordComponent `json:"components"`
+	Flags      int                `json:"flags,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color,omitempty"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type discordComponent struct {
+	Type       int                `json:"type"`
+	Style      int                `json:"style,omitempty"`
+	Label      string             `json:"label,omitempty"`
+	CustomID   string             `json:"custom_id,omitempty"`
+	Components []discordComponent `json:"components,omitempty"`
+}
+
+const (
+	discordComponentActionRow = 1
+	discordComponentButton    = 2
+
+	discordButtonPrimary   = 1
+	discordButtonSecondary = 2
+	discordButtonDanger    = 4
+
+	discordColorInfo    = 0x2eb67d
+	discordColorWarning = 0xecb22e
+
+	// custom_idに入れられる文字数の上限
+	maxDiscordCustomIDLength = 100
+	// 1つの埋め込みに表示する履歴の数。説明文の文字数の上限に収まるようにする
+	maxDiscordHistoryEntries = 25
+)
+
+func newDiscordMessage(embeds ...discordEmbed) *discordMessage {
+	return &discordMessage{
+		Embeds:     embeds,
+		Components: []discordComponent{},
+	}
+}
+
+func newDiscordEphemeralMessage(embeds ...discordEmbed) *discordMessage {
+	message := newDiscordMessage(embeds...)
+	message.Flags = discordFlagEphemeral
+	return message
+}
+
+func newDiscordButtons(buttons ...discordComponent) []discordComponent {
+	return []discordComponent{{Type: discordComponentActionRow, Components: buttons}}
+}
+
+func newDiscordButton(style int, label string, customID string) discordComponent {
+	return discordComponent{Type: discordComponentButton, Style: style, Label: label, CustomID: customID}
+}
+
+func buildDiscordPaymentCreatedMessage(payment *entity.Payment) *discordMessage {
+	description := fmt.Sprintf("🧾 %sさんが%s立て替えました！", presenter.Mention(payment.PayerID), payment.Amount.String())
+	if payment.Memo != "" {
+		description += "\n" + payment.Memo
+	}
+	message := newDiscordMessage(discordEmbed{Description: description, Color: discordColorInfo})
+	message.Components = newDiscordButtons(
+		newDiscordButton(discordButtonDanger, "取り消す", DiscordActionPaymentDelete+":"+payment.ID.String()),
+	)
+	return message
+}
+
+func buildDiscordPaymentDeletedMessage(payment *entity.Payment) *discordMessage {
+	return newDiscordMessage(discordEmbed{
+		Description: fmt.Sprintf("🗑️ %sさんの%sの立替えを取り消しました\n`/warikan undo` で元に戻せます", presenter.Mention(payment.PayerID), payment.Amount.String()),
+	})
+}
+
+// buildDiscordDuplicatePaymentMessage は直前に同じ立替えが登録されていたときに、本当に登録するか確かめる
+// まだ登録していない立替えはボタンのcustom_idに持たせる。入りきらないメモは切り詰める
+func buildDiscordDuplicatePaymentMessage(payerID valueobject.PayerID, amount valueobject.Yen, memo string) *discordMessage {
+	description := fmt.Sprintf("🤔 %sさんの%sの立替えは、直前にも登録されています！\n二重に送信されたのでなければ、もう一度登録してください", presenter.Mention(payerID), amount.String())
+	if memo != "" {
+		description += "\n" + memo
+	}
+	customID := DiscordActionDuplicateConfirm + ":" + strconv.FormatInt(amount.Int64(), 10) + ":" + memo
+	for utf8.RuneCountInString(customID) > maxDiscordCustomIDLength {
+		_, size := utf8.DecodeLastRuneInString(customID)
+		customID = customID[:len(customID)-size]
+	}
+	message := newDiscordEphemeralMessage(discordEmbed{Description: description, Color: discordColorWarning})
+	message.Components = newDiscordButtons(
+		newDiscordButton(discordButtonPrimary, "もう一度登録する", customID),
+		newDiscordButton(discordButtonSecondary, "やめる", DiscordActionDuplicateCancel),
+	)
+	return message
+}
+
+func buildDiscordDuplicateCanceledMessage() *discordMessage {
+	return newDiscordEphemeralMessage(discordEmbed{Description: "登録をやめました"})
+}
+
+func buildDiscordNothingToUndoMessage() *discordMessage {
+	return newDiscordEphemeralMessage(discordEmbed{
+		Description: "⚠️ 元に戻せる立替えがありません！\n削除してから10分以内の立替えだけ戻せます",
+		Color:       discordColorWarning,
+	})
+}
+
+func buildDiscordPayerJoinedMessage(payer *entity.Payer) *discordMessage {
+	return newDiscordMessage(discordEmbed{
+		Description: fmt.Sprintf("👛 %sさんが割り勘に参加します！", presenter.Mention(payer.ID)),
+		Color:       discordColorInfo,
+	})
+}
+
+func buildDiscordPayerWeightChangedMessage(payer *entity.Payer) *discordMessage {
+	return newDiscordMessage(discordEmbed{
+		Description: fmt.Sprintf("⚖️ %sさんの負担割合を%d%%に変更しました！", presenter.Mention(payer.ID), payer.Weight.Int()),
+		Color:       discordColorInfo,
+	})
+}
+
+func buildDiscordPayerAlreadyJoinedMessage(payerID valueobject.PayerID) *discordMessage {
+	return newDiscordEphemeralMessage(discordEmbed{
+		Description: fmt.Sprintf("⚠️ %sさんはすでに割り勘に参加しています！", presenter.Mention(payerID)),
+		Color:       discordColorWarning,
+	})
+}
+
+func buildDiscordSettlementMessage(settlement *usecase.Settlement) *discordMessage {
+	view := presenter.NewSettlementView(settlement)
+	embed := discordEmbed{
+		Title: presenter.Emoji(view.Icon) + view.Title,
+		Color: discordColorInfo,
+	}
+	for _, section := range view.Sections {
+		lines := make([]string, 0, len(section.Lines))
+		for _, line := range section.Lines {
+			lines = append(lines, line.Format(presenter.Mention))
+		}
+		value := strings.Join(lines, "\n")
+		if value == "" {
+			value = section.Empty
+		}
+		if value == "" {
+			// Discordは空の値を受け付けない
+			value = "なし"
+		}
+		embed.Fields = append(embed.Fields, discordEmbedField{Name: presenter.Emoji(section.Icon) + section.Heading, Value: value})
+	}
+	return newDiscordMessage(embed)
+}
+
+func buildDiscordHistoryMessage(logs []*entity.AuditLog) *discordMessage {
+	if len(logs) == 0 {
+		return newDiscordEphemeralMessage(discordEmbed{Title: "📜 変更履歴", Description: "まだ履歴はありません"})
+	}
+	if len(logs) > maxDiscordHistoryEntries {
+		logs = logs[len(logs)-maxDiscordHistoryEntries:]
+	}
+	lines := make([]string, 0, len(logs))
+	for _, log := range logs {
+		lines = append(lines, fmt.Sprintf("`%s` %s %s", log.CreatedAt.Format("01/02 15:04"), presenter.Mention(log.ActorID), describeAuditLog(log, presenter.Mention)))
+	}
+	return newDiscordEphemeralMessage(discordEmbed{Title: "📜 変更履歴", Description: strings.Join(lines, "\n")})
+}
+
+func buildDiscordHelpMessage() *discordMessage {
+	return newDiscordEphemeralMessage(discordEmbed{
+		Title:       "Discordで割り勘の計算ができます 🎉",
+		Description: "支払いの集計はチャンネルごとに行われるので、イベント用のチャンネルで使ってください！",
+		Color:       discordColorInfo,
+		Fields: []discordEmbedField{
+			{Name: "🧾 立替え登録", Value: "`/warikan pay amount:[金額] (memo:[内容])`\n取り消すときは登録メッセージのボタンを、元に戻すときは `/warikan undo` を使ってください"},
+			{Name: "👛 支払者登録", Value: "`/warikan join (weight:[重み])`\n参加後に重みを付けて実行すると重みを変更できます"},
+			{Name: "💰 清算", Value: "`/warikan settle`"},
+			{Name: "📜 履歴", Value: "`/warikan history`"},
+		},
+	})
+}
+
+func buildDiscordErrorMessage(err error) *discordMessage {
+	text := "⚠️ エラーが発生しました (´・ω・`)\nしばらくしてからもう一度お試しください"
+	if e := new(valueobject.ErrorInvalid); errors.As(err, &e) {
+		text = "⚠️ 金額や割合の書き方が正しくありません！\n使い方は `/warikan help` をご覧ください"
+	}
+	if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
+		text = "⚠️ 対象の立替えや参加者が見つかりませんでした"
+	}
+	if e := new(valueobject.ErrorForbidden); errors.As(err, &e) {
+		text = "⚠️ この操作は立替えた本人か幹事だけができます"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		text = "⚠️ 処理に時間がかかりすぎたので中断しました\nもう一度お試しください"
+	}
+	return newDiscordEphemeralMessage(discordEmbed{Description: text, Color: discordColorWarning})
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/memory"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+func newDiscordTestHandler(t *testing.T) (*DiscordInteractionHandler, ed25519.PrivateKey, time.Time) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	now := time.Date(2025, 5, 1, 19, 0, 0, 0, time.UTC)
+	h := NewDiscordInteractionHandler(publicKey, usecase.NewPayment(memory.NewStore(), nil), nil)
+	h.now = func() time.Time { return now }
+	return h, privateKey, now
+}
+
+// newDiscordRequest はDiscordと同じ手順で署名したリクエストを作る
+func newDiscordRequest(privateKey ed25519.PrivateKey, timestamp time.Time, body string) *http.Request {
+	rawTimestamp := strconv.FormatInt(timestamp.Unix(), 10)
+	r := httptest.NewRequest(http.MethodPost, "/discord/interactions", strings.NewReader(body))
+	r.Header.Set("X-Signature-Ed25519", hex.EncodeToString(ed25519.Sign(privateKey, []byte(rawTimestamp+body))))
+	r.Header.Set("X-Signature-Timestamp", rawTimestamp)
+	return r
+}
+
+func TestDiscordInteractionHandler_Verify(t *testing.T) {
+	t.Parallel()
+
+	const ping = `{"id":"1","type":1}`
+	tests := []struct {
+		name           string
+		request        func(privateKey ed25519.PrivateKey, now time.Time) *http.Request
+		expectedStatus int
+	}{
+		{
+			name: "OK: ping",
+			request: func(privateKey ed25519.PrivateKey, now time.Time) *http.Request {
+				return newDiscordRequest(privateKey, now, ping)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "NG: signed by another key",
+			request: func(privateKey ed25519.PrivateKey, now time.Time) *http.Request {
+				_, otherKey, _ := ed25519.GenerateKey(nil)
+				return newDiscordRequest(otherKey, now, ping)
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "NG: tampered body",
+			request: func(privateKey ed25519.PrivateKey, now time.Time) *http.Request {
+				r := newDiscordRequest(privateKey, now, ping)
+				r.Body = http.NoBody
+				return r
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "NG: stale timestamp",
+			request: func(privateKey ed25519.PrivateKey, now time.Time) *http.Request {
+				return newDiscordRequest(privateKey, now.Add(-discordTimestampTolerance-time.Second), ping)
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "NG: missing headers",
+			request: func(privateKey ed25519.PrivateKey, now time.Time) *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/discord/interactions", strings.NewReader(ping))
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			h, privateKey, now := newDiscordTestHandler(t)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, test.request(privateKey, now))
+			assert.Equal(t, test.expectedStatus, w.Code)
+			if test.expectedStatus == http.StatusOK {
+				assert.JSONEq(t, `{"type":1}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDiscordInteractionHandler_Settle(t *testing.T) {
+	t.Parallel()
+
+	h, privateKey, now := newDiscordTestHandler(t)
+	send := func(body string) discordInteractionResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, newDiscordRequest(privateKey, now, body))
+		require.Equal(t, http.StatusOK, w.Code)
+		var response discordInteractionResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.NotNil(t, response.Data)
+		return response
+	}
+	command := func(userID string, subcommand string, options string) string {
+		return `{"id":"1","type":2,"guild_id":"G1","channel_id":"C1","member":{"user":{"id":"` + userID + `"}},` +
+			`"data":{"name":"warikan","options":[{"name":"` + subcommand + `","type":1,"options":[` + options + `]}]}}`
+	}
+
+	send(command("100", subcommandJoin, ""))
+	send(command("200", subcommandJoin, ""))
+	paid := send(command("100", subcommandPay, `{"name":"amount","type":4,"value":3000},{"name":"memo","type":3,"value":"居酒屋"}`))
+	assert.Equal(t, discordResponseChannelMessage, paid.Type)
+	assert.Equal(t, "<@100>さんが3,000円立て替えました！\n居酒屋", strings.TrimPrefix(paid.Data.Embeds[0].Description, "🧾 "))
+	require.Len(t, paid.Data.Components, 1)
+	deleteID := paid.Data.Components[0].Components[0].CustomID
+
+	settled := send(command("200", subcommandSettle, ""))
+	require.Len(t, settled.Data.Embeds, 1)
+	embed := settled.Data.Embeds[0]
+	assert.Equal(t, "🧾 合計3,000円が立て替えられています", embed.Fields[0].Name)
+	assert.Equal(t, "<@100> 3,000円", embed.Fields[0].Value)
+	assert.Equal(t, "<@200> → 1,500円 → <@100>", embed.Fields[2].Value)
+
+	// 立て替えた本人でも幹事でもない人は取り消せない
+	button := func(userID string) string {
+		return `{"id":"2","type":3,"guild_id":"G1","channel_id":"C1","member":{"user":{"id":"` + userID + `"}},"data":{"custom_id":"` + deleteID + `","component_type":2}}`
+	}
+	rejected := send(button("200"))
+	assert.Equal(t, discordResponseChannelMessage, rejected.Type)
+	assert.Equal(t, discordFlagEphemeral, rejected.Data.Flags)
+
+	deleted := send(button("100"))
+	assert.Equal(t, discordResponseUpdateMessage, deleted.Type)
+	assert.Empty(t, deleted.Data.Components)
+}
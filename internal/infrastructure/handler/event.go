@@ -1,30 +1,47 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"strings"
 
-	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 
 	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/logging"
 	"github.com/kakudo415/warikan-bot/internal/usecase"
 )
 
+// レシート画像として扱うファイルサイズの上限
+const maxReceiptImageSize = 10 << 20
+
 type SlackEventHandler struct {
-	signingSecret  string
-	client         *slack.Client
-	paymentUsecase *usecase.PaymentUsecase
+	clients             SlackClients
+	channels            *SlackChannels
+	users               *SlackUsers
+	paymentUsecase      *usecase.PaymentUsecase
+	receiptUsecase      *usecase.ReceiptUsecase
+	deliveryUsecase     *usecase.DeliveryUsecase
+	installationUsecase *usecase.InstallationUsecase
+	botProfile          BotProfile
 }
 
-func NewSlackEventHandler(token string, signingSecret string, paymentUsecase *usecase.PaymentUsecase) *SlackEventHandler {
+func NewSlackEventHandler(clients SlackClients, channels *SlackChannels, users *SlackUsers, paymentUsecase *usecase.PaymentUsecase, receiptUsecase *usecase.ReceiptUsecase, deliveryUsecase *usecase.DeliveryUsecase, installationUsecase *usecase.InstallationUsecase, botProfile BotProfile) *SlackEventHandler {
 	return &SlackEventHandler{
-		client:         slack.New(token),
-		signingSecret:  signingSecret,
-		paymentUsecase: paymentUsecase,
+		clients:             clients,
+		channels:            channels,
+		users:               users,
+		paymentUsecase:      paymentUsecase,
+		receiptUsecase:      receiptUsecase,
+		deliveryUsecase:     deliveryUsecase,
+		installationUsecase: installationUsecase,
+		botProfile:          botProfile,
 	}
 }
 
@@ -34,19 +51,6 @@ func (h *SlackEventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to read request body", http.StatusBadRequest)
 		return
 	}
-	verifier, err := slack.NewSecretsVerifier(r.Header, h.signingSecret)
-	if err != nil {
-		http.Error(w, "Failed to create secrets verifier", http.StatusBadRequest)
-		return
-	}
-	if _, err := verifier.Write(body); err != nil {
-		http.Error(w, "Failed to write to secrets verifier", http.StatusInternalServerError)
-		return
-	}
-	if err := verifier.Ensure(); err != nil {
-		http.Error(w, "Invalid request signature", http.StatusUnauthorized)
-		return
-	}
 
 	event, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
 	if err != nil {
@@ -67,7 +71,17 @@ func (h *SlackEventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if event.Type == slackevents.CallbackEvent {
-		err = h.handleCallbackEvent(event)
+		ctx := r.Context()
+		// 応答が遅れたり失敗したりすると、Slackは同じイベントを再送してくる
+		if retryNum := r.Header.Get("X-Slack-Retry-Num"); retryNum != "" {
+			ctx = logging.With(ctx, slog.String("retry_num", retryNum), slog.String("retry_reason", r.Header.Get("X-Slack-Retry-Reason")))
+		}
+		err = h.handleCallbackEvent(ctx, event)
+		if errors.Is(err, errDeliveryInProgress) {
+			// 先の処理が終わらなかったときにやり直せるように、もう一度送ってもらう
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
 		if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
 			http.Error(w, e.Error(), http.StatusNotFound)
 			return
@@ -77,6 +91,7 @@ func (h *SlackEventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err != nil {
+			slog.ErrorContext(ctx, "failed to handle callback event", slog.Any("error", err))
 			http.Error(w, "Failed to handle callback event", http.StatusInternalServerError)
 		}
 		return
@@ -85,36 +100,114 @@ func (h *SlackEventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Unsupported event type", http.StatusBadRequest)
 }
 
-func (h *SlackEventHandler) handleCallbackEvent(event slackevents.EventsAPIEvent) error {
+func (h *SlackEventHandler) handleCallbackEvent(ctx context.Context, event slackevents.EventsAPIEvent) error {
+	ctx = logging.With(ctx, slog.String("enterprise", event.EnterpriseID), slog.String("team", event.TeamID), slog.String("event_type", event.InnerEvent.Type))
+
+	var eventID string
+	if callback, ok := event.Data.(*slackevents.EventsAPICallbackEvent); ok {
+		eventID = callback.EventID
+	}
+	return processOnce(ctx, h.deliveryUsecase, deliveryKey("event", eventID), func() error {
+		return h.handleInnerEvent(ctx, event)
+	})
+}
+
+func (h *SlackEventHandler) handleInnerEvent(ctx context.Context, event slackevents.EventsAPIEvent) error {
+	teamID := valueobject.NewEnterpriseTeamID(event.EnterpriseID, event.TeamID)
 	switch e := event.InnerEvent.Data.(type) {
 	case *slackevents.MessageMetadataDeletedEvent:
-		if err := h.handleMessageMetadataDeletedEvent(e); err != nil {
+		if err := h.handleMessageMetadataDeletedEvent(ctx, teamID, e); err != nil {
+			return err
+		}
+	case *slackevents.FileSharedEvent:
+		if err := h.handleFileSharedEvent(ctx, teamID, e); err != nil {
 			return err
 		}
+	case *slackevents.AppUninstalledEvent:
+		return h.installationUsecase.Uninstall(ctx, teamID)
+	case *slackevents.TokensRevokedEvent:
+		// ユーザーのトークンだけが取り消されたときは、ボットはそのまま使える
+		if len(e.Tokens.Bot) == 0 {
+			return nil
+		}
+		return h.installationUsecase.Uninstall(ctx, teamID)
 	default:
 		return fmt.Errorf("unsupported event type: %T", e)
 	}
 	return nil
 }
 
-func (h *SlackEventHandler) handleMessageMetadataDeletedEvent(event *slackevents.MessageMetadataDeletedEvent) error {
-	if event.PreviousMetadata.EventType != "warikan" {
+func (h *SlackEventHandler) handleMessageMetadataDeletedEvent(ctx context.Context, teamID valueobject.TeamID, event *slackevents.MessageMetadataDeletedEvent) error {
+	if event.PreviousMetadata.EventType != SlackMetadataEventType {
 		return nil
 	}
+	ctx = logging.With(ctx, slog.String("channel", event.ChannelId), slog.String("user", event.UserId))
+	client, err := h.clients.Client(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	actorID, err := h.users.PayerID(ctx, client, event.UserId)
+	if err != nil {
+		return err
+	}
 
-	paymentIDPayload := event.PreviousMetadata.EventPayload["payment_id"]
-	if paymentIDPayload == nil {
-		return nil
+	if rawPaymentID, ok := event.PreviousMetadata.EventPayload["payment_id"].(string); ok {
+		paymentID, err := valueobject.NewPaymentIDFromString(rawPaymentID)
+		if err != nil {
+			return err
+		}
+		err = h.paymentUsecase.Delete(ctx, paymentID, actorID)
+		if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
+			// すでに削除されている
+			return nil
+		}
+		return err
+	}
+
+	if rawPayerID, ok := event.PreviousMetadata.EventPayload["payer_id"].(string); ok {
+		eventID, err := h.channels.EventID(ctx, client, teamID, event.ChannelId)
+		if err != nil {
+			return err
+		}
+		payerID := valueobject.NewPayerID(rawPayerID)
+		err = h.paymentUsecase.Leave(ctx, eventID, payerID, actorID)
+		if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (h *SlackEventHandler) handleFileSharedEvent(ctx context.Context, teamID valueobject.TeamID, event *slackevents.FileSharedEvent) error {
+	ctx = logging.With(ctx, slog.String("channel", event.ChannelID), slog.String("user", event.UserID))
+	client, err := h.clients.Client(ctx, teamID)
+	if err != nil {
+		return err
 	}
-	rawPaymentID, ok := paymentIDPayload.(string)
-	if !ok {
+	file, _, _, err := client.GetFileInfoContext(ctx, event.FileID, 0, 0)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(file.Mimetype, "image/") || file.Size > maxReceiptImageSize {
 		return nil
 	}
 
-	paymentID, err := valueobject.NewPaymentIDFromString(rawPaymentID)
+	var image bytes.Buffer
+	if err := client.GetFileContext(ctx, file.URLPrivateDownload, &image); err != nil {
+		return err
+	}
+	amount, err := h.receiptUsecase.ReadTotal(ctx, &image)
+	if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
+		// レシート以外の画像もアップロードされるので、読み取れなければ何もしない
+		slog.DebugContext(ctx, "no total found in the shared image", slog.String("file", event.FileID))
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	return h.paymentUsecase.Delete(paymentID)
+	_, err = client.PostEphemeralContext(ctx, event.ChannelID, event.UserID, buildReceiptScannedMessage(amount), h.botProfile.msgOption())
+	return err
 }
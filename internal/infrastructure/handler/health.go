@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// healthCheckTimeout はデータベースの死活確認を待つ時間
+const healthCheckTimeout = 2 * time.Second
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthHandler はプロセスの生存確認 (/healthz) と、リクエストを受け付けられるかの確認 (/readyz) に答える
+type HealthHandler struct {
+	database     Pinger
+	shuttingDown atomic.Bool
+}
+
+func NewHealthHandler(database Pinger) *HealthHandler {
+	return &HealthHandler{
+		database: database,
+	}
+}
+
+// ShutDown は終了処理の開始を知らせ、以降の /readyz を失敗させる
+func (h *HealthHandler) ShutDown() {
+	h.shuttingDown.Store(true)
+}
+
+func (h *HealthHandler) ServeLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok"))
+}
+
+func (h *HealthHandler) ServeReadiness(w http.ResponseWriter, r *http.Request) {
+	if h.shuttingDown.Load() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+	if err := h.database.Ping(ctx); err != nil {
+		slog.ErrorContext(ctx, "database is unreachable", slog.Any("error", err))
+		http.Error(w, "database is unreachable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok"))
+}
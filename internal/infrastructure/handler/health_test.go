@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePinger struct {
+	err error
+}
+
+func (p *fakePinger) Ping(ctx context.Context) error {
+	return p.err
+}
+
+func TestHealthHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		pingErr           error
+		shutDown          bool
+		expectedLiveness  int
+		expectedReadiness int
+	}{
+		{
+			name:              "OK: database is reachable",
+			expectedLiveness:  http.StatusOK,
+			expectedReadiness: http.StatusOK,
+		},
+		{
+			name:              "NG: database is unreachable",
+			pingErr:           errors.New("connection refused"),
+			expectedLiveness:  http.StatusOK,
+			expectedReadiness: http.StatusServiceUnavailable,
+		},
+		{
+			name:              "NG: shutting down",
+			shutDown:          true,
+			expectedLiveness:  http.StatusOK,
+			expectedReadiness: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := NewHealthHandler(&fakePinger{err: test.pingErr})
+			if test.shutDown {
+				h.ShutDown()
+			}
+
+			liveness := httptest.NewRecorder()
+			h.ServeLiveness(liveness, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			assert.Equal(t, test.expectedLiveness, liveness.Code)
+
+			readiness := httptest.NewRecorder()
+			h.ServeReadiness(readiness, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, test.expectedReadiness, readiness.Code)
+		})
+	}
+}
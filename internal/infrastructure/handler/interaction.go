@@ -0,0 +1,404 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/slack-go/slack"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/logging"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+const (
+	SlackActionReceiptConfirm = "receipt_confirm"
+	SlackActionReceiptEdit    = "receipt_edit"
+
+	SlackActionPaymentMenu = "payment_menu"
+	SlackPaymentMenuEdit   = "edit"
+
+	SlackActionDuplicateConfirm = "duplicate_confirm"
+	SlackActionDuplicateCancel  = "duplicate_cancel"
+
+	SlackCallbackPaymentShortcut  = "register_payment"
+	SlackCallbackPaymentModal     = "payment_modal"
+	SlackCallbackPaymentEditModal = "payment_edit_modal"
+
+	SlackBlockPaymentAmount         = "amount"
+	SlackBlockPaymentMemo           = "memo"
+	SlackBlockPaymentPaidBy         = "paid_by"
+	SlackBlockPaymentBeneficiaries  = "beneficiaries"
+	SlackActionPaymentAmount        = "amount"
+	SlackActionPaymentMemo          = "memo"
+	SlackActionPaymentPaidBy        = "paid_by"
+	SlackActionPaymentBeneficiaries = "beneficiaries"
+)
+
+// 編集モーダルから元のメッセージを更新するために引き回す情報
+type paymentEditMetadata struct {
+	ChannelID string `json:"channel_id"`
+	MessageTS string `json:"message_ts"`
+	PaymentID string `json:"payment_id"`
+}
+
+type SlackInteractionHandler struct {
+	clients         SlackClients
+	channels        *SlackChannels
+	users           *SlackUsers
+	paymentUsecase  *usecase.PaymentUsecase
+	deliveryUsecase *usecase.DeliveryUsecase
+	botProfile      BotProfile
+}
+
+func NewSlackInteractionHandler(clients SlackClients, channels *SlackChannels, users *SlackUsers, paymentUsecase *usecase.PaymentUsecase, deliveryUsecase *usecase.DeliveryUsecase, botProfile BotProfile) *SlackInteractionHandler {
+	return &SlackInteractionHandler{
+		clients:         clients,
+		channels:        channels,
+		users:           users,
+		paymentUsecase:  paymentUsecase,
+		deliveryUsecase: deliveryUsecase,
+		botProfile:      botProfile,
+	}
+}
+
+func (h *SlackInteractionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "Failed to parse request body", http.StatusBadRequest)
+		return
+	}
+	var callback slack.InteractionCallback
+	if err := json.Unmarshal([]byte(form.Get("payload")), &callback); err != nil {
+		http.Error(w, "Failed to parse interaction payload", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.handleInteraction(r.Context(), callback)
+	if errors.Is(err, errDeliveryInProgress) {
+		// 先の処理が終わらなかったときにやり直せるように、もう一度送ってもらう
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
+		http.Error(w, e.Error(), http.StatusNotFound)
+		return
+	}
+	if e := new(valueobject.ErrorAlreadyExists); errors.As(err, &e) {
+		http.Error(w, e.Error(), http.StatusConflict)
+		return
+	}
+	if e := new(valueobject.ErrorForbidden); errors.As(err, &e) {
+		http.Error(w, e.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to handle interaction", slog.Any("error", err))
+		http.Error(w, "Failed to handle interaction", http.StatusInternalServerError)
+		return
+	}
+	if response != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (h *SlackInteractionHandler) handleInteraction(ctx context.Context, callback slack.InteractionCallback) (*slack.ViewSubmissionResponse, error) {
+	ctx = logging.With(ctx,
+		slog.String("enterprise", callback.Enterprise.ID),
+		slog.String("team", callback.Team.ID),
+		slog.String("channel", callback.Channel.ID),
+		slog.String("user", callback.User.ID),
+		slog.String("interaction_type", string(callback.Type)),
+	)
+
+	var response *slack.ViewSubmissionResponse
+	err := processOnce(ctx, h.deliveryUsecase, deliveryKey("interaction", callback.TriggerID), func() error {
+		var err error
+		response, err = h.dispatchInteraction(ctx, callback)
+		return err
+	})
+	return response, err
+}
+
+func (h *SlackInteractionHandler) dispatchInteraction(ctx context.Context, callback slack.InteractionCallback) (*slack.ViewSubmissionResponse, error) {
+	client, err := h.clients.Client(ctx, interactionTeamID(callback))
+	if err != nil {
+		return nil, err
+	}
+
+	switch callback.Type {
+	case slack.InteractionTypeBlockActions:
+		for _, action := range callback.ActionCallback.BlockActions {
+			if err := h.handleBlockAction(ctx, client, callback, action); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case slack.InteractionTypeMessageAction:
+		if callback.CallbackID != SlackCallbackPaymentShortcut {
+			return nil, nil
+		}
+		_, err := client.OpenViewContext(ctx, callback.TriggerID, buildPaymentModal(callback.Channel.ID, callback.User.ID, valueobject.Yen(0)))
+		return nil, err
+	case slack.InteractionTypeViewSubmission:
+		switch callback.View.CallbackID {
+		case SlackCallbackPaymentModal:
+			return h.handlePaymentModalSubmission(ctx, client, callback)
+		case SlackCallbackPaymentEditModal:
+			return h.handlePaymentEditModalSubmission(ctx, client, callback)
+		default:
+			return nil, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported interaction type: %s", callback.Type)
+	}
+}
+
+func (h *SlackInteractionHandler) handleBlockAction(ctx context.Context, client *slack.Client, callback slack.InteractionCallback, action *slack.BlockAction) error {
+	switch action.ActionID {
+	case SlackActionReceiptConfirm:
+		return h.handleReceiptConfirmAction(ctx, client, callback, action)
+	case SlackActionReceiptEdit:
+		return h.handleReceiptEditAction(ctx, client, callback, action)
+	case SlackActionPaymentMenu:
+		return h.handlePaymentMenuAction(ctx, client, callback, action)
+	case SlackActionDuplicateConfirm:
+		return h.handleDuplicateConfirmAction(ctx, client, callback, action)
+	case SlackActionDuplicateCancel:
+		_, _, err := client.PostMessageContext(ctx, callback.Channel.ID, slack.MsgOptionDeleteOriginal(callback.ResponseURL))
+		return err
+	default:
+		return nil
+	}
+}
+
+func (h *SlackInteractionHandler) handleReceiptConfirmAction(ctx context.Context, client *slack.Client, callback slack.InteractionCallback, action *slack.BlockAction) error {
+	amount, err := valueobject.ParseYen(action.Value)
+	if err != nil {
+		return err
+	}
+
+	eventID, err := h.channels.EventID(ctx, client, interactionTeamID(callback), callback.Channel.ID)
+	if err != nil {
+		return err
+	}
+	payerID, err := h.users.PayerID(ctx, client, callback.User.ID)
+	if err != nil {
+		return err
+	}
+	payment, err := h.paymentUsecase.Create(ctx, eventID, payerID, payerID, amount, "", nil)
+	if e := new(valueobject.ErrorDuplicatePayment); errors.As(err, &e) {
+		// ボタンを押し直したときも、確認のメッセージに置き換える
+		message, err := buildDuplicatePaymentMessage(&entity.Payment{PayerID: payerID, Amount: amount})
+		if err != nil {
+			return err
+		}
+		_, _, err = client.PostMessageContext(ctx, callback.Channel.ID, message, slack.MsgOptionReplaceOriginal(callback.ResponseURL))
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	_, _, err = client.PostMessageContext(ctx, callback.Channel.ID, buildPaymentCreatedMessage(payment), paymentMetadata(payment), h.botProfile.msgOption())
+	if err != nil {
+		return err
+	}
+	_, _, err = client.PostMessageContext(ctx, callback.Channel.ID, slack.MsgOptionDeleteOriginal(callback.ResponseURL))
+	return err
+}
+
+func (h *SlackInteractionHandler) handleDuplicateConfirmAction(ctx context.Context, client *slack.Client, callback slack.InteractionCallback, action *slack.BlockAction) error {
+	pending, err := parseDuplicatePaymentValue(action.Value)
+	if err != nil {
+		return err
+	}
+
+	eventID, err := h.channels.EventID(ctx, client, interactionTeamID(callback), callback.Channel.ID)
+	if err != nil {
+		return err
+	}
+	actorID, err := h.users.PayerID(ctx, client, callback.User.ID)
+	if err != nil {
+		return err
+	}
+	payment, err := h.paymentUsecase.CreateConfirmed(ctx, eventID, actorID, pending.PayerID, pending.Amount, pending.Memo, pending.Beneficiaries)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = client.PostMessageContext(ctx, callback.Channel.ID, buildPaymentCreatedMessage(payment), paymentMetadata(payment), h.botProfile.msgOption())
+	if err != nil {
+		return err
+	}
+	_, _, err = client.PostMessageContext(ctx, callback.Channel.ID, slack.MsgOptionDeleteOriginal(callback.ResponseURL))
+	return err
+}
+
+func (h *SlackInteractionHandler) handleReceiptEditAction(ctx context.Context, client *slack.Client, callback slack.InteractionCallback, action *slack.BlockAction) error {
+	amount, err := valueobject.ParseYen(action.Value)
+	if err != nil {
+		return err
+	}
+	if _, err := client.OpenViewContext(ctx, callback.TriggerID, buildPaymentModal(callback.Channel.ID, callback.User.ID, amount)); err != nil {
+		return err
+	}
+	_, _, err = client.PostMessageContext(ctx, callback.Channel.ID, slack.MsgOptionDeleteOriginal(callback.ResponseURL))
+	return err
+}
+
+func (h *SlackInteractionHandler) handlePaymentModalSubmission(ctx context.Context, client *slack.Client, callback slack.InteractionCallback) (*slack.ViewSubmissionResponse, error) {
+	values := callback.View.State.Values
+	amount, err := valueobject.ParseYen(strings.TrimSuffix(strings.TrimSpace(values[SlackBlockPaymentAmount][SlackActionPaymentAmount].Value), "円"))
+	if err != nil {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			SlackBlockPaymentAmount: "金額は数字で入力してください",
+		}), nil
+	}
+	memo := strings.TrimSpace(values[SlackBlockPaymentMemo][SlackActionPaymentMemo].Value)
+	paidBy := values[SlackBlockPaymentPaidBy][SlackActionPaymentPaidBy].SelectedUser
+
+	channelID := callback.View.PrivateMetadata
+	eventID, err := h.channels.EventID(ctx, client, interactionTeamID(callback), channelID)
+	if errors.Is(err, errChannelUnavailable) {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			SlackBlockPaymentAmount: "チャンネルの情報を取得できませんでした。warikan-botをチャンネルに追加してください",
+		}), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	actorID, err := h.users.PayerID(ctx, client, callback.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	payerID, err := h.users.PayerID(ctx, client, paidBy)
+	if err != nil {
+		return nil, err
+	}
+	beneficiaries, err := h.users.PayerIDs(ctx, client, values[SlackBlockPaymentBeneficiaries][SlackActionPaymentBeneficiaries].SelectedUsers)
+	if err != nil {
+		return nil, err
+	}
+	payment, err := h.paymentUsecase.Create(ctx, eventID, actorID, payerID, amount, memo, beneficiaries)
+	if e := new(valueobject.ErrorInvalid); errors.As(err, &e) && len(beneficiaries) > 0 {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			SlackBlockPaymentBeneficiaries: "割り勘の対象者は参加者から選んでください",
+		}), nil
+	}
+	if e := new(valueobject.ErrorDuplicatePayment); errors.As(err, &e) {
+		// モーダルは閉じて、本人にだけ確認のメッセージを送る
+		message, err := buildDuplicatePaymentMessage(&entity.Payment{PayerID: payerID, Amount: amount, Memo: memo, Beneficiaries: beneficiaries})
+		if err != nil {
+			return nil, err
+		}
+		_, err = client.PostEphemeralContext(ctx, channelID, actorID.UserID(), message)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, err = client.PostMessageContext(ctx, channelID, buildPaymentCreatedMessage(payment), paymentMetadata(payment), h.botProfile.msgOption())
+	return nil, err
+}
+
+func (h *SlackInteractionHandler) handlePaymentMenuAction(ctx context.Context, client *slack.Client, callback slack.InteractionCallback, action *slack.BlockAction) error {
+	menu, paymentID, err := parsePaymentMenuValue(action.SelectedOption.Value)
+	if err != nil {
+		return err
+	}
+
+	switch menu {
+	case SlackPaymentMenuEdit:
+		payment, err := h.paymentUsecase.Find(ctx, paymentID)
+		if err != nil {
+			return err
+		}
+		metadata, err := json.Marshal(paymentEditMetadata{
+			ChannelID: callback.Channel.ID,
+			MessageTS: callback.Message.Timestamp,
+			PaymentID: paymentID.String(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = client.OpenViewContext(ctx, callback.TriggerID, buildPaymentEditModal(payment, string(metadata)))
+		return err
+	default:
+		return nil
+	}
+}
+
+func (h *SlackInteractionHandler) handlePaymentEditModalSubmission(ctx context.Context, client *slack.Client, callback slack.InteractionCallback) (*slack.ViewSubmissionResponse, error) {
+	var metadata paymentEditMetadata
+	if err := json.Unmarshal([]byte(callback.View.PrivateMetadata), &metadata); err != nil {
+		return nil, err
+	}
+	paymentID, err := valueobject.NewPaymentIDFromString(metadata.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	values := callback.View.State.Values
+	amount, err := valueobject.ParseYen(strings.TrimSuffix(strings.TrimSpace(values[SlackBlockPaymentAmount][SlackActionPaymentAmount].Value), "円"))
+	if err != nil {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			SlackBlockPaymentAmount: "金額は数字で入力してください",
+		}), nil
+	}
+	memo := strings.TrimSpace(values[SlackBlockPaymentMemo][SlackActionPaymentMemo].Value)
+
+	editorID, err := h.users.PayerID(ctx, client, callback.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	payment, err := h.paymentUsecase.Update(ctx, paymentID, editorID, amount, memo)
+	if e := new(valueobject.ErrorForbidden); errors.As(err, &e) {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			SlackBlockPaymentAmount: "編集できるのは立て替えた人か幹事だけです",
+		}), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, _, err = client.UpdateMessageContext(ctx, metadata.ChannelID, metadata.MessageTS, buildPaymentCreatedMessage(payment), paymentMetadata(payment))
+	return nil, err
+}
+
+// interactionTeamID はリクエストが届いたワークスペースを返す
+func interactionTeamID(callback slack.InteractionCallback) valueobject.TeamID {
+	return valueobject.NewEnterpriseTeamID(callback.Enterprise.ID, callback.Team.ID)
+}
+
+func buildPaymentMenuValue(menu string, paymentID valueobject.PaymentID) string {
+	return menu + ":" + paymentID.String()
+}
+
+func parsePaymentMenuValue(value string) (string, valueobject.PaymentID, error) {
+	menu, rawPaymentID, ok := strings.Cut(value, ":")
+	if !ok {
+		return "", valueobject.PaymentID{}, fmt.Errorf("invalid payment menu value: %s", value)
+	}
+	paymentID, err := valueobject.NewPaymentIDFromString(rawPaymentID)
+	if err != nil {
+		return "", valueobject.PaymentID{}, err
+	}
+	return menu, paymentID, nil
+}
@@ -0,0 +1,358 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/text/width"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/logging"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/metrics"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+// LINEから届くWebhookの本文の上限
+const maxLINERequestBodySize = 1 << 20
+
+// https://developers.line.biz/ja/reference/messaging-api/#source-user
+const (
+	lineSourceUser  = "user"
+	lineSourceGroup = "group"
+	lineSourceRoom  = "room"
+)
+
+// 二重登録の確認ボタンから届くポストバックの操作
+const lineActionDuplicateConfirm = "duplicate"
+
+type lineSource struct {
+	Type    string `json:"type"`
+	UserID  string `json:"userId"`
+	GroupID string `json:"groupId"`
+	RoomID  string `json:"roomId"`
+}
+
+// id は割り勘をまとめる単位を返す。グループやトークルームごとに、1対1のトークではユーザーごとにまとめる
+func (s lineSource) id() string {
+	switch s.Type {
+	case lineSourceGroup:
+		return s.GroupID
+	case lineSourceRoom:
+		return s.RoomID
+	default:
+		return s.UserID
+	}
+}
+
+type lineEvent struct {
+	Type           string     `json:"type"`
+	WebhookEventID string     `json:"webhookEventId"`
+	ReplyToken     string     `json:"replyToken"`
+	Source         lineSource `json:"source"`
+	Message        struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"message"`
+	Postback struct {
+		Data string `json:"data"`
+	} `json:"postback"`
+}
+
+type lineWebhook struct {
+	Events []lineEvent `json:"events"`
+}
+
+// LINEWebhookHandler はLINEのグループで送られたメッセージを、Slackと同じユースケースで処理する
+// 割り勘と関係のない会話も届くので、決まった書き方のメッセージにだけ返信する
+type LINEWebhookHandler struct {
+	channelSecret   string
+	client          *LINEClient
+	paymentUsecase  *usecase.PaymentUsecase
+	deliveryUsecase *usecase.DeliveryUsecase
+	workers         *WorkerPool
+	metrics         *metrics.Metrics
+	payPattern      *regexp.Regexp
+	joinPattern     *regexp.Regexp
+	settlePattern   *regexp.Regexp
+	historyPattern  *regexp.Regexp
+	helpPattern     *regexp.Regexp
+}
+
+func NewLINEWebhookHandler(channelSecret string, client *LINEClient, paymentUsecase *usecase.PaymentUsecase, deliveryUsecase *usecase.DeliveryUsecase, workers *WorkerPool, metrics *metrics.Metrics) *LINEWebhookHandler {
+	return &LINEWebhookHandler{
+		channelSecret:   channelSecret,
+		client:          client,
+		paymentUsecase:  paymentUsecase,
+		deliveryUsecase: deliveryUsecase,
+		workers:         workers,
+		metrics:         metrics,
+		payPattern:      regexp.MustCompile(`(?s)^割り?勘\s+((?:\d{1,3}(?:,\d{3})+|\d+))円?(?:\s+(.+))?$`),
+		joinPattern:     regexp.MustCompile(`^参加(?:\s+(\d+)%?)?$`),
+		settlePattern:   regexp.MustCompile(`^(?:精算|清算|集計)$`),
+		historyPattern:  regexp.MustCompile(`^履歴$`),
+		helpPattern:     regexp.MustCompile(`^(?:割り?勘|(?:割り?勘\s*)?(?:ヘルプ|使い方))$`),
+	}
+}
+
+func (h *LINEWebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxLINERequestBodySize))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if !h.verify(r.Header.Get("X-Line-Signature"), body) {
+		slog.WarnContext(ctx, "rejected line request with invalid signature")
+		http.Error(w, "Invalid request", http.StatusUnauthorized)
+		return
+	}
+
+	var webhook lineWebhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		http.Error(w, "Failed to parse LINE webhook", http.StatusBadRequest)
+		return
+	}
+	// 返信はMessaging APIで送るので、Webhookにはすぐに応答する
+	for _, event := range webhook.Events {
+		h.submit(ctx, event)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// verify はチャネルシークレットで計算した署名と一致するか確かめる
+// https://developers.line.biz/ja/docs/messaging-api/receiving-messages/#verifying-signatures
+func (h *LINEWebhookHandler) verify(signature string, body []byte) bool {
+	if signature == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(h.channelSecret))
+	mac.Write(body)
+	return hmac.Equal([]byte(signature), []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil))))
+}
+
+func (h *LINEWebhookHandler) submit(ctx context.Context, event lineEvent) {
+	subcommand := h.eventSubcommand(event)
+	if subcommand == "" {
+		return
+	}
+	ctx = logging.With(ctx,
+		slog.String("platform", "line"),
+		slog.String("source", event.Source.id()),
+		slog.String("user", event.Source.UserID),
+		slog.String("subcommand", subcommand),
+	)
+	if !h.workers.Submit(func() { h.processEvent(ctx, event, subcommand) }) {
+		slog.WarnContext(ctx, "rejected line event because the queue is full")
+	}
+}
+
+func (h *LINEWebhookHandler) processEvent(ctx context.Context, event lineEvent, subcommand string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commandTimeout)
+	defer cancel()
+
+	start := time.Now()
+	// 応答が遅れると、LINEは同じイベントを再送してくる
+	err := processOnce(ctx, h.deliveryUsecase, deliveryKey("line", event.WebhookEventID), func() error {
+		return h.handleEvent(ctx, event, subcommand)
+	})
+	if errors.Is(err, errDeliveryInProgress) {
+		// 結果は先に受け取ったほうで返す
+		return
+	}
+	h.metrics.CommandHandled(subcommand, err)
+	if err == nil {
+		slog.InfoContext(ctx, "handled line event", slog.Duration("duration", time.Since(start)))
+		return
+	}
+	slog.ErrorContext(ctx, "failed to handle line event", slog.Any("error", err))
+	if err := h.client.Reply(ctx, event.ReplyToken, buildLINEErrorMessage(err)); err != nil {
+		slog.ErrorContext(ctx, "failed to reply to line event", slog.Any("error", err))
+	}
+}
+
+// eventSubcommand は割り勘の操作を表すイベントなら、その操作を返す。関係のないイベントなら空を返す
+func (h *LINEWebhookHandler) eventSubcommand(event lineEvent) string {
+	switch {
+	case event.Type == "message" && event.Message.Type == "text":
+		return h.subcommand(event.Message.Text)
+	case event.Type == "postback":
+		return subcommandPay
+	default:
+		return ""
+	}
+}
+
+// subcommand はメッセージがどの操作かを判定する。全角の数字や空白で書かれていても受け付ける
+func (h *LINEWebhookHandler) subcommand(text string) string {
+	text = normalizeLINEText(text)
+	switch {
+	case h.payPattern.MatchString(text):
+		return subcommandPay
+	case h.joinPattern.MatchString(text):
+		return subcommandJoin
+	case h.settlePattern.MatchString(text):
+		return subcommandSettle
+	case h.historyPattern.MatchString(text):
+		return subcommandHistory
+	case h.helpPattern.MatchString(text):
+		return subcommandHelp
+	default:
+		return ""
+	}
+}
+
+func normalizeLINEText(text string) string {
+	return strings.TrimSpace(width.Fold.String(text))
+}
+
+func (h *LINEWebhookHandler) handleEvent(ctx context.Context, event lineEvent, subcommand string) error {
+	if event.Source.UserID == "" {
+		// 送った人がわからないと、誰の立替えか決められない
+		return h.client.Reply(ctx, event.ReplyToken, buildLINEUnknownUserMessage())
+	}
+	eventID := valueobject.NewLINEEventID(event.Source.id())
+	payerID := valueobject.NewLINEPayerID(event.Source.UserID)
+	if event.Type == "postback" {
+		return h.handlePostback(ctx, event, eventID, payerID)
+	}
+	text := normalizeLINEText(event.Message.Text)
+
+	switch subcommand {
+	case subcommandPay:
+		match := h.payPattern.FindStringSubmatch(text)
+		amount, err := valueobject.ParseYen(match[1])
+		if err != nil {
+			return err
+		}
+		memo := strings.TrimSpace(match[2])
+		payment, err := h.paymentUsecase.Create(ctx, eventID, payerID, payerID, amount, memo, nil)
+		if e := new(valueobject.ErrorDuplicatePayment); errors.As(err, &e) {
+			name := h.displayName(ctx, event.Source, payerID)
+			return h.client.Reply(ctx, event.ReplyToken, buildLINEDuplicatePaymentMessage(name, payerID, amount, memo))
+		}
+		if err != nil {
+			return err
+		}
+		return h.client.Reply(ctx, event.ReplyToken, buildLINEPaymentCreatedMessage(payment, h.displayName(ctx, event.Source, payerID)))
+
+	case subcommandJoin:
+		weight := valueobject.Percent(100)
+		match := h.joinPattern.FindStringSubmatch(text)
+		if match[1] != "" {
+			w, err := valueobject.ParsePercent(match[1])
+			if err != nil {
+				return err
+			}
+			weight = w
+		}
+		name := h.displayName(ctx, event.Source, payerID)
+		_, err := h.paymentUsecase.Join(ctx, eventID, payerID, weight)
+		if e := new(valueobject.ErrorAlreadyExists); errors.As(err, &e) {
+			if match[1] == "" {
+				return h.client.Reply(ctx, event.ReplyToken, buildLINEPayerAlreadyJoinedMessage(name))
+			}
+			// 参加済みで重みが指定された場合は重みを変更する
+			payer, err := h.paymentUsecase.ChangeWeight(ctx, eventID, payerID, weight)
+			if err != nil {
+				return err
+			}
+			return h.client.Reply(ctx, event.ReplyToken, buildLINEPayerWeightChangedMessage(payer, name))
+		}
+		if err != nil {
+			return err
+		}
+		return h.client.Reply(ctx, event.ReplyToken, buildLINEPayerJoinedMessage(name))
+
+	case subcommandSettle:
+		settlement, err := h.paymentUsecase.Settle(ctx, eventID)
+		if err != nil {
+			return err
+		}
+		var payerIDs []valueobject.PayerID
+		for payerID := range settlement.AmountsAdvanced {
+			payerIDs = append(payerIDs, payerID)
+		}
+		for _, payer := range settlement.Payers {
+			payerIDs = append(payerIDs, payer.ID)
+		}
+		names := h.displayNames(ctx, event.Source, payerIDs)
+		return h.client.Reply(ctx, event.ReplyToken, buildLINESettlementMessage(settlement, names))
+
+	case subcommandHistory:
+		logs, err := h.paymentUsecase.History(ctx, eventID)
+		if err != nil {
+			return err
+		}
+		var payerIDs []valueobject.PayerID
+		for _, log := range logs {
+			payerIDs = append(payerIDs, log.ActorID, log.PayerID)
+		}
+		names := h.displayNames(ctx, event.Source, payerIDs)
+		return h.client.Reply(ctx, event.ReplyToken, buildLINEHistoryMessage(logs, names))
+
+	case subcommandHelp:
+		return h.client.Reply(ctx, event.ReplyToken, buildLINEHelpMessage())
+
+	default:
+		return fmt.Errorf("unsupported line subcommand: %s", subcommand)
+	}
+}
+
+// handlePostback は二重登録の確認で「もう一度登録する」が押されたときに、立替えを登録する
+func (h *LINEWebhookHandler) handlePostback(ctx context.Context, event lineEvent, eventID valueobject.EventID, actorID valueobject.PayerID) error {
+	data, err := url.ParseQuery(event.Postback.Data)
+	if err != nil {
+		return valueobject.NewErrorInvalid("failed to parse postback", err)
+	}
+	if data.Get("action") != lineActionDuplicateConfirm {
+		return fmt.Errorf("unsupported line postback: %s", event.Postback.Data)
+	}
+	// グループでは誰でもボタンを押せるので、立て替えた本人だけが登録できるようにする
+	payerID := valueobject.NewPayerID(data.Get("payer"))
+	if payerID != actorID {
+		return valueobject.NewErrorForbidden("only the payer can confirm the payment", nil)
+	}
+	amount, err := valueobject.ParseYen(data.Get("amount"))
+	if err != nil {
+		return err
+	}
+	payment, err := h.paymentUsecase.CreateConfirmed(ctx, eventID, actorID, payerID, amount, data.Get("memo"), nil)
+	if err != nil {
+		return err
+	}
+	return h.client.Reply(ctx, event.ReplyToken, buildLINEPaymentCreatedMessage(payment, h.displayName(ctx, event.Source, payerID)))
+}
+
+// displayNames は利用者の表示名をまとめて調べる。LINEのメッセージではメンションを書けないので、名前で表示する
+func (h *LINEWebhookHandler) displayNames(ctx context.Context, source lineSource, payerIDs []valueobject.PayerID) map[valueobject.PayerID]string {
+	names := make(map[valueobject.PayerID]string, len(payerIDs))
+	for _, payerID := range payerIDs {
+		if _, ok := names[payerID]; ok || payerID.IsUnknown() {
+			continue
+		}
+		names[payerID] = h.displayName(ctx, source, payerID)
+	}
+	return names
+}
+
+func (h *LINEWebhookHandler) displayName(ctx context.Context, source lineSource, payerID valueobject.PayerID) string {
+	name, err := h.client.DisplayName(ctx, source, payerID.UserID())
+	if err != nil || name == "" {
+		// グループから抜けた人は調べられない
+		slog.WarnContext(ctx, "failed to get line display name", slog.String("payer_id", payerID.String()), slog.Any("error", err))
+		return unknownLINEUserName
+	}
+	return name
+}
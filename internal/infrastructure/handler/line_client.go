@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const lineAPIURL = "https://api.line.me"
+
+// LINEClient はMessaging APIのうち、返信とプロフィールの取得だけを呼び出す
+// https://developers.line.biz/ja/reference/messaging-api/
+type LINEClient struct {
+	httpClient  *http.Client
+	accessToken string
+	apiURL      string
+}
+
+func NewLINEClient(httpClient *http.Client, accessToken string) *LINEClient {
+	return &LINEClient{
+		httpClient:  httpClient,
+		accessToken: accessToken,
+		apiURL:      lineAPIURL,
+	}
+}
+
+type lineReplyRequest struct {
+	ReplyToken string        `json:"replyToken"`
+	Messages   []lineMessage `json:"messages"`
+}
+
+// Reply はWebhookで受け取った応答トークンを使って返信する。応答トークンは1回だけ使える
+func (c *LINEClient) Reply(ctx context.Context, replyToken string, messages ...lineMessage) error {
+	body, err := json.Marshal(lineReplyRequest{ReplyToken: replyToken, Messages: messages})
+	if err != nil {
+		return fmt.Errorf("failed to marshal line reply: %w", err)
+	}
+	return c.do(ctx, http.MethodPost, "/v2/bot/message/reply", bytes.NewReader(body), nil)
+}
+
+type lineProfile struct {
+	DisplayName string `json:"displayName"`
+}
+
+// DisplayName はsourceのグループやトークルームでのユーザーの表示名を返す
+func (c *LINEClient) DisplayName(ctx context.Context, source lineSource, userID string) (string, error) {
+	path := "/v2/bot/profile/" + url.PathEscape(userID)
+	switch source.Type {
+	case lineSourceGroup:
+		path = fmt.Sprintf("/v2/bot/group/%s/member/%s", url.PathEscape(source.GroupID), url.PathEscape(userID))
+	case lineSourceRoom:
+		path = fmt.Sprintf("/v2/bot/room/%s/member/%s", url.PathEscape(source.RoomID), url.PathEscape(userID))
+	}
+	var profile lineProfile
+	if err := c.do(ctx, http.MethodGet, path, nil, &profile); err != nil {
+		return "", err
+	}
+	return profile.DisplayName, nil
+}
+
+func (c *LINEClient) do(ctx context.Context, method string, path string, body io.Reader, result any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call line api: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to call line api %s: %s: %s", path, resp.Status, message)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode line api response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/presenter"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+// https://developers.line.biz/ja/reference/messaging-api/#message-objects
+type lineMessage struct {
+	Type     string             `json:"type"`
+	Text     string             `json:"text,omitempty"`
+	AltText  string             `json:"altText,omitempty"`
+	Contents *lineFlexComponent `json:"contents,omitempty"`
+}
+
+// lineFlexComponent はFlex Messageのバブル・ボックス・テキストなどをまとめて表す
+// https://developers.line.biz/ja/reference/messaging-api/#flex-message
+type lineFlexComponent struct {
+	Type     string              `json:"type"`
+	Layout   string              `json:"layout,omitempty"`
+	Text     string              `json:"text,omitempty"`
+	Size     string              `json:"size,omitempty"`
+	Weight   string              `json:"weight,omitempty"`
+	Color    string              `json:"color,omitempty"`
+	Align    string              `json:"align,omitempty"`
+	Wrap     bool                `json:"wrap,omitempty"`
+	Flex     *int                `json:"flex,omitempty"`
+	Margin   string              `json:"margin,omitempty"`
+	Spacing  string              `json:"spacing,omitempty"`
+	Style    string              `json:"style,omitempty"`
+	Action   *lineAction         `json:"action,omitempty"`
+	Header   *lineFlexComponent  `json:"header,omitempty"`
+	Body     *lineFlexComponent  `json:"body,omitempty"`
+	Footer   *lineFlexComponent  `json:"footer,omitempty"`
+	Contents []lineFlexComponent `json:"contents,omitempty"`
+}
+
+type lineAction struct {
+	Type        string `json:"type"`
+	Label       string `json:"label"`
+	Data        string `json:"data,omitempty"`
+	DisplayText string `json:"displayText,omitempty"`
+}
+
+const (
+	// 表示名を調べられなかった人
+	unknownLINEUserName = "不明なメンバー"
+
+	lineColorMuted = "#888888"
+
+	// 1つのメッセージに載せる履歴の数。テキストメッセージの文字数の上限に収まるようにする
+	maxLINEHistoryEntries = 30
+	// ポストバックのデータに入れられる文字数の上限
+	maxLINEPostbackDataLength = 300
+)
+
+func newLINEText(text string) lineMessage {
+	return lineMessage{Type: "text", Text: text}
+}
+
+func newLINEFlex(altText string, bubble lineFlexComponent) lineMessage {
+	return lineMessage{Type: "flex", AltText: altText, Contents: &bubble}
+}
+
+func newLINEBox(layout string, contents ...lineFlexComponent) lineFlexComponent {
+	return lineFlexComponent{Type: "box", Layout: layout, Contents: contents}
+}
+
+func newLINEFlexText(text string) lineFlexComponent {
+	return lineFlexComponent{Type: "text", Text: text, Wrap: true}
+}
+
+// newLINERow は左に名前、右に金額や割合を並べた行を作る
+func newLINERow(label string, value string) lineFlexComponent {
+	labelFlex, valueFlex := 3, 2
+	return newLINEBox("horizontal",
+		lineFlexComponent{Type: "text", Text: label, Size: "sm", Wrap: true, Flex: &labelFlex},
+		lineFlexComponent{Type: "text", Text: value, Size: "sm", Align: "end", Flex: &valueFlex},
+	)
+}
+
+func newLINESection(title string) lineFlexComponent {
+	return lineFlexComponent{Type: "text", Text: title, Size: "sm", Weight: "bold", Color: lineColorMuted, Margin: "lg", Wrap: true}
+}
+
+func lineName(names map[valueobject.PayerID]string, payerID valueobject.PayerID) string {
+	if name, ok := names[payerID]; ok {
+		return name
+	}
+	return unknownLINEUserName
+}
+
+func buildLINEPaymentCreatedMessage(payment *entity.Payment, name string) lineMessage {
+	text := fmt.Sprintf("%sさんが%s立て替えました！", name, payment.Amount.String())
+	if payment.Memo != "" {
+		text += "\n" + payment.Memo
+	}
+	return newLINEText(text)
+}
+
+// buildLINEDuplicatePaymentMessage は直前に同じ立替えが登録されていたときに、本当に登録するか確かめる
+// まだ登録していない立替えはポストバックのデータに持たせる。入りきらないメモは切り詰める
+func buildLINEDuplicatePaymentMessage(name string, payerID valueobject.PayerID, amount valueobject.Yen, memo string) lineMessage {
+	text := fmt.Sprintf("%sさんの%sの立替えは、直前にも登録されています！\n二重に送信されたのでなければ、もう一度登録してください", name, amount.String())
+	if memo != "" {
+		text += "\n" + memo
+	}
+	data := url.Values{
+		"action": {lineActionDuplicateConfirm},
+		"payer":  {payerID.String()},
+		"amount": {strconv.FormatInt(amount.Int64(), 10)},
+		"memo":   {memo},
+	}
+	// URLエンコードすると長さが変わるので、エンコードしたものが収まるまで1文字ずつ削る
+	for len(data.Encode()) > maxLINEPostbackDataLength {
+		_, size := utf8.DecodeLastRuneInString(memo)
+		memo = memo[:len(memo)-size]
+		data.Set("memo", memo)
+	}
+	return newLINEFlex("直前にも同じ立替えが登録されています", lineFlexComponent{
+		Type: "bubble",
+		Body: &lineFlexComponent{Type: "box", Layout: "vertical", Contents: []lineFlexComponent{newLINEFlexText(text)}},
+		Footer: &lineFlexComponent{Type: "box", Layout: "vertical", Contents: []lineFlexComponent{
+			{
+				Type:  "button",
+				Style: "primary",
+				Action: &lineAction{
+					Type:        "postback",
+					Label:       "もう一度登録する",
+					Data:        data.Encode(),
+					DisplayText: "もう一度登録する",
+				},
+			},
+		}},
+	})
+}
+
+func buildLINEPayerJoinedMessage(name string) lineMessage {
+	return newLINEText(fmt.Sprintf("%sさんが割り勘に参加します！", name))
+}
+
+func buildLINEPayerWeightChangedMessage(payer *entity.Payer, name string) lineMessage {
+	return newLINEText(fmt.Sprintf("%sさんの負担割合を%d%%に変更しました！", name, payer.Weight.Int()))
+}
+
+func buildLINEPayerAlreadyJoinedMessage(name string) lineMessage {
+	return newLINEText(fmt.Sprintf("%sさんはすでに割り勘に参加しています！", name))
+}
+
+// buildLINESettlementMessage は集計結果をFlex Messageにする。namesには登場する人の表示名を入れておく
+func buildLINESettlementMessage(settlement *usecase.Settlement, names map[valueobject.PayerID]string) lineMessage {
+	view := presenter.NewSettlementView(settlement)
+	name := func(payerID valueobject.PayerID) string { return lineName(names, payerID) }
+	var body []lineFlexComponent
+	for _, section := range view.Sections {
+		body = append(body, newLINESection(section.Heading))
+		if len(section.Lines) == 0 && section.Empty != "" {
+			body = append(body, newLINEFlexText(section.Empty))
+		}
+		for _, line := range section.Lines {
+			body = append(body, newLINERow(line.Label(name), line.Value))
+		}
+	}
+
+	return newLINEFlex(fmt.Sprintf("%s: 合計%s", view.Title, view.Total.String()), lineFlexComponent{
+		Type: "bubble",
+		Header: &lineFlexComponent{Type: "box", Layout: "vertical", Contents: []lineFlexComponent{
+			{Type: "text", Text: view.Title, Size: "xl", Weight: "bold"},
+		}},
+		Body: &lineFlexComponent{Type: "box", Layout: "vertical", Spacing: "sm", Contents: body},
+	})
+}
+
+func buildLINEHistoryMessage(logs []*entity.AuditLog, names map[valueobject.PayerID]string) lineMessage {
+	if len(logs) == 0 {
+		return newLINEText("変更履歴\nまだ履歴はありません")
+	}
+	if len(logs) > maxLINEHistoryEntries {
+		logs = logs[len(logs)-maxLINEHistoryEntries:]
+	}
+	var mention presenter.Namer = func(payerID valueobject.PayerID) string { return lineName(names, payerID) }
+	lines := []string{"変更履歴"}
+	for _, log := range logs {
+		lines = append(lines, fmt.Sprintf("%s %s%s", log.CreatedAt.Format("01/02 15:04"), mention(log.ActorID), describeAuditLog(log, mention)))
+	}
+	return newLINEText(strings.Join(lines, "\n"))
+}
+
+func buildLINEHelpMessage() lineMessage {
+	return newLINEText(strings.Join([]string{
+		"グループで割り勘の計算ができます",
+		"支払いの集計はグループごとに行われます",
+		"",
+		"立替えを登録する: 割り勘 3000 居酒屋",
+		"割り勘に参加する: 参加（重みを付けるときは 参加 150%）",
+		"清算方法を表示する: 精算",
+		"変更履歴を表示する: 履歴",
+		"この使い方を表示する: ヘルプ",
+	}, "\n"))
+}
+
+func buildLINEUnknownUserMessage() lineMessage {
+	return newLINEText("送った人がわからないため、割り勘に使えませんでした\nLINEのアプリを最新にしてから、もう一度お試しください")
+}
+
+func buildLINEErrorMessage(err error) lineMessage {
+	text := "エラーが発生しました\nしばらくしてからもう一度お試しください"
+	if e := new(valueobject.ErrorInvalid); errors.As(err, &e) {
+		text = "金額や割合の書き方が正しくありません！\n使い方は「ヘルプ」と送るとご覧になれます"
+	}
+	if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
+		text = "対象の立替えや参加者が見つかりませんでした"
+	}
+	if e := new(valueobject.ErrorForbidden); errors.As(err, &e) {
+		text = "この操作は立て替えた本人だけができます"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		text = "処理に時間がかかりすぎたので中断しました\nもう一度お試しください"
+	}
+	return newLINEText(text)
+}
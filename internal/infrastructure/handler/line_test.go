@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/memory"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+const lineTestChannelSecret = "line-secret"
+
+// fakeLINEAPI は返信を記録し、グループのメンバーの表示名を返すMessaging APIの代わり
+type fakeLINEAPI struct {
+	mu      sync.Mutex
+	replies []lineReplyRequest
+}
+
+func (f *fakeLINEAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	names := map[string]string{"U1": "太郎", "U2": "花子"}
+	switch {
+	case r.URL.Path == "/v2/bot/message/reply":
+		var reply lineReplyRequest
+		json.NewDecoder(r.Body).Decode(&reply)
+		f.mu.Lock()
+		f.replies = append(f.replies, reply)
+		f.mu.Unlock()
+		w.Write([]byte(`{}`))
+	case strings.HasPrefix(r.URL.Path, "/v2/bot/group/G1/member/"):
+		name, ok := names[path.Base(r.URL.Path)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"displayName":%q}`, name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newLINERequest(secret string, body string) *http.Request {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	r := httptest.NewRequest(http.MethodPost, "/line/webhook", strings.NewReader(body))
+	r.Header.Set("X-Line-Signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func newLINEMessageEvent(eventID string, userID string, text string) string {
+	return fmt.Sprintf(`{"type":"message","webhookEventId":%q,"replyToken":"reply-%s","source":{"type":"group","groupId":"G1","userId":%q},"message":{"type":"text","text":%q}}`, eventID, eventID, userID, text)
+}
+
+func TestLINEWebhookHandler_Verify(t *testing.T) {
+	t.Parallel()
+
+	body := `{"destination":"U0BOT","events":[]}`
+	tests := []struct {
+		name           string
+		request        *http.Request
+		expectedStatus int
+	}{
+		{
+			name:           "OK: verified",
+			request:        newLINERequest(lineTestChannelSecret, body),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "NG: signed with another secret",
+			request:        newLINERequest("another-secret", body),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "NG: missing signature",
+			request:        httptest.NewRequest(http.MethodPost, "/line/webhook", strings.NewReader(body)),
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := NewLINEWebhookHandler(lineTestChannelSecret, NewLINEClient(http.DefaultClient, "token"), nil, nil, NewWorkerPool(1, 1), nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, test.request)
+			assert.Equal(t, test.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestLINEWebhookHandler_Subcommand(t *testing.T) {
+	t.Parallel()
+
+	h := NewLINEWebhookHandler(lineTestChannelSecret, nil, nil, nil, nil, nil)
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{text: "割り勘 3000", expected: subcommandPay},
+		{text: "割勘　３，０００円　居酒屋", expected: subcommandPay},
+		{text: "参加", expected: subcommandJoin},
+		{text: "参加 150%", expected: subcommandJoin},
+		{text: "精算", expected: subcommandSettle},
+		{text: "割り勘", expected: subcommandHelp},
+		{text: "今日の割り勘 3000 だよね", expected: ""},
+		{text: "精算してね", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, h.subcommand(test.text))
+		})
+	}
+}
+
+func TestLINEWebhookHandler_Settle(t *testing.T) {
+	t.Parallel()
+
+	api := &fakeLINEAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	client := NewLINEClient(server.Client(), "token")
+	client.apiURL = server.URL
+
+	store := memory.NewStore()
+	// 1つずつ順に処理されるようにする
+	workers := NewWorkerPool(1, 10)
+	h := NewLINEWebhookHandler(lineTestChannelSecret, client, usecase.NewPayment(store, nil), usecase.NewDelivery(store), workers, nil)
+
+	events := []string{
+		newLINEMessageEvent("E1", "U1", "参加"),
+		newLINEMessageEvent("E2", "U2", "参加"),
+		newLINEMessageEvent("E3", "U1", "ところで今日は何時集合？"),
+		newLINEMessageEvent("E4", "U1", "割り勘 ３０００ 居酒屋"),
+		newLINEMessageEvent("E5", "U2", "精算"),
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newLINERequest(lineTestChannelSecret, `{"events":[`+strings.Join(events, ",")+`]}`))
+	require.Equal(t, http.StatusOK, w.Code)
+	// 同じイベントが再送されても、二重に登録しない
+	h.ServeHTTP(httptest.NewRecorder(), newLINERequest(lineTestChannelSecret, `{"events":[`+events[3]+`]}`))
+	require.NoError(t, workers.Close(t.Context()))
+
+	// 関係のない会話には返信しない
+	require.Len(t, api.replies, 4)
+	assert.Equal(t, "太郎さんが割り勘に参加します！", api.replies[0].Messages[0].Text)
+	assert.Equal(t, "太郎さんが3,000円立て替えました！\n居酒屋", api.replies[2].Messages[0].Text)
+
+	settlement := api.replies[3]
+	assert.Equal(t, "reply-E5", settlement.ReplyToken)
+	require.Len(t, settlement.Messages, 1)
+	assert.Equal(t, "flex", settlement.Messages[0].Type)
+	assert.Equal(t, "集計結果: 合計3,000円", settlement.Messages[0].AltText)
+	raw, err := json.Marshal(settlement.Messages[0].Contents)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"text":"花子 → 太郎"`)
+	assert.Contains(t, string(raw), `"text":"1,500円"`)
+}
+
+func TestBuildLINEDuplicatePaymentMessage(t *testing.T) {
+	t.Parallel()
+
+	payerID := valueobject.NewLINEPayerID("U0123456789abcdef0123456789abcdef")
+	tests := []struct {
+		name         string
+		memo         string
+		expectedMemo string
+	}{
+		{
+			name:         "OK: short memo is kept",
+			memo:         "居酒屋",
+			expectedMemo: "居酒屋",
+		},
+		{
+			name:         "OK: long memo is truncated to fit in the postback data",
+			memo:         strings.Repeat("居酒屋", 50),
+			expectedMemo: strings.Repeat("居酒屋", 8),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			amount, err := valueobject.NewYen(3000)
+			require.NoError(t, err)
+
+			message := buildLINEDuplicatePaymentMessage("太郎", payerID, amount, test.memo)
+
+			action := message.Contents.Footer.Contents[0].Action
+			require.NotNil(t, action)
+			assert.LessOrEqual(t, len(action.Data), maxLINEPostbackDataLength)
+			data, err := url.ParseQuery(action.Data)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedMemo, data.Get("memo"))
+			assert.Equal(t, payerID.String(), data.Get("payer"))
+			assert.Equal(t, "3000", data.Get("amount"))
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+const (
+	slackAuthorizeURL = "https://slack.com/oauth/v2/authorize"
+	// warikan-botが使う権限。Slackアプリの設定と合わせておく
+	// channels:readとgroups:readは、Slackコネクトで共有されたチャンネルを作ったワークスペースを調べるのに使う
+	slackBotScopes = "commands,chat:write,chat:write.customize,files:read,metadata.message:read,channels:read,groups:read,users:read"
+
+	// 追加を始めたブラウザと、Slackから戻ってきたブラウザが同じか確かめるためのクッキー
+	oauthStateCookie = "warikan_oauth_state"
+	// Slackの画面で追加を許可するまでに待つ時間
+	oauthStateTTL = 10 * time.Minute
+)
+
+var oauthResultTemplate = template.Must(template.New("oauth").Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head><meta charset="utf-8"><title>warikan-bot</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+</body>
+</html>
+`))
+
+// SlackOAuthHandler はワークスペースにwarikan-botを追加するためのOAuthのやり取りを行う
+type SlackOAuthHandler struct {
+	clientID            string
+	clientSecret        string
+	redirectURL         string
+	installationUsecase *usecase.InstallationUsecase
+	httpClient          *http.Client
+	now                 func() time.Time
+}
+
+// redirectURLが空のときは、Slackアプリに設定したものが使われる
+func NewSlackOAuthHandler(clientID string, clientSecret string, redirectURL string, installationUsecase *usecase.InstallationUsecase, httpClient *http.Client) *SlackOAuthHandler {
+	return &SlackOAuthHandler{
+		clientID:            clientID,
+		clientSecret:        clientSecret,
+		redirectURL:         redirectURL,
+		installationUsecase: installationUsecase,
+		httpClient:          httpClient,
+		now:                 time.Now,
+	}
+}
+
+// ServeInstall はSlackの追加画面に移動させる
+func (h *SlackOAuthHandler) ServeInstall(w http.ResponseWriter, r *http.Request) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	state := h.buildState(hex.EncodeToString(nonce), h.now().Add(oauthStateTTL))
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    hex.EncodeToString(nonce),
+		Path:     "/slack/oauth",
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HttpOnly: true,
+		// 手元で動かすときはHTTPでも試せるようにする
+		Secure:   !strings.HasPrefix(h.redirectURL, "http://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	query := url.Values{
+		"client_id": {h.clientID},
+		"scope":     {slackBotScopes},
+		"state":     {state},
+	}
+	if h.redirectURL != "" {
+		query.Set("redirect_uri", h.redirectURL)
+	}
+	http.Redirect(w, r, slackAuthorizeURL+"?"+query.Encode(), http.StatusFound)
+}
+
+// ServeCallback はSlackから戻ってきたときに、受け取ったコードをボットトークンに交換して保存する
+func (h *SlackOAuthHandler) ServeCallback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		h.render(w, http.StatusOK, "追加を取り消しました", "warikan-botはワークスペースに追加されていません。")
+		return
+	}
+
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || !h.verifyState(query.Get("state"), cookie.Value) {
+		slog.WarnContext(ctx, "rejected oauth callback with invalid state")
+		h.render(w, http.StatusBadRequest, "追加できませんでした", "リンクの有効期限が切れています。はじめからやり直してください。")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/slack/oauth", MaxAge: -1})
+
+	response, err := slack.GetOAuthV2ResponseContext(ctx, h.httpClient, h.clientID, h.clientSecret, query.Get("code"), h.redirectURL)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to exchange oauth code", slog.Any("error", err))
+		h.render(w, http.StatusBadGateway, "追加できませんでした", "Slackとの通信に失敗しました。しばらくしてからやり直してください。")
+		return
+	}
+
+	teamID := valueobject.NewEnterpriseTeamID(response.Enterprise.ID, response.Team.ID)
+	teamName := response.Team.Name
+	if response.IsEnterpriseInstall {
+		// Enterprise Gridの組織全体に追加されたときは、組織のどのワークスペースからもこのトークンを使う
+		teamID = teamID.Organization()
+		teamName = response.Enterprise.Name
+	}
+	err = h.installationUsecase.Install(ctx, &entity.Installation{
+		TeamID:      teamID,
+		TeamName:    teamName,
+		BotUserID:   response.BotUserID,
+		BotToken:    response.AccessToken,
+		InstallerID: valueobject.NewSlackPayerID(teamID, response.AuthedUser.ID),
+		InstalledAt: h.now(),
+	})
+	if e := new(valueobject.ErrorInvalid); errors.As(err, &e) {
+		slog.WarnContext(ctx, "rejected invalid installation", slog.Any("error", err))
+		h.render(w, http.StatusBadRequest, "追加できませんでした", "Slackから受け取った内容が正しくありません。はじめからやり直してください。")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to install", slog.Any("error", err))
+		h.render(w, http.StatusInternalServerError, "追加できませんでした", "しばらくしてからやり直してください。")
+		return
+	}
+
+	h.render(w, http.StatusOK, "追加しました", teamName+"でwarikan-botを使えるようになりました。チャンネルで /warikan help と入力してみてください。")
+}
+
+// buildState はSlackを経由して戻ってくるstateを作る。有効期限を含めて署名し、書き換えられていないか確かめられるようにする
+func (h *SlackOAuthHandler) buildState(nonce string, expiry time.Time) string {
+	payload := nonce + "." + strconv.FormatInt(expiry.Unix(), 10)
+	return payload + "." + h.sign(payload)
+}
+
+// verifyState はstateが署名どおりで期限内で、追加を始めたブラウザのクッキーと一致するか確かめる
+func (h *SlackOAuthHandler) verifyState(state string, nonce string) bool {
+	payload, signature, ok := cutLast(state, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(h.sign(payload))) {
+		return false
+	}
+	stateNonce, rawExpiry, ok := strings.Cut(payload, ".")
+	if !ok || nonce == "" || !hmac.Equal([]byte(stateNonce), []byte(nonce)) {
+		return false
+	}
+	expiry, err := strconv.ParseInt(rawExpiry, 10, 64)
+	if err != nil {
+		return false
+	}
+	return h.now().Before(time.Unix(expiry, 0))
+}
+
+func (h *SlackOAuthHandler) sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(h.clientSecret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h *SlackOAuthHandler) render(w http.ResponseWriter, status int, title string, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	oauthResultTemplate.Execute(w, struct{ Title, Message string }{title, message})
+}
+
+func cutLast(s string, sep string) (before string, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/memory"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// oauth.v2.access の代わりに、決まった応答を返すクライアント
+func newOAuthClient(body string) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    r,
+		}, nil
+	})}
+}
+
+func TestSlackOAuthHandler(t *testing.T) {
+	t.Parallel()
+
+	const accessResponse = `{"ok":true,"access_token":"xoxb-1","bot_user_id":"U0BOT","team":{"id":"T0001","name":"warikan"},"authed_user":{"id":"U0001"}}`
+	installedAt := time.Date(2025, 5, 1, 19, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		// 追加画面に移動してから、Slackから戻ってくるまでの時間
+		elapsed time.Duration
+		// Slackから戻ってきたときのクエリを書き換える
+		callback func(query url.Values, cookie *http.Cookie)
+		// oauth.v2.access の応答。空のときはワークスペースへの追加
+		response       string
+		expectedStatus int
+		expectedSaved  bool
+		// 追加されたワークスペースから届くリクエストのチーム
+		requestTeamID valueobject.TeamID
+	}{
+		{
+			name:           "OK: installed",
+			elapsed:        time.Minute,
+			callback:       func(query url.Values, cookie *http.Cookie) {},
+			expectedStatus: http.StatusOK,
+			expectedSaved:  true,
+		},
+		{
+			name:           "OK: installed to the whole Enterprise Grid organization",
+			elapsed:        time.Minute,
+			callback:       func(query url.Values, cookie *http.Cookie) {},
+			response:       `{"ok":true,"access_token":"xoxb-1","bot_user_id":"U0BOT","is_enterprise_install":true,"enterprise":{"id":"E0001","name":"warikan-org"},"authed_user":{"id":"U0001"}}`,
+			expectedStatus: http.StatusOK,
+			expectedSaved:  true,
+			requestTeamID:  valueobject.NewEnterpriseTeamID("E0001", "T0002"),
+		},
+		{
+			name:    "OK: canceled on Slack",
+			elapsed: time.Minute,
+			callback: func(query url.Values, cookie *http.Cookie) {
+				query.Set("error", "access_denied")
+				query.Del("code")
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "NG: expired",
+			elapsed:        oauthStateTTL + time.Second,
+			callback:       func(query url.Values, cookie *http.Cookie) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "NG: tampered state",
+			elapsed: time.Minute,
+			callback: func(query url.Values, cookie *http.Cookie) {
+				query.Set("state", query.Get("state")+"0")
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "NG: started in another browser",
+			elapsed: time.Minute,
+			callback: func(query url.Values, cookie *http.Cookie) {
+				cookie.Value = "0123456789abcdef0123456789abcdef"
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			response := accessResponse
+			if test.response != "" {
+				response = test.response
+			}
+			requestTeamID := valueobject.NewTeamID("T0001")
+			if !test.requestTeamID.IsUnknown() {
+				requestTeamID = test.requestTeamID
+			}
+
+			installationUsecase := usecase.NewInstallation(memory.NewStore())
+			h := NewSlackOAuthHandler("123.456", "client-secret", "https://warikan.example.com/slack/oauth/callback", installationUsecase, newOAuthClient(response))
+			h.now = func() time.Time { return installedAt }
+
+			w := httptest.NewRecorder()
+			h.ServeInstall(w, httptest.NewRequest(http.MethodGet, "/slack/install", nil))
+			require.Equal(t, http.StatusFound, w.Code)
+			location, err := url.Parse(w.Header().Get("Location"))
+			require.NoError(t, err)
+			assert.Equal(t, "123.456", location.Query().Get("client_id"))
+			assert.Equal(t, slackBotScopes, location.Query().Get("scope"))
+			cookies := w.Result().Cookies()
+			require.Len(t, cookies, 1)
+
+			query := url.Values{"code": {"code"}, "state": {location.Query().Get("state")}}
+			test.callback(query, cookies[0])
+			r := httptest.NewRequest(http.MethodGet, "/slack/oauth/callback?"+query.Encode(), nil)
+			r.AddCookie(cookies[0])
+			h.now = func() time.Time { return installedAt.Add(test.elapsed) }
+			w = httptest.NewRecorder()
+			h.ServeCallback(w, r)
+			assert.Equal(t, test.expectedStatus, w.Code)
+
+			installation, err := installationUsecase.Find(t.Context(), requestTeamID.Organization())
+			if !test.expectedSaved {
+				e := new(valueobject.ErrorNotFound)
+				assert.ErrorAs(t, err, &e)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "xoxb-1", installation.BotToken)
+			assert.Equal(t, "U0BOT", installation.BotUserID)
+			assert.Equal(t, "U0001", installation.InstallerID.UserID())
+
+			// 追加したワークスペースからのリクエストには、保存したトークンで答える
+			client, err := NewInstalledSlackClients(installationUsecase).Client(t.Context(), requestTeamID)
+			require.NoError(t, err)
+			assert.NotNil(t, client)
+		})
+	}
+}
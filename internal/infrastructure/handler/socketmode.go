@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/logging"
+)
+
+// socketModeAcker はSocket Modeで届いたリクエストに応答する
+type socketModeAcker interface {
+	Ack(req socketmode.Request, payload ...interface{})
+}
+
+// SlackSocketModeRunner は公開URLを用意せずに、Socket ModeでSlackからのリクエストを受け取る
+type SlackSocketModeRunner struct {
+	client             *socketmode.Client
+	acker              socketModeAcker
+	workers            *WorkerPool
+	commandHandler     *SlackCommandHandler
+	eventHandler       *SlackEventHandler
+	interactionHandler *SlackInteractionHandler
+}
+
+// clientにはアプリレベルトークンを設定しておく
+func NewSlackSocketModeRunner(client *slack.Client, workers *WorkerPool, commandHandler *SlackCommandHandler, eventHandler *SlackEventHandler, interactionHandler *SlackInteractionHandler) *SlackSocketModeRunner {
+	socketClient := socketmode.New(client)
+	return &SlackSocketModeRunner{
+		client:             socketClient,
+		acker:              socketClient,
+		workers:            workers,
+		commandHandler:     commandHandler,
+		eventHandler:       eventHandler,
+		interactionHandler: interactionHandler,
+	}
+}
+
+// Run はctxがキャンセルされるまでSlackとの接続を保ち、届いたリクエストを処理する
+// Run から戻った後は、受け取ったリクエストを新しく処理しない
+func (r *SlackSocketModeRunner) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-r.client.Events:
+				r.handleEvent(ctx, event)
+			}
+		}
+	}()
+
+	err := r.client.RunContext(ctx)
+	cancel()
+	<-done
+	return err
+}
+
+func (r *SlackSocketModeRunner) handleEvent(ctx context.Context, event socketmode.Event) {
+	// HTTPのリクエストIDの代わりに、Slackが振ったエンベロープIDでログを突き合わせる
+	if event.Request != nil {
+		ctx = logging.With(ctx, slog.String("request_id", event.Request.EnvelopeID))
+		if event.Request.RetryAttempt > 0 {
+			ctx = logging.With(ctx, slog.String("retry_num", strconv.Itoa(event.Request.RetryAttempt)), slog.String("retry_reason", event.Request.RetryReason))
+		}
+	}
+
+	switch event.Type {
+	case socketmode.EventTypeConnected:
+		slog.InfoContext(ctx, "connected to slack with socket mode")
+	case socketmode.EventTypeConnectionError, socketmode.EventTypeInvalidAuth:
+		slog.ErrorContext(ctx, "socket mode connection failed", slog.Any("error", event.Data))
+	case socketmode.EventTypeSlashCommand:
+		slash, ok := event.Data.(slack.SlashCommand)
+		if !ok {
+			return
+		}
+		if !r.commandHandler.submit(ctx, slash) {
+			r.acker.Ack(*event.Request, buildBusyResponse())
+			return
+		}
+		r.acker.Ack(*event.Request)
+	case socketmode.EventTypeEventsAPI:
+		eventsAPIEvent, ok := event.Data.(slackevents.EventsAPIEvent)
+		if !ok {
+			return
+		}
+		// 受け付けられないときは応答しないでおき、Slackに再送してもらう
+		if !r.workers.Submit(func() { r.processCallbackEvent(ctx, eventsAPIEvent) }) {
+			slog.WarnContext(ctx, "rejected event because the queue is full")
+			return
+		}
+		r.acker.Ack(*event.Request)
+	case socketmode.EventTypeInteractive:
+		callback, ok := event.Data.(slack.InteractionCallback)
+		if !ok {
+			return
+		}
+		// モーダルの入力エラーは応答に含めて返す必要があるので、送信されたモーダルだけは応答する前に処理する
+		if callback.Type != slack.InteractionTypeViewSubmission {
+			// ボタンなどは時間がかかっても接続を止めないように、応答してから処理する
+			if !r.workers.Submit(func() { r.processInteraction(ctx, callback) }) {
+				slog.WarnContext(ctx, "rejected interaction because the queue is full")
+				return
+			}
+			r.acker.Ack(*event.Request)
+			return
+		}
+		response := r.processInteraction(ctx, callback)
+		if response != nil {
+			r.acker.Ack(*event.Request, response)
+			return
+		}
+		r.acker.Ack(*event.Request)
+	}
+}
+
+func (r *SlackSocketModeRunner) processCallbackEvent(ctx context.Context, event slackevents.EventsAPIEvent) {
+	if event.Type != slackevents.CallbackEvent {
+		return
+	}
+	// 接続が切れても処理中のイベントは最後まで処理する
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commandTimeout)
+	defer cancel()
+	// 処理している途中で再送されたものは、先に受け取ったほうに任せる
+	if err := r.eventHandler.handleCallbackEvent(ctx, event); err != nil && !errors.Is(err, errDeliveryInProgress) {
+		slog.ErrorContext(ctx, "failed to handle callback event", slog.Any("error", err))
+	}
+}
+
+func (r *SlackSocketModeRunner) processInteraction(ctx context.Context, callback slack.InteractionCallback) *slack.ViewSubmissionResponse {
+	// 接続が切れても処理中の操作は最後まで処理する
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commandTimeout)
+	defer cancel()
+	response, err := r.interactionHandler.handleInteraction(ctx, callback)
+	if err != nil && !errors.Is(err, errDeliveryInProgress) {
+		slog.ErrorContext(ctx, "failed to handle interaction", slog.Any("error", err))
+	}
+	return response
+}
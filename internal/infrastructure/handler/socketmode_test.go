@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/memory"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+type fakeSocketModeAcker struct {
+	mu       sync.Mutex
+	payloads []interface{}
+}
+
+func (a *fakeSocketModeAcker) Ack(req socketmode.Request, payload ...interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var pld interface{}
+	if len(payload) > 0 {
+		pld = payload[0]
+	}
+	a.payloads = append(a.payloads, pld)
+}
+
+func TestSlackSocketModeRunner_handleEvent(t *testing.T) {
+	t.Parallel()
+
+	invalidAmountSubmission := slack.InteractionCallback{
+		Type: slack.InteractionTypeViewSubmission,
+		View: slack.View{
+			CallbackID: SlackCallbackPaymentModal,
+			State: &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+				SlackBlockPaymentAmount: {SlackActionPaymentAmount: {Value: "三千円"}},
+			}},
+		},
+	}
+	tests := []struct {
+		name      string
+		event     socketmode.Event
+		queueSize int
+		// 応答したときは応答の中身があるか
+		expectedAcked   bool
+		expectedPayload bool
+		expectedQueued  int
+	}{
+		{
+			name:           "OK: slash command is queued and acked",
+			event:          socketmode.Event{Type: socketmode.EventTypeSlashCommand, Data: slack.SlashCommand{Text: "list"}},
+			queueSize:      1,
+			expectedAcked:  true,
+			expectedQueued: 1,
+		},
+		{
+			name:            "OK: slash command is acked with busy message when the queue is full",
+			event:           socketmode.Event{Type: socketmode.EventTypeSlashCommand, Data: slack.SlashCommand{Text: "list"}},
+			expectedAcked:   true,
+			expectedPayload: true,
+		},
+		{
+			name:           "OK: events api event is queued and acked",
+			event:          socketmode.Event{Type: socketmode.EventTypeEventsAPI, Data: slackevents.EventsAPIEvent{Type: slackevents.CallbackEvent}},
+			queueSize:      1,
+			expectedAcked:  true,
+			expectedQueued: 1,
+		},
+		{
+			name:  "OK: events api event is left for redelivery when the queue is full",
+			event: socketmode.Event{Type: socketmode.EventTypeEventsAPI, Data: slackevents.EventsAPIEvent{Type: slackevents.CallbackEvent}},
+		},
+		{
+			name:           "OK: block actions are acked before they are handled",
+			event:          socketmode.Event{Type: socketmode.EventTypeInteractive, Data: slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}},
+			queueSize:      1,
+			expectedAcked:  true,
+			expectedQueued: 1,
+		},
+		{
+			name:  "OK: block actions are not acked when the queue is full",
+			event: socketmode.Event{Type: socketmode.EventTypeInteractive, Data: slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}},
+		},
+		{
+			name:            "OK: view submission is handled before it is acked",
+			event:           socketmode.Event{Type: socketmode.EventTypeInteractive, Data: invalidAmountSubmission},
+			queueSize:       1,
+			expectedAcked:   true,
+			expectedPayload: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			// 処理する人がいないプールで、予約されたかだけを確かめる
+			workers := NewWorkerPool(0, test.queueSize)
+			store := memory.NewStore()
+			paymentUsecase := usecase.NewPayment(store, nil)
+			deliveryUsecase := usecase.NewDelivery(store)
+			clients := NewStaticSlackClients(slack.New("xoxb-test"))
+			acker := new(fakeSocketModeAcker)
+			runner := &SlackSocketModeRunner{
+				acker:              acker,
+				workers:            workers,
+				commandHandler:     NewSlackCommandHandler(clients, NewSlackChannels(paymentUsecase), NewSlackUsers(paymentUsecase), paymentUsecase, deliveryUsecase, workers, nil, BotProfile{}),
+				interactionHandler: NewSlackInteractionHandler(clients, NewSlackChannels(paymentUsecase), NewSlackUsers(paymentUsecase), paymentUsecase, deliveryUsecase, BotProfile{}),
+			}
+			test.event.Request = &socketmode.Request{EnvelopeID: "envelope"}
+
+			runner.handleEvent(t.Context(), test.event)
+
+			assert.Equal(t, test.expectedQueued, len(workers.jobs))
+			if !test.expectedAcked {
+				assert.Empty(t, acker.payloads)
+				return
+			}
+			require.Len(t, acker.payloads, 1)
+			assert.Equal(t, test.expectedPayload, acker.payloads[0] != nil)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/slack-go/slack"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+// errUserUnavailable は権限がないなどで、利用者が所属する組織を調べられなかったことを表す
+var errUserUnavailable = errors.New("user is unavailable")
+
+// SlackUsers はSlackの利用者が所属する組織を調べて、組織で区別した参加者のIDを作る
+// Slackコネクトで共有したチャンネルには他の組織の利用者もいるので、リクエストが届いたワークスペースではなく利用者の所属を使う
+type SlackUsers struct {
+	paymentUsecase *usecase.PaymentUsecase
+
+	mu sync.Mutex
+	// 利用者の所属は変わらないので、一度調べたら覚えておく
+	payerIDs map[string]valueobject.PayerID
+}
+
+func NewSlackUsers(paymentUsecase *usecase.PaymentUsecase) *SlackUsers {
+	return &SlackUsers{
+		paymentUsecase: paymentUsecase,
+		payerIDs:       make(map[string]valueobject.PayerID),
+	}
+}
+
+// PayerID はSlackのユーザーIDから参加者のIDを返す。ユーザーIDが空のときは空のIDを返す
+func (u *SlackUsers) PayerID(ctx context.Context, client *slack.Client, userID string) (valueobject.PayerID, error) {
+	if userID == "" {
+		return valueobject.PayerID{}, nil
+	}
+	u.mu.Lock()
+	payerID, ok := u.payerIDs[userID]
+	u.mu.Unlock()
+	if ok {
+		return payerID, nil
+	}
+
+	user, err := client.GetUserInfoContext(ctx, userID)
+	if err != nil {
+		return valueobject.PayerID{}, fmt.Errorf("%w: failed to get user info: %w", errUserUnavailable, err)
+	}
+	payerID = valueobject.NewSlackPayerID(valueobject.NewEnterpriseTeamID(user.Enterprise.EnterpriseID, user.TeamID), userID)
+	// 所属を区別する前はユーザーIDだけで記録していたので、その記録を引き継ぐ
+	if err := u.paymentUsecase.RenamePayer(ctx, valueobject.NewPayerID(userID), payerID); err != nil {
+		return valueobject.PayerID{}, err
+	}
+
+	u.mu.Lock()
+	u.payerIDs[userID] = payerID
+	u.mu.Unlock()
+	return payerID, nil
+}
+
+// PayerIDs は複数のユーザーIDから参加者のIDを返す
+func (u *SlackUsers) PayerIDs(ctx context.Context, client *slack.Client, userIDs []string) ([]valueobject.PayerID, error) {
+	var payerIDs []valueobject.PayerID
+	for _, userID := range userIDs {
+		payerID, err := u.PayerID(ctx, client, userID)
+		if err != nil {
+			return nil, err
+		}
+		payerIDs = append(payerIDs, payerID)
+	}
+	return payerIDs, nil
+}
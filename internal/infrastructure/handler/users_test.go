@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/memory"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+func TestSlackUsers_PayerID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		// users.info の応答
+		response        string
+		expectedPayerID valueobject.PayerID
+		expectedErr     bool
+	}{
+		{
+			name:            "OK: user in the workspace",
+			response:        `{"ok":true,"user":{"id":"U0001","team_id":"T0001"}}`,
+			expectedPayerID: valueobject.NewPayerID("T0001:U0001"),
+		},
+		{
+			name:            "OK: user in the Enterprise Grid organization",
+			response:        `{"ok":true,"user":{"id":"U0001","team_id":"T0001","enterprise_user":{"id":"W0001","enterprise_id":"E0001"}}}`,
+			expectedPayerID: valueobject.NewPayerID("E0001:U0001"),
+		},
+		{
+			name:        "NG: user cannot be looked up",
+			response:    `{"ok":false,"error":"missing_scope"}`,
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(test.response))
+			}))
+			defer server.Close()
+			client := slack.New("xoxb-1", slack.OptionAPIURL(server.URL+"/"))
+
+			// 組織を区別する前に参加していた
+			paymentUsecase := usecase.NewPayment(memory.NewStore(), nil)
+			eventID := valueobject.NewEventID("T0001:C0001")
+			_, err := paymentUsecase.Join(t.Context(), eventID, valueobject.NewPayerID("U0001"), valueobject.Percent(100))
+			require.NoError(t, err)
+			users := NewSlackUsers(paymentUsecase)
+
+			payerID, err := users.PayerID(t.Context(), client, "U0001")
+			if test.expectedErr {
+				assert.ErrorIs(t, err, errUserUnavailable)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPayerID, payerID)
+			assert.Equal(t, "U0001", payerID.UserID())
+
+			// 2回目は覚えておいた結果を使う
+			payerID, err = users.PayerID(t.Context(), client, "U0001")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPayerID, payerID)
+			assert.Equal(t, int32(1), calls.Load())
+
+			// それまでの記録を引き継ぐ
+			payers, err := paymentUsecase.Payers(t.Context(), eventID)
+			require.NoError(t, err)
+			require.Len(t, payers, 1)
+			assert.Equal(t, test.expectedPayerID, payers[0].ID)
+		})
+	}
+}
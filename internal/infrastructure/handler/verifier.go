@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/metrics"
+)
+
+const (
+	// Slackが推奨する、リクエストのタイムスタンプと現在時刻のずれの上限
+	slackTimestampTolerance = 5 * time.Minute
+	// Slackから届くリクエストの本文の上限。モーダルの入力内容を含めても十分に収まる
+	maxSlackRequestBodySize = 1 << 20
+)
+
+// 検証に失敗した理由。メトリクスのラベルにも使う
+const (
+	rejectReasonMissingHeader    = "missing_header"
+	rejectReasonInvalidTimestamp = "invalid_timestamp"
+	rejectReasonStaleTimestamp   = "stale_timestamp"
+	rejectReasonBodyTooLarge     = "body_too_large"
+	rejectReasonInvalidSignature = "invalid_signature"
+	rejectReasonReplayed         = "replayed"
+)
+
+// SlackVerifier はSlackからのリクエストの署名とタイムスタンプを検証し、同じリクエストを使い回す攻撃を防ぐ
+type SlackVerifier struct {
+	signingSecret string
+	metrics       *metrics.Metrics
+	now           func() time.Time
+
+	mu sync.Mutex
+	// 受け付けた署名と、そのタイムスタンプ。許容範囲を過ぎたものは捨てる
+	seen map[string]time.Time
+}
+
+func NewSlackVerifier(signingSecret string, metrics *metrics.Metrics) *SlackVerifier {
+	return &SlackVerifier{
+		signingSecret: signingSecret,
+		metrics:       metrics,
+		now:           time.Now,
+		seen:          make(map[string]time.Time),
+	}
+}
+
+// Middleware は検証に通ったリクエストだけをnextに渡す。nextは本文をそのまま読める
+func (v *SlackVerifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawTimestamp := r.Header.Get("X-Slack-Request-Timestamp")
+		signature := r.Header.Get("X-Slack-Signature")
+		if rawTimestamp == "" || signature == "" {
+			v.reject(w, r, rejectReasonMissingHeader, http.StatusUnauthorized)
+			return
+		}
+		unix, err := strconv.ParseInt(rawTimestamp, 10, 64)
+		if err != nil {
+			v.reject(w, r, rejectReasonInvalidTimestamp, http.StatusUnauthorized)
+			return
+		}
+		timestamp := time.Unix(unix, 0)
+		if skew := v.now().Sub(timestamp).Abs(); skew > slackTimestampTolerance {
+			v.reject(w, r, rejectReasonStaleTimestamp, http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSlackRequestBodySize))
+		if e := new(http.MaxBytesError); errors.As(err, &e) {
+			v.reject(w, r, rejectReasonBodyTooLarge, http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if !hmac.Equal([]byte(signature), []byte(v.sign(rawTimestamp, body))) {
+			v.reject(w, r, rejectReasonInvalidSignature, http.StatusUnauthorized)
+			return
+		}
+		if !v.remember(signature, timestamp) {
+			v.reject(w, r, rejectReasonReplayed, http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sign はSlackと同じ手順で署名を計算する
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func (v *SlackVerifier) sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(v.signingSecret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// remember は初めて見た署名ならtrueを返す。同じ署名のリクエストは使い回されたものとみなす
+func (v *SlackVerifier) remember(signature string, timestamp time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	expiry := v.now().Add(-slackTimestampTolerance)
+	for seenSignature, seenTimestamp := range v.seen {
+		if seenTimestamp.Before(expiry) {
+			delete(v.seen, seenSignature)
+		}
+	}
+	if _, ok := v.seen[signature]; ok {
+		return false
+	}
+	v.seen[signature] = timestamp
+	return true
+}
+
+func (v *SlackVerifier) reject(w http.ResponseWriter, r *http.Request, reason string, status int) {
+	v.metrics.SlackRequestRejected(reason)
+	slog.WarnContext(r.Context(), "rejected slack request", slog.String("reason", reason))
+	http.Error(w, "Invalid request", status)
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/metrics"
+)
+
+// Slackのドキュメントにある署名の例
+// https://api.slack.com/authentication/verifying-requests-from-slack
+const (
+	fixtureSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+	fixtureTimestamp     = 1531420618
+	fixtureSignature     = "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return body
+}
+
+func newSignedRequest(body []byte, timestamp int64, signature string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/slack/command", bytes.NewReader(body))
+	r.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(timestamp, 10))
+	r.Header.Set("X-Slack-Signature", signature)
+	return r
+}
+
+func TestSlackVerifier(t *testing.T) {
+	t.Parallel()
+
+	slashCommand := readFixture(t, "slash_command.txt")
+	eventCallback := readFixture(t, "event_callback.json")
+	signer := NewSlackVerifier(fixtureSigningSecret, nil)
+	signedAt := time.Unix(fixtureTimestamp, 0)
+
+	tests := []struct {
+		name           string
+		request        func() *http.Request
+		now            time.Time
+		expectedStatus int
+	}{
+		{
+			name: "OK: slash command signed by Slack",
+			request: func() *http.Request {
+				return newSignedRequest(slashCommand, fixtureTimestamp, fixtureSignature)
+			},
+			now:            signedAt.Add(time.Minute),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "OK: event callback",
+			request: func() *http.Request {
+				return newSignedRequest(eventCallback, fixtureTimestamp, signer.sign(strconv.Itoa(fixtureTimestamp), eventCallback))
+			},
+			now:            signedAt,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "NG: missing signature",
+			request: func() *http.Request {
+				r := newSignedRequest(slashCommand, fixtureTimestamp, fixtureSignature)
+				r.Header.Del("X-Slack-Signature")
+				return r
+			},
+			now:            signedAt,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "NG: timestamp is not a number",
+			request: func() *http.Request {
+				r := newSignedRequest(slashCommand, fixtureTimestamp, fixtureSignature)
+				r.Header.Set("X-Slack-Request-Timestamp", "yesterday")
+				return r
+			},
+			now:            signedAt,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "NG: signed too long ago",
+			request: func() *http.Request {
+				return newSignedRequest(slashCommand, fixtureTimestamp, fixtureSignature)
+			},
+			now:            signedAt.Add(6 * time.Minute),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "NG: signed in the future",
+			request: func() *http.Request {
+				return newSignedRequest(slashCommand, fixtureTimestamp, fixtureSignature)
+			},
+			now:            signedAt.Add(-6 * time.Minute),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "NG: body is tampered",
+			request: func() *http.Request {
+				tampered := bytes.Replace(slashCommand, []byte("text="), []byte("text=100000"), 1)
+				return newSignedRequest(tampered, fixtureTimestamp, fixtureSignature)
+			},
+			now:            signedAt,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "NG: signed with another secret",
+			request: func() *http.Request {
+				other := NewSlackVerifier("another-secret", nil)
+				return newSignedRequest(slashCommand, fixtureTimestamp, other.sign(strconv.Itoa(fixtureTimestamp), slashCommand))
+			},
+			now:            signedAt,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "NG: body is too large",
+			request: func() *http.Request {
+				large := []byte(strings.Repeat("a", maxSlackRequestBodySize+1))
+				return newSignedRequest(large, fixtureTimestamp, signer.sign(strconv.Itoa(fixtureTimestamp), large))
+			},
+			now:            signedAt,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			verifier := NewSlackVerifier(fixtureSigningSecret, nil)
+			verifier.now = func() time.Time { return test.now }
+			request := test.request()
+			expectedBody, err := io.ReadAll(request.Body)
+			require.NoError(t, err)
+			request.Body = io.NopCloser(bytes.NewReader(expectedBody))
+
+			var body []byte
+			handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+			}))
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+			if test.expectedStatus == http.StatusOK {
+				assert.Equal(t, expectedBody, body, "next handler must read the whole body")
+			} else {
+				assert.Nil(t, body, "next handler must not be called")
+			}
+		})
+	}
+}
+
+func TestSlackVerifierReplay(t *testing.T) {
+	t.Parallel()
+
+	slashCommand := readFixture(t, "slash_command.txt")
+	appMetrics := metrics.New()
+	verifier := NewSlackVerifier(fixtureSigningSecret, appMetrics)
+	now := time.Unix(fixtureTimestamp, 0)
+	verifier.now = func() time.Time { return now }
+	handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newSignedRequest(slashCommand, fixtureTimestamp, fixtureSignature))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	// 同じリクエストをそのまま送り直しても受け付けない
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newSignedRequest(slashCommand, fixtureTimestamp, fixtureSignature))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	appMetrics.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, recorder.Body.String(), `warikan_slack_requests_rejected_total{reason="replayed"} 1`)
+}
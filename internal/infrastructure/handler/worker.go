@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"sync"
+)
+
+// WorkerPool は重い処理をリクエストから切り離し、同時に実行する数を制限する
+type WorkerPool struct {
+	jobs chan func()
+	wg   sync.WaitGroup
+	// 終了処理の後に届いたリクエストが、閉じたキューに送らないようにする
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewWorkerPool(workers int, queueSize int) *WorkerPool {
+	p := &WorkerPool{
+		jobs: make(chan func(), queueSize),
+	}
+	for range workers {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+// Submit はキューに空きがないか、すでに Close されていればjobを捨ててfalseを返す
+func (p *WorkerPool) Submit(job func()) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	select {
+	case p.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close は新しい処理の受け付けをやめ、キューに残った処理が終わるか、ctxが終了するまで待つ
+func (p *WorkerPool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerPool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("OK: runs every submitted job", func(t *testing.T) {
+		t.Parallel()
+
+		pool := NewWorkerPool(4, 100)
+		var count atomic.Int64
+		for range 100 {
+			assert.True(t, pool.Submit(func() { count.Add(1) }))
+		}
+		assert.NoError(t, pool.Close(t.Context()))
+		assert.Equal(t, int64(100), count.Load())
+	})
+
+	t.Run("NG: rejects jobs when the queue is full", func(t *testing.T) {
+		t.Parallel()
+
+		pool := NewWorkerPool(1, 1)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		assert.True(t, pool.Submit(func() {
+			close(started)
+			<-release
+		}))
+		<-started
+		assert.True(t, pool.Submit(func() {}))
+		assert.False(t, pool.Submit(func() {}))
+		close(release)
+		assert.NoError(t, pool.Close(t.Context()))
+	})
+
+	t.Run("NG: rejects jobs after close", func(t *testing.T) {
+		t.Parallel()
+
+		pool := NewWorkerPool(1, 1)
+		assert.NoError(t, pool.Close(t.Context()))
+		assert.False(t, pool.Submit(func() {}))
+	})
+
+	t.Run("NG: stops waiting when the drain times out", func(t *testing.T) {
+		t.Parallel()
+
+		pool := NewWorkerPool(1, 1)
+		release := make(chan struct{})
+		defer close(release)
+		assert.True(t, pool.Submit(func() { <-release }))
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		assert.ErrorIs(t, pool.Close(ctx), context.Canceled)
+	})
+}
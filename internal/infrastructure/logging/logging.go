@@ -0,0 +1,74 @@
+// Package logging はslogの設定と、リクエストごとの属性をコンテキストで引き回す仕組みをまとめる
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New はformatとlevelに従ってログを書き出すロガーを作る。コンテキストに積んだ属性も出力する
+func New(w io.Writer, format string, level string) (*slog.Logger, error) {
+	var lv slog.Level
+	if err := lv.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level: %w", err)
+	}
+	options := &slog.HandlerOptions{Level: lv}
+
+	var handler slog.Handler
+	switch format {
+	case FormatText:
+		handler = slog.NewTextHandler(w, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("unknown log format: %q", format)
+	}
+	return slog.New(&contextHandler{handler}), nil
+}
+
+type attrsKey struct{}
+
+// With はctxから出力するログにattrsを加える
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	parent, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(parent)+len(attrs))
+	merged = append(merged, parent...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// NewRequestID はログを突き合わせるためのリクエストIDを作る
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// contextHandler はWithで積んだ属性をログに加える
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		format      string
+		level       string
+		expectedErr bool
+	}{
+		{name: "OK: text", format: FormatText, level: "info"},
+		{name: "OK: json", format: FormatJSON, level: "debug"},
+		{name: "NG: unknown format", format: "xml", level: "info", expectedErr: true},
+		{name: "NG: unknown level", format: FormatText, level: "verbose", expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := New(&bytes.Buffer{}, test.format, test.level)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestWith(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, "info")
+	require.NoError(t, err)
+
+	ctx := With(t.Context(), slog.String("request_id", "abc"))
+	ctx = With(ctx, slog.String("channel", "C0001"))
+	logger.InfoContext(ctx, "payment created", slog.Int("amount", 3000))
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "abc", record["request_id"])
+	assert.Equal(t, "C0001", record["channel"])
+	assert.Equal(t, 3000.0, record["amount"])
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var requestID string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attrs, _ := r.Context().Value(attrsKey{}).([]slog.Attr)
+		require.Len(t, attrs, 1)
+		requestID = attrs[0].Value.String()
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/slack/command", nil))
+	assert.Equal(t, http.StatusTeapot, recorder.Code)
+	assert.NotEmpty(t, requestID)
+	assert.Equal(t, requestID, recorder.Header().Get(RequestIDHeader))
+}
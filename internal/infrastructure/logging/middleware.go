@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const RequestIDHeader = "X-Request-Id"
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Middleware はリクエストにIDを振ってコンテキストに積み、処理し終えたらその結果をログに出す
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := With(r.Context(), slog.String("request_id", requestID))
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if recorder.status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+		slog.Log(ctx, level, "handled request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", recorder.status),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
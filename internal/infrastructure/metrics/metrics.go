@@ -0,0 +1,152 @@
+// Package metrics はPrometheusで集計する指標をまとめる
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "warikan"
+
+// Metrics のメソッドはnilでも呼べるので、テストなどで計測しないときはnilを渡せばよい
+type Metrics struct {
+	registry         *prometheus.Registry
+	commands         *prometheus.CounterVec
+	settleDuration   prometheus.Histogram
+	paymentsCreated  prometheus.Counter
+	paymentsDeleted  prometheus.Counter
+	paymentsRestored prometheus.Counter
+	slackAPIRequests *prometheus.CounterVec
+	slackAPIErrors   *prometheus.CounterVec
+	queryDuration    *prometheus.HistogramVec
+	slackRejected    *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "commands_total",
+			Help:      "Number of slash commands handled, by subcommand and result.",
+		}, []string{"subcommand", "result"}),
+		settleDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "settle_duration_seconds",
+			Help:      "Time taken to compute a settlement.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		paymentsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "payments_created_total",
+			Help:      "Number of payments created.",
+		}),
+		paymentsDeleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "payments_deleted_total",
+			Help:      "Number of payments deleted.",
+		}),
+		paymentsRestored: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "payments_restored_total",
+			Help:      "Number of deleted payments restored with undo.",
+		}),
+		slackAPIRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "slack_api_requests_total",
+			Help:      "Number of Slack API requests, by method.",
+		}, []string{"method"}),
+		slackAPIErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "slack_api_errors_total",
+			Help:      "Number of failed Slack API requests, by method and error.",
+		}, []string{"method", "error"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Time taken by repository operations, by operation.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"operation"}),
+		slackRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "slack_requests_rejected_total",
+			Help:      "Number of incoming Slack requests rejected by verification, by reason.",
+		}, []string{"reason"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.commands,
+		m.settleDuration,
+		m.paymentsCreated,
+		m.paymentsDeleted,
+		m.paymentsRestored,
+		m.slackAPIRequests,
+		m.slackAPIErrors,
+		m.queryDuration,
+		m.slackRejected,
+	)
+	return m
+}
+
+// Handler は /metrics で公開するハンドラを返す
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) CommandHandled(subcommand string, err error) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.commands.WithLabelValues(subcommand, result).Inc()
+}
+
+func (m *Metrics) SettleObserved(duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.settleDuration.Observe(duration.Seconds())
+}
+
+func (m *Metrics) PaymentCreated() {
+	if m == nil {
+		return
+	}
+	m.paymentsCreated.Inc()
+}
+
+func (m *Metrics) PaymentDeleted() {
+	if m == nil {
+		return
+	}
+	m.paymentsDeleted.Inc()
+}
+
+func (m *Metrics) PaymentRestored() {
+	if m == nil {
+		return
+	}
+	m.paymentsRestored.Inc()
+}
+
+func (m *Metrics) SlackRequestRejected(reason string) {
+	if m == nil {
+		return
+	}
+	m.slackRejected.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) queryObserved(operation string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.queryDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
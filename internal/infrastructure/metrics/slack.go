@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type slackTransport struct {
+	base    http.RoundTripper
+	metrics *Metrics
+}
+
+// SlackTransport はSlack APIへのリクエストとエラーを数える。
+// Slack APIは失敗してもHTTPのステータスは200で、本文の ok が false になるので、本文も確かめる
+func (m *Metrics) SlackTransport(base http.RoundTripper) http.RoundTripper {
+	return &slackTransport{
+		base:    base,
+		metrics: m,
+	}
+}
+
+func (t *slackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := slackMethod(req)
+	t.metrics.slackAPIRequests.WithLabelValues(method).Inc()
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.metrics.slackAPIErrors.WithLabelValues(method, "transport").Inc()
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		t.metrics.slackAPIErrors.WithLabelValues(method, http.StatusText(resp.StatusCode)).Inc()
+		return resp, nil
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &result) == nil && !result.OK && result.Error != "" {
+		t.metrics.slackAPIErrors.WithLabelValues(method, result.Error).Inc()
+	}
+	return resp, nil
+}
+
+// slackMethod はURLからAPIのメソッド名を取り出す。response_urlなどのAPI以外は種類だけにまとめる
+func slackMethod(req *http.Request) string {
+	if name, ok := strings.CutPrefix(req.URL.Path, "/api/"); ok && req.URL.Host == "slack.com" {
+		return name
+	}
+	if strings.HasPrefix(req.URL.Path, "/commands/") || strings.HasPrefix(req.URL.Path, "/actions/") {
+		return "response_url"
+	}
+	if strings.HasPrefix(req.URL.Host, "files.slack.com") {
+		return "files"
+	}
+	return "other"
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestSlackTransport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		url           string
+		status        int
+		body          string
+		expectedLabel []string
+	}{
+		{
+			name:   "OK: successful API call",
+			url:    "https://slack.com/api/chat.postMessage",
+			status: http.StatusOK,
+			body:   `{"ok":true}`,
+		},
+		{
+			name:          "NG: API error in the body",
+			url:           "https://slack.com/api/chat.postMessage",
+			status:        http.StatusOK,
+			body:          `{"ok":false,"error":"channel_not_found"}`,
+			expectedLabel: []string{"chat.postMessage", "channel_not_found"},
+		},
+		{
+			name:          "NG: rate limited",
+			url:           "https://slack.com/api/views.open",
+			status:        http.StatusTooManyRequests,
+			body:          `{"ok":false,"error":"ratelimited"}`,
+			expectedLabel: []string{"views.open", "Too Many Requests"},
+		},
+		{
+			name:          "NG: response_url is gone",
+			url:           "https://hooks.slack.com/commands/T0001/1234/abcd",
+			status:        http.StatusNotFound,
+			expectedLabel: []string{"response_url", "Not Found"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := New()
+			transport := m.SlackTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: test.status,
+					Header:     http.Header{"Content-Type": []string{"application/json; charset=utf-8"}},
+					Body:       io.NopCloser(strings.NewReader(test.body)),
+				}, nil
+			}))
+
+			req, err := http.NewRequest(http.MethodPost, test.url, nil)
+			require.NoError(t, err)
+			resp, err := transport.RoundTrip(req)
+			require.NoError(t, err)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.body, string(body), "body must still be readable")
+
+			if test.expectedLabel == nil {
+				assert.Equal(t, 0, testutil.CollectAndCount(m.slackAPIErrors))
+				return
+			}
+			assert.Equal(t, 1.0, testutil.ToFloat64(m.slackAPIErrors.WithLabelValues(test.expectedLabel...)))
+		})
+	}
+}
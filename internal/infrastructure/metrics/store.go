@@ -0,0 +1,263 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/repository"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+// Store はリポジトリの各操作にかかった時間を記録する
+type Store struct {
+	store   repository.Store
+	metrics *Metrics
+}
+
+func NewStore(store repository.Store, metrics *Metrics) *Store {
+	return &Store{
+		store:   store,
+		metrics: metrics,
+	}
+}
+
+// observe はstartから経過した時間をoperationの所要時間として記録する
+func (m *Metrics) observe(operation string, start time.Time) {
+	m.queryObserved(operation, time.Since(start))
+}
+
+func (s *Store) Events() repository.EventRepository {
+	return &eventRepository{s.store.Events(), s.metrics}
+}
+
+func (s *Store) Payers() repository.PayerRepository {
+	return &payerRepository{s.store.Payers(), s.metrics}
+}
+
+func (s *Store) Payments() repository.PaymentRepository {
+	return &paymentRepository{s.store.Payments(), s.metrics}
+}
+
+func (s *Store) AuditLogs() repository.AuditLogRepository {
+	return &auditLogRepository{s.store.AuditLogs(), s.metrics}
+}
+
+func (s *Store) Deliveries() repository.DeliveryRepository {
+	return &deliveryRepository{s.store.Deliveries(), s.metrics}
+}
+
+func (s *Store) Installations() repository.InstallationRepository {
+	return &installationRepository{s.store.Installations(), s.metrics}
+}
+
+func (s *Store) APITokens() repository.APITokenRepository {
+	return &apiTokenRepository{s.store.APITokens(), s.metrics}
+}
+
+func (s *Store) Transaction(ctx context.Context, fn func(store repository.Store) error) error {
+	defer s.metrics.observe("transaction", time.Now())
+	return s.store.Transaction(ctx, func(store repository.Store) error {
+		return fn(NewStore(store, s.metrics))
+	})
+}
+
+type eventRepository struct {
+	repository repository.EventRepository
+	metrics    *Metrics
+}
+
+func (r *eventRepository) CreateIfNotExists(ctx context.Context, event *entity.Event) error {
+	defer r.metrics.observe("events.create_if_not_exists", time.Now())
+	return r.repository.CreateIfNotExists(ctx, event)
+}
+
+func (r *eventRepository) FindByID(ctx context.Context, eventID valueobject.EventID) (*entity.Event, error) {
+	defer r.metrics.observe("events.find_by_id", time.Now())
+	return r.repository.FindByID(ctx, eventID)
+}
+
+func (r *eventRepository) FindAll(ctx context.Context) ([]*entity.Event, error) {
+	defer r.metrics.observe("events.find_all", time.Now())
+	return r.repository.FindAll(ctx)
+}
+
+func (r *eventRepository) AssignTeam(ctx context.Context, teamID valueobject.TeamID) (int, error) {
+	defer r.metrics.observe("events.assign_team", time.Now())
+	return r.repository.AssignTeam(ctx, teamID)
+}
+
+func (r *eventRepository) Move(ctx context.Context, from valueobject.EventID, to valueobject.EventID) error {
+	defer r.metrics.observe("events.move", time.Now())
+	return r.repository.Move(ctx, from, to)
+}
+
+type payerRepository struct {
+	repository repository.PayerRepository
+	metrics    *Metrics
+}
+
+func (r *payerRepository) Create(ctx context.Context, payer *entity.Payer) error {
+	defer r.metrics.observe("payers.create", time.Now())
+	return r.repository.Create(ctx, payer)
+}
+
+func (r *payerRepository) CreateIfNotExists(ctx context.Context, payer *entity.Payer) error {
+	defer r.metrics.observe("payers.create_if_not_exists", time.Now())
+	return r.repository.CreateIfNotExists(ctx, payer)
+}
+
+func (r *payerRepository) Update(ctx context.Context, payer *entity.Payer) error {
+	defer r.metrics.observe("payers.update", time.Now())
+	return r.repository.Update(ctx, payer)
+}
+
+func (r *payerRepository) Delete(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID) error {
+	defer r.metrics.observe("payers.delete", time.Now())
+	return r.repository.Delete(ctx, eventID, payerID)
+}
+
+func (r *payerRepository) FindByID(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID) (*entity.Payer, error) {
+	defer r.metrics.observe("payers.find_by_id", time.Now())
+	return r.repository.FindByID(ctx, eventID, payerID)
+}
+
+func (r *payerRepository) FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.Payer, error) {
+	defer r.metrics.observe("payers.find_by_event_id", time.Now())
+	return r.repository.FindByEventID(ctx, eventID)
+}
+
+func (r *payerRepository) Rename(ctx context.Context, from valueobject.PayerID, to valueobject.PayerID) error {
+	defer r.metrics.observe("payers.rename", time.Now())
+	return r.repository.Rename(ctx, from, to)
+}
+
+type paymentRepository struct {
+	repository repository.PaymentRepository
+	metrics    *Metrics
+}
+
+func (r *paymentRepository) Create(ctx context.Context, payment *entity.Payment) error {
+	defer r.metrics.observe("payments.create", time.Now())
+	return r.repository.Create(ctx, payment)
+}
+
+func (r *paymentRepository) Update(ctx context.Context, payment *entity.Payment) error {
+	defer r.metrics.observe("payments.update", time.Now())
+	return r.repository.Update(ctx, payment)
+}
+
+func (r *paymentRepository) Delete(ctx context.Context, paymentID valueobject.PaymentID) error {
+	defer r.metrics.observe("payments.delete", time.Now())
+	return r.repository.Delete(ctx, paymentID)
+}
+
+func (r *paymentRepository) Restore(ctx context.Context, paymentID valueobject.PaymentID) error {
+	defer r.metrics.observe("payments.restore", time.Now())
+	return r.repository.Restore(ctx, paymentID)
+}
+
+func (r *paymentRepository) FindByID(ctx context.Context, paymentID valueobject.PaymentID) (*entity.Payment, error) {
+	defer r.metrics.observe("payments.find_by_id", time.Now())
+	return r.repository.FindByID(ctx, paymentID)
+}
+
+func (r *paymentRepository) FindLastDeleted(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID, since time.Time) (*entity.Payment, error) {
+	defer r.metrics.observe("payments.find_last_deleted", time.Now())
+	return r.repository.FindLastDeleted(ctx, eventID, payerID, since)
+}
+
+func (r *paymentRepository) FindLastSimilar(ctx context.Context, payment *entity.Payment, since time.Time) (*entity.Payment, error) {
+	defer r.metrics.observe("payments.find_last_similar", time.Now())
+	return r.repository.FindLastSimilar(ctx, payment, since)
+}
+
+func (r *paymentRepository) FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.Payment, error) {
+	defer r.metrics.observe("payments.find_by_event_id", time.Now())
+	return r.repository.FindByEventID(ctx, eventID)
+}
+
+type auditLogRepository struct {
+	repository repository.AuditLogRepository
+	metrics    *Metrics
+}
+
+func (r *auditLogRepository) Append(ctx context.Context, log *entity.AuditLog) error {
+	defer r.metrics.observe("audit_logs.append", time.Now())
+	return r.repository.Append(ctx, log)
+}
+
+func (r *auditLogRepository) FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.AuditLog, error) {
+	defer r.metrics.observe("audit_logs.find_by_event_id", time.Now())
+	return r.repository.FindByEventID(ctx, eventID)
+}
+
+type deliveryRepository struct {
+	repository repository.DeliveryRepository
+	metrics    *Metrics
+}
+
+func (r *deliveryRepository) Create(ctx context.Context, key string, receivedAt time.Time) error {
+	defer r.metrics.observe("deliveries.create", time.Now())
+	return r.repository.Create(ctx, key, receivedAt)
+}
+
+func (r *deliveryRepository) Complete(ctx context.Context, key string) error {
+	defer r.metrics.observe("deliveries.complete", time.Now())
+	return r.repository.Complete(ctx, key)
+}
+
+func (r *deliveryRepository) IsCompleted(ctx context.Context, key string) (bool, error) {
+	defer r.metrics.observe("deliveries.is_completed", time.Now())
+	return r.repository.IsCompleted(ctx, key)
+}
+
+func (r *deliveryRepository) Delete(ctx context.Context, key string) error {
+	defer r.metrics.observe("deliveries.delete", time.Now())
+	return r.repository.Delete(ctx, key)
+}
+
+func (r *deliveryRepository) DeleteBefore(ctx context.Context, before time.Time) error {
+	defer r.metrics.observe("deliveries.delete_before", time.Now())
+	return r.repository.DeleteBefore(ctx, before)
+}
+
+type installationRepository struct {
+	repository repository.InstallationRepository
+	metrics    *Metrics
+}
+
+func (r *installationRepository) Save(ctx context.Context, installation *entity.Installation) error {
+	defer r.metrics.observe("installations.save", time.Now())
+	return r.repository.Save(ctx, installation)
+}
+
+func (r *installationRepository) FindByTeamID(ctx context.Context, teamID valueobject.TeamID) (*entity.Installation, error) {
+	defer r.metrics.observe("installations.find_by_team_id", time.Now())
+	return r.repository.FindByTeamID(ctx, teamID)
+}
+
+func (r *installationRepository) Delete(ctx context.Context, teamID valueobject.TeamID) error {
+	defer r.metrics.observe("installations.delete", time.Now())
+	return r.repository.Delete(ctx, teamID)
+}
+
+type apiTokenRepository struct {
+	repository repository.APITokenRepository
+	metrics    *Metrics
+}
+
+func (r *apiTokenRepository) Create(ctx context.Context, token *entity.APIToken) error {
+	defer r.metrics.observe("api_tokens.create", time.Now())
+	return r.repository.Create(ctx, token)
+}
+
+func (r *apiTokenRepository) FindByHash(ctx context.Context, hash string) (*entity.APIToken, error) {
+	defer r.metrics.observe("api_tokens.find_by_hash", time.Now())
+	return r.repository.FindByHash(ctx, hash)
+}
+
+func (r *apiTokenRepository) DeleteByEventID(ctx context.Context, eventID valueobject.EventID) error {
+	defer r.metrics.observe("api_tokens.delete_by_event_id", time.Now())
+	return r.repository.DeleteByEventID(ctx, eventID)
+}
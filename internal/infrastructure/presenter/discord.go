@@ -0,0 +1,18 @@
+package presenter
+
+// Discordは :name: の書き方を絵文字にしないので、Unicodeの絵文字を使う
+var discordEmojis = map[string]string{
+	"receipt":          "🧾",
+	"purse":            "👛",
+	"money_with_wings": "💸",
+	"moneybag":         "💰",
+}
+
+// Emoji は絵文字の名前を、Discordで表示できるUnicodeの絵文字にして、後ろに空白を付ける
+func Emoji(icon string) string {
+	emoji, ok := discordEmojis[icon]
+	if !ok {
+		return ""
+	}
+	return emoji + " "
+}
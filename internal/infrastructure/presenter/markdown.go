@@ -0,0 +1,19 @@
+package presenter
+
+import "strings"
+
+// RenderSettlementMarkdown は集計結果をMarkdownにする。見出しと箇条書きだけを使う
+func RenderSettlementMarkdown(view *SettlementView, name Namer) string {
+	var b strings.Builder
+	b.WriteString("## " + view.Title + "\n")
+	for _, section := range view.Sections {
+		b.WriteString("\n### " + section.Heading + "\n\n")
+		if len(section.Lines) == 0 && section.Empty != "" {
+			b.WriteString(section.Empty + "\n")
+		}
+		for _, line := range section.Lines {
+			b.WriteString("- " + line.Format(name) + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+// Package presenter は集計結果などをプラットフォームによらない表示用の形にし、SlackやMarkdownなどの書式に変換する
+package presenter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+// Namer は利用者をプラットフォームに合わせた書き方にする。メンションを書けないところでは表示名を返す
+type Namer func(payerID valueobject.PayerID) string
+
+// Mention はSlackとDiscordで共通の、ユーザーへのメンションを作る
+func Mention(payerID valueobject.PayerID) string {
+	return fmt.Sprintf("<@%s>", payerID.UserID())
+}
+
+// SettlementView は集計結果を、どのプラットフォームでも同じ内容と順番で表示するための形
+type SettlementView struct {
+	// 絵文字を書けるところでは、見出しの前に付ける絵文字の名前
+	Icon     string
+	Title    string
+	Total    valueobject.Yen
+	Sections []SettlementSection
+}
+
+type SettlementSection struct {
+	Icon    string
+	Heading string
+	Lines   []SettlementLine
+	// 行がないときに代わりに表示する
+	Empty string
+}
+
+// SettlementLine は1人の金額や割合、または1件の送金を表す
+type SettlementLine struct {
+	PayerID valueobject.PayerID
+	// 送金のときの受け取る人。それ以外は空
+	To    valueobject.PayerID
+	Value string
+}
+
+// IsTransfer は送金を表す行かどうかを返す
+func (l SettlementLine) IsTransfer() bool {
+	return !l.To.IsUnknown()
+}
+
+// Format は行を1行の文字列にする
+func (l SettlementLine) Format(name Namer) string {
+	if l.IsTransfer() {
+		return fmt.Sprintf("%s → %s → %s", name(l.PayerID), l.Value, name(l.To))
+	}
+	return fmt.Sprintf("%s %s", name(l.PayerID), l.Value)
+}
+
+// Label は金額や割合を除いた、行の見出しにあたる部分を返す
+func (l SettlementLine) Label(name Namer) string {
+	if l.IsTransfer() {
+		return fmt.Sprintf("%s → %s", name(l.PayerID), name(l.To))
+	}
+	return name(l.PayerID)
+}
+
+// NewSettlementView は集計結果を表示用の形にする
+// 立て替えた人はマップに入っているので、毎回同じ並びになるようにIDの順に並べる
+func NewSettlementView(settlement *usecase.Settlement) *SettlementView {
+	payerIDs := make([]valueobject.PayerID, 0, len(settlement.AmountsAdvanced))
+	for payerID := range settlement.AmountsAdvanced {
+		payerIDs = append(payerIDs, payerID)
+	}
+	sort.Slice(payerIDs, func(i, j int) bool { return payerIDs[i].String() < payerIDs[j].String() })
+
+	advanced := SettlementSection{
+		Icon:    "receipt",
+		Heading: fmt.Sprintf("合計%sが立て替えられています", settlement.Total.String()),
+		Empty:   "まだ立替えはありません",
+	}
+	for _, payerID := range payerIDs {
+		advanced.Lines = append(advanced.Lines, SettlementLine{PayerID: payerID, Value: settlement.AmountsAdvanced[payerID].String()})
+	}
+
+	payers := SettlementSection{
+		Icon:    "purse",
+		Heading: fmt.Sprintf("%d人で割り勘します", len(settlement.Payers)),
+	}
+	for _, payer := range settlement.Payers {
+		payers.Lines = append(payers.Lines, SettlementLine{PayerID: payer.ID, Value: fmt.Sprintf("%d%%", payer.Weight.Int())})
+	}
+
+	instructions := SettlementSection{
+		Icon:    "money_with_wings",
+		Heading: "次のように清算してください",
+		Empty:   "清算は必要ありません",
+	}
+	for _, instruction := range settlement.Instructions {
+		instructions.Lines = append(instructions.Lines, SettlementLine{PayerID: instruction.From, To: instruction.To, Value: instruction.Amount.String()})
+	}
+
+	return &SettlementView{
+		Icon:     "moneybag",
+		Title:    "集計結果",
+		Total:    settlement.Total,
+		Sections: []SettlementSection{advanced, payers, instructions},
+	}
+}
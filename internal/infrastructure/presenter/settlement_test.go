@@ -0,0 +1,93 @@
+package presenter
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+// 表示を変えたときは go test ./internal/infrastructure/presenter -update でゴールデンファイルを作り直す
+var update = flag.Bool("update", false, "update golden files")
+
+// assertGolden はtestdata/nameの内容とactualが一致するか確かめる
+func assertGolden(t *testing.T, name string, actual string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.WriteFile(path, []byte(actual), 0o644))
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), actual)
+}
+
+func TestSettlementRenderers(t *testing.T) {
+	t.Parallel()
+
+	taro := valueobject.NewPayerID("U0001")
+	hanako := valueobject.NewPayerID("U0002")
+	jiro := valueobject.NewPayerID("U0003")
+	names := map[valueobject.PayerID]string{taro: "太郎", hanako: "花子", jiro: "次郎"}
+	name := func(payerID valueobject.PayerID) string { return names[payerID] }
+
+	tests := []struct {
+		name       string
+		settlement *usecase.Settlement
+	}{
+		{
+			name: "settlement",
+			settlement: &usecase.Settlement{
+				Total: valueobject.Yen(4000),
+				AmountsAdvanced: map[valueobject.PayerID]valueobject.Yen{
+					hanako: valueobject.Yen(1000),
+					taro:   valueobject.Yen(3000),
+				},
+				Payers: []*entity.Payer{
+					{ID: taro, Weight: valueobject.Percent(100)},
+					{ID: hanako, Weight: valueobject.Percent(100)},
+					{ID: jiro, Weight: valueobject.Percent(50)},
+				},
+				Instructions: []*usecase.SettlementInstruction{
+					{From: jiro, To: taro, Amount: valueobject.Yen(800)},
+					{From: hanako, To: taro, Amount: valueobject.Yen(600)},
+				},
+			},
+		},
+		{
+			name: "empty",
+			settlement: &usecase.Settlement{
+				AmountsAdvanced: map[valueobject.PayerID]valueobject.Yen{},
+				Payers: []*entity.Payer{
+					{ID: taro, Weight: valueobject.Percent(100)},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			view := NewSettlementView(test.settlement)
+			assertGolden(t, test.name+".txt", RenderSettlementText(view, name))
+			assertGolden(t, test.name+".md", RenderSettlementMarkdown(view, name))
+			// メンションの<>をエスケープせずに書き出して、ゴールデンファイルを読みやすくする
+			var blocks strings.Builder
+			encoder := json.NewEncoder(&blocks)
+			encoder.SetEscapeHTML(false)
+			encoder.SetIndent("", "  ")
+			require.NoError(t, encoder.Encode(RenderSettlementSlackBlocks(view, Mention)))
+			assertGolden(t, test.name+".slack.json", blocks.String())
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package presenter
+
+import (
+	"github.com/slack-go/slack"
+)
+
+// 1つのセクションに並べられるフィールドの上限
+const maxSlackSectionFields = 10
+
+// RenderSettlementSlackBlocks は集計結果をSlackのBlock Kitにする
+// 金額や割合はフィールドで2列に並べ、送金は1件ずつ別のセクションにして読みやすくする
+func RenderSettlementSlackBlocks(view *SettlementView, name Namer) []slack.Block {
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(
+			slack.NewTextBlockObject("plain_text", Shortcode(view.Icon)+view.Title, false, false),
+		),
+	}
+	for i, section := range view.Sections {
+		if i > 0 {
+			blocks = append(blocks, slack.NewDividerBlock())
+		}
+		heading := slack.NewTextBlockObject("mrkdwn", Shortcode(section.Icon)+section.Heading, false, false)
+		if len(section.Lines) == 0 {
+			text := heading.Text
+			if section.Empty != "" {
+				text += "\n" + section.Empty
+			}
+			blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", text, false, false), nil, nil))
+			continue
+		}
+		if section.Lines[0].IsTransfer() {
+			blocks = append(blocks, slack.NewSectionBlock(heading, nil, nil))
+			for _, line := range section.Lines {
+				blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", line.Format(name), false, false), nil, nil))
+			}
+			continue
+		}
+		fields := make([]*slack.TextBlockObject, 0, len(section.Lines))
+		for _, line := range section.Lines {
+			fields = append(fields, slack.NewTextBlockObject("mrkdwn", line.Format(name), false, false))
+		}
+		// フィールドが多いときは、見出しのないセクションに続けて並べる
+		blocks = append(blocks, slack.NewSectionBlock(heading, fields[:min(len(fields), maxSlackSectionFields)], nil))
+		for start := maxSlackSectionFields; start < len(fields); start += maxSlackSectionFields {
+			blocks = append(blocks, slack.NewSectionBlock(nil, fields[start:min(len(fields), start+maxSlackSectionFields)], nil))
+		}
+	}
+	return blocks
+}
+
+// Shortcode は絵文字の名前を、Slackで使える :name: の書き方にして、後ろに空白を付ける
+func Shortcode(icon string) string {
+	if icon == "" {
+		return ""
+	}
+	return ":" + icon + ": "
+}
@@ -0,0 +1,19 @@
+package presenter
+
+import "strings"
+
+// RenderSettlementText は集計結果を装飾のない文章にする
+func RenderSettlementText(view *SettlementView, name Namer) string {
+	var b strings.Builder
+	b.WriteString(view.Title + "\n")
+	for _, section := range view.Sections {
+		b.WriteString("\n" + section.Heading + "\n")
+		if len(section.Lines) == 0 && section.Empty != "" {
+			b.WriteString("  " + section.Empty + "\n")
+		}
+		for _, line := range section.Lines {
+			b.WriteString("  " + line.Format(name) + "\n")
+		}
+	}
+	return b.String()
+}
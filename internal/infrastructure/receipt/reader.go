@@ -0,0 +1,36 @@
+package receipt
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+// OCR はレシート画像から文字列を読み取るアダプタ
+type OCR interface {
+	Recognize(ctx context.Context, image io.Reader) (string, error)
+}
+
+type Reader struct {
+	ocr OCR
+}
+
+func NewReader(ocr OCR) *Reader {
+	return &Reader{
+		ocr: ocr,
+	}
+}
+
+func (r *Reader) ReadTotal(ctx context.Context, image io.Reader) (valueobject.Yen, error) {
+	text, err := r.ocr.Recognize(ctx, image)
+	if err != nil {
+		return valueobject.Yen(0), fmt.Errorf("failed to recognize receipt: %w", err)
+	}
+	total, ok := findTotal(text)
+	if !ok {
+		return valueobject.Yen(0), valueobject.NewErrorNotFound("total not found in receipt", nil)
+	}
+	return total, nil
+}
@@ -0,0 +1,34 @@
+package receipt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+type Tesseract struct {
+	command   string
+	languages string
+}
+
+func NewTesseract(command string, languages string) *Tesseract {
+	return &Tesseract{
+		command:   command,
+		languages: languages,
+	}
+}
+
+func (t *Tesseract) Recognize(ctx context.Context, image io.Reader) (string, error) {
+	var stdout, stderr bytes.Buffer
+	// --psm 6 はレシートのような一段組みのテキストブロックを想定したモード
+	cmd := exec.CommandContext(ctx, t.command, "stdin", "stdout", "-l", t.languages, "--psm", "6")
+	cmd.Stdin = image
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract failed: %w (%s)", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,78 @@
+package receipt
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/width"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+var (
+	grandTotalPattern = regexp.MustCompile(`総合計|お買上げ?計|お買い?上げ?合計`)
+	totalPattern      = regexp.MustCompile(`合計|(?i:total)`)
+	// 合計の近くに印字されるが、支払総額ではない行
+	excludedPattern = regexp.MustCompile(`小計|対象|消費税|内税|外税|税額|預|釣|値引|割引`)
+	// 点数や税率は金額と紛らわしいので取り除く
+	noisePattern  = regexp.MustCompile(`\d+\s*点|\d+(?:\.\d+)?\s*%`)
+	amountPattern = regexp.MustCompile(`\d{1,3}(?:[,.]\d{3})+|\d+`)
+)
+
+// findTotal はOCRで読み取ったレシートの文字列から支払総額を推定する
+func findTotal(text string) (valueobject.Yen, bool) {
+	lines := strings.Split(width.Fold.String(text), "\n")
+
+	found := false
+	var total valueobject.Yen
+	bestPriority := 0
+	for i, line := range lines {
+		// OCRは文字間に空白を挟みがちなので、キーワードは空白を除いて照合する
+		compact := strings.Join(strings.Fields(line), "")
+		if excludedPattern.MatchString(compact) {
+			continue
+		}
+		priority := 0
+		switch {
+		case grandTotalPattern.MatchString(compact):
+			priority = 2
+		case totalPattern.MatchString(compact):
+			priority = 1
+		default:
+			continue
+		}
+
+		amount, ok := lastAmount(line)
+		if !ok && i+1 < len(lines) {
+			// 金額だけが次の行に分かれて読み取られることがある
+			amount, ok = lastAmount(lines[i+1])
+		}
+		if !ok {
+			continue
+		}
+		if priority > bestPriority || (priority == bestPriority && amount > total) {
+			total = amount
+			bestPriority = priority
+			found = true
+		}
+	}
+	return total, found
+}
+
+func lastAmount(line string) (valueobject.Yen, bool) {
+	matches := amountPattern.FindAllString(noisePattern.ReplaceAllString(line, ""), -1)
+	if len(matches) == 0 {
+		return valueobject.Yen(0), false
+	}
+	raw := strings.NewReplacer(",", "", ".", "").Replace(matches[len(matches)-1])
+	amount, err := strconv.Atoi(raw)
+	if err != nil {
+		return valueobject.Yen(0), false
+	}
+	yen, err := valueobject.NewYen(amount)
+	if err != nil || yen == 0 {
+		return valueobject.Yen(0), false
+	}
+	return yen, true
+}
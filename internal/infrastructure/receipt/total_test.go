@@ -0,0 +1,72 @@
+package receipt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+func TestFindTotal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		text          string
+		expectedTotal valueobject.Yen
+		expectedFound bool
+	}{
+		{
+			name:          "OK: simple receipt",
+			text:          "コンビニ\nおにぎり ¥150\nお茶 ¥130\n小計 ¥280\n合計 ¥302\nお預り ¥1,000\nお釣り ¥698\n",
+			expectedTotal: valueobject.Yen(302),
+			expectedFound: true,
+		},
+		{
+			name:          "OK: full-width characters and spaces inserted by OCR",
+			text:          "合　計　　￥１，２３４\n",
+			expectedTotal: valueobject.Yen(1234),
+			expectedFound: true,
+		},
+		{
+			name:          "OK: item count on the total line",
+			text:          "合計 3点 ¥4,980\n",
+			expectedTotal: valueobject.Yen(4980),
+			expectedFound: true,
+		},
+		{
+			name:          "OK: tax breakdown is ignored",
+			text:          "(10%対象 ¥2,000)\n(内消費税等 ¥181)\n合計 ¥2,000\n",
+			expectedTotal: valueobject.Yen(2000),
+			expectedFound: true,
+		},
+		{
+			name:          "OK: grand total is preferred",
+			text:          "合計 ¥10,000\nサービス料 ¥1,000\n総合計 ¥11,000\n",
+			expectedTotal: valueobject.Yen(11000),
+			expectedFound: true,
+		},
+		{
+			name:          "OK: amount on the next line",
+			text:          "合計\n¥3,300\n",
+			expectedTotal: valueobject.Yen(3300),
+			expectedFound: true,
+		},
+		{
+			name:          "NG: no total line",
+			text:          "おにぎり ¥150\nお茶 ¥130\n",
+			expectedFound: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			total, found := findTotal(test.text)
+			assert.Equal(t, test.expectedFound, found, "found mismatch")
+			assert.Equal(t, test.expectedTotal, total, "total mismatch")
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
+)
+
+type APITokenRepository struct {
+	q sqldb.Querier
+}
+
+func newAPITokenRepository(q sqldb.Querier) *APITokenRepository {
+	return &APITokenRepository{
+		q: q,
+	}
+}
+
+func (r *APITokenRepository) Create(ctx context.Context, token *entity.APIToken) error {
+	_, err := r.q.ExecContext(ctx, "INSERT INTO api_tokens (hash, event_id, created_at) VALUES (?, ?, ?)",
+		token.Hash,
+		token.EventID.String(),
+		token.CreatedAt.Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+func (r *APITokenRepository) FindByHash(ctx context.Context, hash string) (*entity.APIToken, error) {
+	var rawHash, rawEventID, rawCreatedAt string
+	err := r.q.QueryRowContext(ctx, "SELECT hash, event_id, created_at FROM api_tokens WHERE hash = ?", hash).Scan(&rawHash, &rawEventID, &rawCreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, valueobject.NewErrorNotFound("api token not found", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, rawCreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.APIToken{
+		Hash:      rawHash,
+		EventID:   valueobject.NewEventID(rawEventID),
+		CreatedAt: createdAt,
+	}, nil
+}
+
+func (r *APITokenRepository) DeleteByEventID(ctx context.Context, eventID valueobject.EventID) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM api_tokens WHERE event_id = ?", eventID.String())
+	return err
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
+)
+
+type DeliveryRepository struct {
+	q sqldb.Querier
+}
+
+func newDeliveryRepository(q sqldb.Querier) *DeliveryRepository {
+	return &DeliveryRepository{
+		q: q,
+	}
+}
+
+func (r *DeliveryRepository) Create(ctx context.Context, key string, receivedAt time.Time) error {
+	_, err := r.q.ExecContext(ctx, "INSERT INTO deliveries (key, received_at) VALUES (?, ?)", key, receivedAt.UnixNano())
+	if sqliteErr := new(sqlite3.Error); errors.As(err, sqliteErr) {
+		if sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return valueobject.NewErrorAlreadyExists("delivery already exists", err)
+		}
+	}
+	return err
+}
+
+func (r *DeliveryRepository) Complete(ctx context.Context, key string) error {
+	_, err := r.q.ExecContext(ctx, "UPDATE deliveries SET completed = 1 WHERE key = ?", key)
+	return err
+}
+
+func (r *DeliveryRepository) IsCompleted(ctx context.Context, key string) (bool, error) {
+	var completed bool
+	err := r.q.QueryRowContext(ctx, "SELECT completed FROM deliveries WHERE key = ?", key).Scan(&completed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, valueobject.NewErrorNotFound("delivery not found", err)
+	}
+	return completed, err
+}
+
+func (r *DeliveryRepository) Delete(ctx context.Context, key string) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM deliveries WHERE key = ?", key)
+	return err
+}
+
+func (r *DeliveryRepository) DeleteBefore(ctx context.Context, before time.Time) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM deliveries WHERE received_at < ?", before.UnixNano())
+	return err
+}
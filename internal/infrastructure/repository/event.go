@@ -1,40 +1,100 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-
-	_ "github.com/mattn/go-sqlite3"
+	"errors"
 
 	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
 )
 
 type EventRepository struct {
-	db *sql.DB
+	q sqldb.Querier
+}
+
+func newEventRepository(q sqldb.Querier) *EventRepository {
+	return &EventRepository{
+		q: q,
+	}
 }
 
-func NewEventRepository(filename string) (*EventRepository, error) {
-	db, err := sql.Open("sqlite3", filename)
+func (r *EventRepository) CreateIfNotExists(ctx context.Context, event *entity.Event) error {
+	_, err := r.q.ExecContext(ctx, "INSERT OR IGNORE INTO events (id, organizer_id) VALUES (?, ?)",
+		event.ID.String(),
+		event.OrganizerID.String(),
+	)
+	return err
+}
+
+func (r *EventRepository) FindByID(ctx context.Context, eventID valueobject.EventID) (*entity.Event, error) {
+	var rawID, rawOrganizerID string
+	err := r.q.QueryRowContext(ctx, "SELECT id, organizer_id FROM events WHERE id = ?", eventID.String()).Scan(&rawID, &rawOrganizerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, valueobject.NewErrorNotFound("event not found", err)
+	}
 	if err != nil {
 		return nil, err
 	}
+	return &entity.Event{
+		ID:          valueobject.NewEventID(rawID),
+		OrganizerID: valueobject.NewPayerID(rawOrganizerID),
+	}, nil
+}
 
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS events (
-			id TEXT PRIMARY KEY
-		);
-	`)
+func (r *EventRepository) FindAll(ctx context.Context) ([]*entity.Event, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT id, organizer_id FROM events ORDER BY id")
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &EventRepository{
-		db: db,
-	}, nil
+	var events []*entity.Event
+	for rows.Next() {
+		var rawID, rawOrganizerID string
+		if err := rows.Scan(&rawID, &rawOrganizerID); err != nil {
+			return nil, err
+		}
+		events = append(events, &entity.Event{
+			ID:          valueobject.NewEventID(rawID),
+			OrganizerID: valueobject.NewPayerID(rawOrganizerID),
+		})
+	}
+	return events, nil
 }
 
-func (r *EventRepository) CreateIfNotExists(event *entity.Event) error {
-	_, err := r.db.Exec("INSERT OR IGNORE INTO events (id) VALUES (?)",
-		event.ID.String(),
-	)
-	return err
+func (r *EventRepository) AssignTeam(ctx context.Context, teamID valueobject.TeamID) (int, error) {
+	var assigned int64
+	err := sqldb.Transact(ctx, r.q, func(q sqldb.Querier) error {
+		// ワークスペースを区別する前のIDはチャンネルIDだけで、区切りの ":" を含まない
+		result, err := q.ExecContext(ctx, "UPDATE events SET id = ? || ':' || id WHERE id NOT LIKE '%:%'", teamID.Namespace())
+		if err != nil {
+			return err
+		}
+		assigned, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		for _, table := range []string{"payers", "payments", "audit_logs", "api_tokens"} {
+			_, err := q.ExecContext(ctx, "UPDATE "+table+" SET event_id = ? || ':' || event_id WHERE event_id NOT LIKE '%:%'", teamID.Namespace())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return int(assigned), err
+}
+
+func (r *EventRepository) Move(ctx context.Context, from valueobject.EventID, to valueobject.EventID) error {
+	return sqldb.Transact(ctx, r.q, func(q sqldb.Querier) error {
+		for _, table := range []string{"payers", "payments", "audit_logs", "api_tokens"} {
+			if _, err := q.ExecContext(ctx, "UPDATE "+table+" SET event_id = ? WHERE event_id = ?", to.String(), from.String()); err != nil {
+				return err
+			}
+		}
+		_, err := q.ExecContext(ctx, "UPDATE events SET id = ? WHERE id = ?", to.String(), from.String())
+		return err
+	})
 }
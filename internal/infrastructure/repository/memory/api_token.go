@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+type APITokenRepository struct {
+	store *Store
+}
+
+func (r *APITokenRepository) Create(ctx context.Context, token *entity.APIToken) error {
+	return r.store.write(ctx, func(state *state) error {
+		if _, ok := state.apiTokens[token.Hash]; ok {
+			return valueobject.NewErrorAlreadyExists("api token already exists", nil)
+		}
+		state.apiTokens[token.Hash] = *token
+		return nil
+	})
+}
+
+func (r *APITokenRepository) FindByHash(ctx context.Context, hash string) (*entity.APIToken, error) {
+	var token entity.APIToken
+	err := r.store.read(ctx, func(state *state) error {
+		found, ok := state.apiTokens[hash]
+		if !ok {
+			return valueobject.NewErrorNotFound("api token not found", nil)
+		}
+		token = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *APITokenRepository) DeleteByEventID(ctx context.Context, eventID valueobject.EventID) error {
+	return r.store.write(ctx, func(state *state) error {
+		for hash, token := range state.apiTokens {
+			if token.EventID == eventID {
+				delete(state.apiTokens, hash)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"context"
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+type AuditLogRepository struct {
+	store *Store
+}
+
+func (r *AuditLogRepository) Append(ctx context.Context, log *entity.AuditLog) error {
+	return r.store.write(ctx, func(state *state) error {
+		state.auditLogs = append(state.auditLogs, *log)
+		return nil
+	})
+}
+
+func (r *AuditLogRepository) FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.AuditLog, error) {
+	var logs []*entity.AuditLog
+	err := r.store.read(ctx, func(state *state) error {
+		for _, log := range state.auditLogs {
+			if log.EventID == eventID {
+				logs = append(logs, &log)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
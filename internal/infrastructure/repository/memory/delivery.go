@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+type DeliveryRepository struct {
+	store *Store
+}
+
+func (r *DeliveryRepository) Create(ctx context.Context, key string, receivedAt time.Time) error {
+	return r.store.write(ctx, func(state *state) error {
+		if _, ok := state.deliveries[key]; ok {
+			return valueobject.NewErrorAlreadyExists("delivery already exists", nil)
+		}
+		state.deliveries[key] = deliveryRecord{receivedAt: receivedAt}
+		return nil
+	})
+}
+
+func (r *DeliveryRepository) Complete(ctx context.Context, key string) error {
+	return r.store.write(ctx, func(state *state) error {
+		record, ok := state.deliveries[key]
+		if !ok {
+			return nil
+		}
+		record.completed = true
+		state.deliveries[key] = record
+		return nil
+	})
+}
+
+func (r *DeliveryRepository) IsCompleted(ctx context.Context, key string) (bool, error) {
+	var completed bool
+	err := r.store.read(ctx, func(state *state) error {
+		record, ok := state.deliveries[key]
+		if !ok {
+			return valueobject.NewErrorNotFound("delivery not found", nil)
+		}
+		completed = record.completed
+		return nil
+	})
+	return completed, err
+}
+
+func (r *DeliveryRepository) Delete(ctx context.Context, key string) error {
+	return r.store.write(ctx, func(state *state) error {
+		delete(state.deliveries, key)
+		return nil
+	})
+}
+
+func (r *DeliveryRepository) DeleteBefore(ctx context.Context, before time.Time) error {
+	return r.store.write(ctx, func(state *state) error {
+		for key, record := range state.deliveries {
+			if record.receivedAt.Before(before) {
+				delete(state.deliveries, key)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+type EventRepository struct {
+	store *Store
+}
+
+func (r *EventRepository) CreateIfNotExists(ctx context.Context, event *entity.Event) error {
+	return r.store.write(ctx, func(state *state) error {
+		if _, ok := state.events[event.ID]; !ok {
+			state.events[event.ID] = *event
+		}
+		return nil
+	})
+}
+
+func (r *EventRepository) FindByID(ctx context.Context, eventID valueobject.EventID) (*entity.Event, error) {
+	var event entity.Event
+	err := r.store.read(ctx, func(state *state) error {
+		found, ok := state.events[eventID]
+		if !ok {
+			return valueobject.NewErrorNotFound("event not found", nil)
+		}
+		event = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *EventRepository) FindAll(ctx context.Context) ([]*entity.Event, error) {
+	var events []*entity.Event
+	err := r.store.read(ctx, func(state *state) error {
+		for _, found := range state.events {
+			event := found
+			events = append(events, &event)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID.String() < events[j].ID.String()
+	})
+	return events, nil
+}
+
+func (r *EventRepository) AssignTeam(ctx context.Context, teamID valueobject.TeamID) (int, error) {
+	assign := func(eventID valueobject.EventID) (valueobject.EventID, bool) {
+		if !eventID.IsLegacy() {
+			return eventID, false
+		}
+		return valueobject.NewChannelEventID(teamID, eventID.ChannelID()), true
+	}
+
+	var assigned int
+	err := r.store.write(ctx, func(state *state) error {
+		for id, event := range state.events {
+			if newID, ok := assign(id); ok {
+				delete(state.events, id)
+				event.ID = newID
+				state.events[newID] = event
+				assigned++
+			}
+		}
+		for key, record := range state.payers {
+			if newID, ok := assign(key.eventID); ok {
+				delete(state.payers, key)
+				record.payer.EventID = newID
+				state.payers[payerKey{newID, key.payerID}] = record
+			}
+		}
+		for id, record := range state.payments {
+			record.payment.EventID, _ = assign(record.payment.EventID)
+			state.payments[id] = record
+		}
+		for i := range state.auditLogs {
+			state.auditLogs[i].EventID, _ = assign(state.auditLogs[i].EventID)
+		}
+		for hash, token := range state.apiTokens {
+			token.EventID, _ = assign(token.EventID)
+			state.apiTokens[hash] = token
+		}
+		return nil
+	})
+	return assigned, err
+}
+
+func (r *EventRepository) Move(ctx context.Context, from valueobject.EventID, to valueobject.EventID) error {
+	return r.store.write(ctx, func(state *state) error {
+		if event, ok := state.events[from]; ok {
+			delete(state.events, from)
+			event.ID = to
+			state.events[to] = event
+		}
+		for key, record := range state.payers {
+			if key.eventID == from {
+				delete(state.payers, key)
+				record.payer.EventID = to
+				state.payers[payerKey{to, key.payerID}] = record
+			}
+		}
+		for id, record := range state.payments {
+			if record.payment.EventID == from {
+				record.payment.EventID = to
+				state.payments[id] = record
+			}
+		}
+		for i := range state.auditLogs {
+			if state.auditLogs[i].EventID == from {
+				state.auditLogs[i].EventID = to
+			}
+		}
+		for hash, token := range state.apiTokens {
+			if token.EventID == from {
+				token.EventID = to
+				state.apiTokens[hash] = token
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+type InstallationRepository struct {
+	store *Store
+}
+
+func (r *InstallationRepository) Save(ctx context.Context, installation *entity.Installation) error {
+	return r.store.write(ctx, func(state *state) error {
+		// SQLと同じく、ワークスペース（組織全体のときは組織）のIDで区別する
+		state.installations[installation.TeamID.String()] = *installation
+		return nil
+	})
+}
+
+func (r *InstallationRepository) FindByTeamID(ctx context.Context, teamID valueobject.TeamID) (*entity.Installation, error) {
+	var installation entity.Installation
+	err := r.store.read(ctx, func(state *state) error {
+		found, ok := state.installations[teamID.String()]
+		if !ok {
+			return valueobject.NewErrorNotFound("installation not found", nil)
+		}
+		installation = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &installation, nil
+}
+
+func (r *InstallationRepository) Delete(ctx context.Context, teamID valueobject.TeamID) error {
+	return r.store.write(ctx, func(state *state) error {
+		delete(state.installations, teamID.String())
+		return nil
+	})
+}
@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+type PayerRepository struct {
+	store *Store
+}
+
+func (r *PayerRepository) Create(ctx context.Context, payer *entity.Payer) error {
+	return r.store.write(ctx, func(state *state) error {
+		key := payerKey{payer.EventID, payer.ID}
+		if _, ok := state.payers[key]; ok {
+			return valueobject.NewErrorAlreadyExists("payer already exists", nil)
+		}
+		state.payers[key] = payerRecord{payer: *payer, seq: state.nextSeq()}
+		return nil
+	})
+}
+
+func (r *PayerRepository) CreateIfNotExists(ctx context.Context, payer *entity.Payer) error {
+	return r.store.write(ctx, func(state *state) error {
+		key := payerKey{payer.EventID, payer.ID}
+		if _, ok := state.payers[key]; !ok {
+			state.payers[key] = payerRecord{payer: *payer, seq: state.nextSeq()}
+		}
+		return nil
+	})
+}
+
+func (r *PayerRepository) Update(ctx context.Context, payer *entity.Payer) error {
+	return r.store.write(ctx, func(state *state) error {
+		key := payerKey{payer.EventID, payer.ID}
+		record, ok := state.payers[key]
+		if !ok {
+			return valueobject.NewErrorNotFound("payer not found", nil)
+		}
+		record.payer.Weight = payer.Weight
+		state.payers[key] = record
+		return nil
+	})
+}
+
+func (r *PayerRepository) Delete(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID) error {
+	return r.store.write(ctx, func(state *state) error {
+		delete(state.payers, payerKey{eventID, payerID})
+		return nil
+	})
+}
+
+func (r *PayerRepository) FindByID(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID) (*entity.Payer, error) {
+	var payer entity.Payer
+	err := r.store.read(ctx, func(state *state) error {
+		record, ok := state.payers[payerKey{eventID, payerID}]
+		if !ok {
+			return valueobject.NewErrorNotFound("payer not found", nil)
+		}
+		payer = record.payer
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &payer, nil
+}
+
+func (r *PayerRepository) FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.Payer, error) {
+	var records []payerRecord
+	err := r.store.read(ctx, func(state *state) error {
+		for _, record := range state.payers {
+			if record.payer.EventID == eventID {
+				records = append(records, record)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].seq < records[j].seq
+	})
+
+	var payers []*entity.Payer
+	for _, record := range records {
+		payer := record.payer
+		payers = append(payers, &payer)
+	}
+	return payers, nil
+}
+
+func (r *PayerRepository) Rename(ctx context.Context, from valueobject.PayerID, to valueobject.PayerID) error {
+	return r.store.write(ctx, func(state *state) error {
+		rename := func(eventID valueobject.EventID, payerID valueobject.PayerID) valueobject.PayerID {
+			if payerID == from && eventID.IsSlack() {
+				return to
+			}
+			return payerID
+		}
+		for key, record := range state.payers {
+			if renamed := rename(key.eventID, key.payerID); renamed != key.payerID {
+				delete(state.payers, key)
+				record.payer.ID = renamed
+				state.payers[payerKey{key.eventID, renamed}] = record
+			}
+		}
+		for id, record := range state.payments {
+			eventID := record.payment.EventID
+			record.payment.PayerID = rename(eventID, record.payment.PayerID)
+			// 対象者のスライスは取り消すときのために元の状態と共有しているので、作り直す
+			beneficiaries := make([]valueobject.PayerID, 0, len(record.payment.Beneficiaries))
+			for _, beneficiaryID := range record.payment.Beneficiaries {
+				beneficiaries = append(beneficiaries, rename(eventID, beneficiaryID))
+			}
+			if record.payment.Beneficiaries != nil {
+				record.payment.Beneficiaries = beneficiaries
+			}
+			state.payments[id] = record
+		}
+		for i := range state.auditLogs {
+			eventID := state.auditLogs[i].EventID
+			state.auditLogs[i].ActorID = rename(eventID, state.auditLogs[i].ActorID)
+			state.auditLogs[i].PayerID = rename(eventID, state.auditLogs[i].PayerID)
+		}
+		for id, event := range state.events {
+			event.OrganizerID = rename(id, event.OrganizerID)
+			state.events[id] = event
+		}
+		return nil
+	})
+}
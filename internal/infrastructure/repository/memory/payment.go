@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+type PaymentRepository struct {
+	store *Store
+}
+
+// copyPayment は呼び出し元と対象者のスライスを共有しないように複製する
+func copyPayment(payment entity.Payment) *entity.Payment {
+	payment.Beneficiaries = append([]valueobject.PayerID(nil), payment.Beneficiaries...)
+	return &payment
+}
+
+func (r *PaymentRepository) Create(ctx context.Context, payment *entity.Payment) error {
+	return r.store.write(ctx, func(state *state) error {
+		if _, ok := state.payments[payment.ID]; ok {
+			return valueobject.NewErrorAlreadyExists("payment already exists", nil)
+		}
+		state.payments[payment.ID] = paymentRecord{payment: *copyPayment(*payment), seq: state.nextSeq(), createdAt: time.Now()}
+		return nil
+	})
+}
+
+func (r *PaymentRepository) Update(ctx context.Context, payment *entity.Payment) error {
+	return r.store.write(ctx, func(state *state) error {
+		record, ok := state.payments[payment.ID]
+		if !ok || record.deletedAt != nil {
+			return valueobject.NewErrorNotFound("payment not found", nil)
+		}
+		record.payment.Amount = payment.Amount
+		record.payment.Memo = payment.Memo
+		state.payments[payment.ID] = record
+		return nil
+	})
+}
+
+func (r *PaymentRepository) Delete(ctx context.Context, paymentID valueobject.PaymentID) error {
+	return r.store.write(ctx, func(state *state) error {
+		record, ok := state.payments[paymentID]
+		if !ok || record.deletedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		record.deletedAt = &now
+		state.payments[paymentID] = record
+		return nil
+	})
+}
+
+func (r *PaymentRepository) Restore(ctx context.Context, paymentID valueobject.PaymentID) error {
+	return r.store.write(ctx, func(state *state) error {
+		record, ok := state.payments[paymentID]
+		if !ok || record.deletedAt == nil {
+			return valueobject.NewErrorNotFound("deleted payment not found", nil)
+		}
+		record.deletedAt = nil
+		state.payments[paymentID] = record
+		return nil
+	})
+}
+
+func (r *PaymentRepository) FindByID(ctx context.Context, paymentID valueobject.PaymentID) (*entity.Payment, error) {
+	var payment *entity.Payment
+	err := r.store.read(ctx, func(state *state) error {
+		record, ok := state.payments[paymentID]
+		if !ok || record.deletedAt != nil {
+			return valueobject.NewErrorNotFound("payment not found", nil)
+		}
+		payment = copyPayment(record.payment)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+func (r *PaymentRepository) FindLastDeleted(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID, since time.Time) (*entity.Payment, error) {
+	var payment *entity.Payment
+	err := r.store.read(ctx, func(state *state) error {
+		var last *paymentRecord
+		for _, record := range state.payments {
+			if record.payment.EventID != eventID || record.payment.PayerID != payerID {
+				continue
+			}
+			if record.deletedAt == nil || record.deletedAt.Before(since) {
+				continue
+			}
+			if last == nil || record.deletedAt.After(*last.deletedAt) {
+				last = &record
+			}
+		}
+		if last == nil {
+			return valueobject.NewErrorNotFound("payment not found", nil)
+		}
+		payment = copyPayment(last.payment)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+func (r *PaymentRepository) FindLastSimilar(ctx context.Context, payment *entity.Payment, since time.Time) (*entity.Payment, error) {
+	var similar *entity.Payment
+	err := r.store.read(ctx, func(state *state) error {
+		var last *paymentRecord
+		for _, record := range state.payments {
+			if record.payment.EventID != payment.EventID || record.payment.PayerID != payment.PayerID {
+				continue
+			}
+			if record.payment.Amount != payment.Amount || record.payment.Memo != payment.Memo {
+				continue
+			}
+			if record.deletedAt != nil || record.createdAt.Before(since) {
+				continue
+			}
+			if last == nil || record.seq > last.seq {
+				last = &record
+			}
+		}
+		if last == nil {
+			return valueobject.NewErrorNotFound("payment not found", nil)
+		}
+		similar = copyPayment(last.payment)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return similar, nil
+}
+
+func (r *PaymentRepository) FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.Payment, error) {
+	var records []paymentRecord
+	err := r.store.read(ctx, func(state *state) error {
+		for _, record := range state.payments {
+			if record.payment.EventID == eventID && record.deletedAt == nil {
+				records = append(records, record)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].seq < records[j].seq
+	})
+
+	var payments []*entity.Payment
+	for _, record := range records {
+		payments = append(payments, copyPayment(record.payment))
+	}
+	return payments, nil
+}
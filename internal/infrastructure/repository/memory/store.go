@@ -0,0 +1,198 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/repository"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+type paymentRecord struct {
+	payment   entity.Payment
+	seq       int
+	createdAt time.Time
+	deletedAt *time.Time
+}
+
+// payerKey は参加者を割り勘ごとに区別する。同じ利用者が複数の割り勘に参加できる
+type payerKey struct {
+	eventID valueobject.EventID
+	payerID valueobject.PayerID
+}
+
+type payerRecord struct {
+	payer entity.Payer
+	seq   int
+}
+
+type deliveryRecord struct {
+	receivedAt time.Time
+	completed  bool
+}
+
+type state struct {
+	events        map[valueobject.EventID]entity.Event
+	payers        map[payerKey]payerRecord
+	payments      map[valueobject.PaymentID]paymentRecord
+	auditLogs     []entity.AuditLog
+	deliveries    map[string]deliveryRecord
+	installations map[string]entity.Installation
+	// トークンのハッシュをキーにする
+	apiTokens map[string]entity.APIToken
+	// 登録順に並べるための連番
+	seq int
+}
+
+func newState() *state {
+	return &state{
+		events:        make(map[valueobject.EventID]entity.Event),
+		payers:        make(map[payerKey]payerRecord),
+		payments:      make(map[valueobject.PaymentID]paymentRecord),
+		deliveries:    make(map[string]deliveryRecord),
+		installations: make(map[string]entity.Installation),
+		apiTokens:     make(map[string]entity.APIToken),
+	}
+}
+
+func (s *state) clone() *state {
+	cloned := &state{
+		events:        make(map[valueobject.EventID]entity.Event, len(s.events)),
+		payers:        make(map[payerKey]payerRecord, len(s.payers)),
+		payments:      make(map[valueobject.PaymentID]paymentRecord, len(s.payments)),
+		auditLogs:     append([]entity.AuditLog(nil), s.auditLogs...),
+		deliveries:    make(map[string]deliveryRecord, len(s.deliveries)),
+		installations: make(map[string]entity.Installation, len(s.installations)),
+		apiTokens:     make(map[string]entity.APIToken, len(s.apiTokens)),
+		seq:           s.seq,
+	}
+	for id, event := range s.events {
+		cloned.events[id] = event
+	}
+	for key, record := range s.payers {
+		cloned.payers[key] = record
+	}
+	for id, record := range s.payments {
+		cloned.payments[id] = record
+	}
+	for key, record := range s.deliveries {
+		cloned.deliveries[key] = record
+	}
+	for teamID, installation := range s.installations {
+		cloned.installations[teamID] = installation
+	}
+	for hash, token := range s.apiTokens {
+		cloned.apiTokens[hash] = token
+	}
+	return cloned
+}
+
+func (s *state) nextSeq() int {
+	s.seq++
+	return s.seq
+}
+
+// Store はデータをメモリ上に保持する。テストやデモ向けで、プロセスが終了するとデータは消える
+type Store struct {
+	mu    *sync.RWMutex
+	state *state
+	// トランザクション中は呼び出し元がロックを握っている
+	inTransaction bool
+}
+
+func NewStore() *Store {
+	return &Store{
+		mu:    &sync.RWMutex{},
+		state: newState(),
+	}
+}
+
+// Ping と Close は他のデータベース実装と同じように扱えるようにするためのもので、何もしない
+func (s *Store) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+// read と write は、SQLのドライバと同じく処理の前にキャンセルを確認する
+func (s *Store) read(ctx context.Context, fn func(state *state) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !s.inTransaction {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+	return fn(s.state)
+}
+
+func (s *Store) write(ctx context.Context, fn func(state *state) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !s.inTransaction {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	return fn(s.state)
+}
+
+func (s *Store) Events() repository.EventRepository {
+	return &EventRepository{s}
+}
+
+func (s *Store) Payers() repository.PayerRepository {
+	return &PayerRepository{s}
+}
+
+func (s *Store) Payments() repository.PaymentRepository {
+	return &PaymentRepository{s}
+}
+
+func (s *Store) AuditLogs() repository.AuditLogRepository {
+	return &AuditLogRepository{s}
+}
+
+func (s *Store) Deliveries() repository.DeliveryRepository {
+	return &DeliveryRepository{s}
+}
+
+func (s *Store) Installations() repository.InstallationRepository {
+	return &InstallationRepository{s}
+}
+
+func (s *Store) APITokens() repository.APITokenRepository {
+	return &APITokenRepository{s}
+}
+
+// Transaction は複製した状態の上でfnを実行し、成功したときだけ置き換える
+func (s *Store) Transaction(ctx context.Context, fn func(store repository.Store) error) error {
+	if s.inTransaction {
+		return fn(s)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &Store{
+		mu:            s.mu,
+		state:         s.state.clone(),
+		inTransaction: true,
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	// 途中でキャンセルされたときはコミットしない
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.state = tx.state
+	return nil
+}
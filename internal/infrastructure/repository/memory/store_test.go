@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/repository"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/repositorytest"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	repositorytest.Run(t, func(t *testing.T) repository.Store {
+		return NewStore()
+	})
+}
+
+func TestStoreConcurrency(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	store := NewStore()
+	eventID := valueobject.NewEventID("C0001")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.Transaction(ctx, func(store repository.Store) error {
+				if err := store.Events().CreateIfNotExists(ctx, &entity.Event{ID: eventID}); err != nil {
+					return err
+				}
+				return store.Payments().Create(ctx, &entity.Payment{ID: valueobject.NewPaymentID(), EventID: eventID, Amount: valueobject.Yen(100)})
+			})
+			assert.NoError(t, err)
+			_, err = store.Payments().FindByEventID(ctx, eventID)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	payments, err := store.Payments().FindByEventID(ctx, eventID)
+	require.NoError(t, err)
+	assert.Len(t, payments, 50)
+}
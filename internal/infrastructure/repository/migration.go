@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var dialect = sqldb.Dialect{
+	CreateTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TEXT NOT NULL DEFAULT (DATETIME('now', 'localtime'))
+		);
+	`,
+	Exists: "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = ?)",
+	Insert: "INSERT INTO schema_migrations (version, name) VALUES (?, ?)",
+}
+
+// Migrate はまだ適用していないマイグレーションを順に適用する
+func Migrate(db *sql.DB) error {
+	migrations, err := sqldb.LoadMigrations(migrationFiles)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return sqldb.Migrate(db, dialect, migrations)
+}
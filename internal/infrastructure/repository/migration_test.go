@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "database.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func appliedVersions(t *testing.T, db *sql.DB) []int {
+	t.Helper()
+
+	rows, err := db.Query("SELECT version FROM schema_migrations ORDER BY version ASC")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		require.NoError(t, rows.Scan(&version))
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+func TestLoadMigrations(t *testing.T) {
+	t.Parallel()
+
+	migrations, err := sqldb.LoadMigrations(migrationFiles)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version, "migration versions must be sequential")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+
+	migrations, err := sqldb.LoadMigrations(migrationFiles)
+	require.NoError(t, err)
+	latest := make([]int, 0, len(migrations))
+	for _, m := range migrations {
+		latest = append(latest, m.Version)
+	}
+
+	t.Run("OK: empty database", func(t *testing.T) {
+		t.Parallel()
+
+		db := openTestDB(t)
+		require.NoError(t, Migrate(db))
+		assert.Equal(t, latest, appliedVersions(t, db))
+	})
+
+	t.Run("OK: baseline database keeps its data", func(t *testing.T) {
+		t.Parallel()
+
+		db := openTestDB(t)
+		fixture, err := os.ReadFile(filepath.Join("testdata", "baseline.sql"))
+		require.NoError(t, err)
+		_, err = db.Exec(string(fixture))
+		require.NoError(t, err)
+
+		require.NoError(t, Migrate(db))
+		assert.Equal(t, latest, appliedVersions(t, db))
+
+		var amount int
+		var memo string
+		var deletedAt sql.NullString
+		err = db.QueryRow("SELECT amount, memo, deleted_at FROM payments WHERE id = '6f1c0d7e-8a3b-4c59-9a57-3c1b2f0e4d21'").Scan(&amount, &memo, &deletedAt)
+		require.NoError(t, err)
+		assert.Equal(t, 3000, amount)
+		assert.Equal(t, "", memo)
+		assert.False(t, deletedAt.Valid)
+
+		var weight int
+		err = db.QueryRow("SELECT weight FROM payers WHERE id = 'U0002'").Scan(&weight)
+		require.NoError(t, err)
+		assert.Equal(t, 50, weight)
+
+		var organizerID string
+		err = db.QueryRow("SELECT organizer_id FROM events WHERE id = 'C0001'").Scan(&organizerID)
+		require.NoError(t, err)
+		assert.Equal(t, "", organizerID)
+	})
+
+	t.Run("OK: applying twice is a no-op", func(t *testing.T) {
+		t.Parallel()
+
+		db := openTestDB(t)
+		require.NoError(t, Migrate(db))
+		require.NoError(t, Migrate(db))
+		assert.Equal(t, latest, appliedVersions(t, db))
+	})
+
+	t.Run("NG: failed migration is rolled back", func(t *testing.T) {
+		t.Parallel()
+
+		db := openTestDB(t)
+		err := sqldb.Migrate(db, dialect, []sqldb.Migration{
+			{Version: 1, Name: "create_foo", Query: "CREATE TABLE foo (id TEXT PRIMARY KEY);"},
+			{Version: 2, Name: "broken", Query: "CREATE TABLE bar (id TEXT PRIMARY KEY); INSERT INTO missing VALUES (1);"},
+		})
+		require.Error(t, err)
+		assert.Equal(t, []int{1}, appliedVersions(t, db))
+
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'bar'").Scan(&count)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count, "partially applied migration must be rolled back")
+	})
+}
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS events (
+	id TEXT PRIMARY KEY
+);
+
+CREATE TABLE IF NOT EXISTS payers (
+	id TEXT PRIMARY KEY,
+	event_id TEXT NOT NULL,
+	weight INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS payments (
+	id TEXT PRIMARY KEY,
+	event_id TEXT NOT NULL,
+	payer_id TEXT NOT NULL,
+	amount INTEGER NOT NULL,
+	created_at TEXT NOT NULL DEFAULT (DATETIME('now', 'localtime'))
+);
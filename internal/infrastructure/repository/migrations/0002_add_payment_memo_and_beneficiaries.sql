@@ -0,0 +1,7 @@
+ALTER TABLE payments ADD COLUMN memo TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE payment_beneficiaries (
+	payment_id TEXT NOT NULL,
+	payer_id TEXT NOT NULL,
+	PRIMARY KEY (payment_id, payer_id)
+);
@@ -0,0 +1 @@
+ALTER TABLE events ADD COLUMN organizer_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+CREATE TABLE audit_logs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_id TEXT NOT NULL,
+	actor_id TEXT NOT NULL,
+	payer_id TEXT NOT NULL,
+	action TEXT NOT NULL,
+	before TEXT NOT NULL,
+	after TEXT NOT NULL,
+	created_at TEXT NOT NULL
+);
+
+CREATE INDEX audit_logs_event_id ON audit_logs (event_id);
@@ -0,0 +1 @@
+ALTER TABLE payments ADD COLUMN deleted_at TEXT;
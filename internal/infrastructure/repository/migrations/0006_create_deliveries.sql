@@ -0,0 +1,7 @@
+CREATE TABLE deliveries (
+	key TEXT PRIMARY KEY,
+	-- UNIX時間（ナノ秒）
+	received_at INTEGER NOT NULL
+);
+
+CREATE INDEX deliveries_received_at ON deliveries (received_at);
@@ -0,0 +1,8 @@
+CREATE TABLE installations (
+	team_id TEXT PRIMARY KEY,
+	team_name TEXT NOT NULL,
+	bot_user_id TEXT NOT NULL,
+	bot_token TEXT NOT NULL,
+	installer_id TEXT NOT NULL,
+	installed_at TEXT NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Enterprise Gridの組織のID。組織全体に追加したときは team_id にも組織のIDを入れる
+ALTER TABLE installations ADD COLUMN enterprise_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+-- 同じ利用者が複数の割り勘に参加できるように、割り勘ごとに参加者を区別する
+CREATE TABLE payers_new (
+	id TEXT NOT NULL,
+	event_id TEXT NOT NULL,
+	weight INTEGER NOT NULL,
+	PRIMARY KEY (event_id, id)
+);
+
+INSERT INTO payers_new (id, event_id, weight) SELECT id, event_id, weight FROM payers;
+
+DROP TABLE payers;
+
+ALTER TABLE payers_new RENAME TO payers;
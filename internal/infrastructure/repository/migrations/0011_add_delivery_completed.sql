@@ -0,0 +1,5 @@
+-- 処理中のリクエストが再送されたときは、処理し終えたものと区別してやり直してもらう
+ALTER TABLE deliveries ADD COLUMN completed INTEGER NOT NULL DEFAULT 0;
+
+-- それまでに記録したものは処理し終えたものとして扱う
+UPDATE deliveries SET completed = 1;
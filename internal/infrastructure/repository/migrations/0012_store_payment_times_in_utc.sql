@@ -0,0 +1,3 @@
+-- サーバーのタイムゾーンによって取り消しの期限がずれないように、立替えの時刻をUTCで記録する
+-- それまでの時刻はサーバーのタイムゾーンで記録されているので、UTCに直す
+UPDATE payments SET created_at = DATETIME(created_at, 'utc'), deleted_at = DATETIME(deleted_at, 'utc');
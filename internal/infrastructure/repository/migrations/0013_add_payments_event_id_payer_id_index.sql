@@ -0,0 +1,3 @@
+-- 参加者ごとの立替えを割り勘の中から探せるようにする
+-- 以前は 0006 で作っていたので、すでにあるデータベースでは作り直さない
+CREATE INDEX IF NOT EXISTS payments_event_id_payer_id ON payments (event_id, payer_id);
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/repository"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/memory"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/postgres"
+)
+
+// Database はユースケースが使うストアに、死活確認と後片付けを加えたもの
+type Database interface {
+	repository.Store
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Open はDSNがPostgreSQLのURLならPostgreSQLに、"memory"ならメモリ上に、それ以外はSQLiteのファイルに接続する
+func Open(dsn string) (Database, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return postgres.NewStore(dsn)
+	}
+	if dsn == "memory" {
+		return memory.NewStore(), nil
+	}
+	return NewStore(dsn)
+}
@@ -1,44 +1,29 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 
 	"github.com/mattn/go-sqlite3"
-	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/kakudo415/warikan-bot/internal/domain/entity"
 	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
 )
 
 type PayerRepository struct {
-	db *sql.DB
+	q sqldb.Querier
 }
 
-func NewPayerRepository(filename string) (*PayerRepository, error) {
-	db, err := sql.Open("sqlite3", filename)
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS payers (
-			id TEXT PRIMARY KEY,
-			event_id TEXT NOT NULL,
-			weight INTEGER NOT NULL
-		);
-	`)
-	if err != nil {
-		return nil, err
-	}
-
+func newPayerRepository(q sqldb.Querier) *PayerRepository {
 	return &PayerRepository{
-		db: db,
-	}, nil
+		q: q,
+	}
 }
 
-func (r *PayerRepository) Create(payer *entity.Payer) error {
-	_, err := r.db.Exec("INSERT INTO payers (id, event_id, weight) VALUES (?, ?, ?)",
+func (r *PayerRepository) Create(ctx context.Context, payer *entity.Payer) error {
+	_, err := r.q.ExecContext(ctx, "INSERT INTO payers (id, event_id, weight) VALUES (?, ?, ?)",
 		payer.ID.String(),
 		payer.EventID.String(),
 		payer.Weight.Int(),
@@ -51,8 +36,8 @@ func (r *PayerRepository) Create(payer *entity.Payer) error {
 	return err
 }
 
-func (r *PayerRepository) CreateIfNotExists(payer *entity.Payer) error {
-	_, err := r.db.Exec("INSERT OR IGNORE INTO payers (id, event_id, weight) VALUES (?, ?, ?)",
+func (r *PayerRepository) CreateIfNotExists(ctx context.Context, payer *entity.Payer) error {
+	_, err := r.q.ExecContext(ctx, "INSERT OR IGNORE INTO payers (id, event_id, weight) VALUES (?, ?, ?)",
 		payer.ID.String(),
 		payer.EventID.String(),
 		payer.Weight.Int(),
@@ -60,8 +45,53 @@ func (r *PayerRepository) CreateIfNotExists(payer *entity.Payer) error {
 	return err
 }
 
-func (r *PayerRepository) FindByEventID(eventID valueobject.EventID) ([]*entity.Payer, error) {
-	rows, err := r.db.Query("SELECT id, event_id, weight FROM payers WHERE event_id = ?", eventID.String())
+func (r *PayerRepository) Update(ctx context.Context, payer *entity.Payer) error {
+	result, err := r.q.ExecContext(ctx, "UPDATE payers SET weight = ? WHERE id = ? AND event_id = ?",
+		payer.Weight.Int(),
+		payer.ID.String(),
+		payer.EventID.String(),
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return valueobject.NewErrorNotFound("payer not found", nil)
+	}
+	return nil
+}
+
+func (r *PayerRepository) Delete(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM payers WHERE id = ? AND event_id = ?", payerID.String(), eventID.String())
+	return err
+}
+
+func (r *PayerRepository) FindByID(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID) (*entity.Payer, error) {
+	var rawID, rawEventID string
+	var weight int
+	err := r.q.QueryRowContext(ctx, "SELECT id, event_id, weight FROM payers WHERE id = ? AND event_id = ?", payerID.String(), eventID.String()).
+		Scan(&rawID, &rawEventID, &weight)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, valueobject.NewErrorNotFound("payer not found", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var payer entity.Payer
+	payer.ID = valueobject.NewPayerID(rawID)
+	payer.EventID = valueobject.NewEventID(rawEventID)
+	payer.Weight, err = valueobject.NewPercent(weight)
+	if err != nil {
+		return nil, err
+	}
+	return &payer, nil
+}
+
+func (r *PayerRepository) FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.Payer, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT id, event_id, weight FROM payers WHERE event_id = ?", eventID.String())
 	if err != nil {
 		return nil, err
 	}
@@ -85,3 +115,22 @@ func (r *PayerRepository) FindByEventID(eventID valueobject.EventID) ([]*entity.
 	}
 	return payers, nil
 }
+
+func (r *PayerRepository) Rename(ctx context.Context, from valueobject.PayerID, to valueobject.PayerID) error {
+	return sqldb.Transact(ctx, r.q, func(q sqldb.Querier) error {
+		for _, column := range []struct{ table, name, slackEvent string }{
+			{"payers", "id", sqldb.IsSlackEvent("event_id")},
+			{"payments", "payer_id", sqldb.IsSlackEvent("event_id")},
+			{"payment_beneficiaries", "payer_id", "payment_id IN (SELECT id FROM payments WHERE " + sqldb.IsSlackEvent("event_id") + ")"},
+			{"audit_logs", "actor_id", sqldb.IsSlackEvent("event_id")},
+			{"audit_logs", "payer_id", sqldb.IsSlackEvent("event_id")},
+			{"events", "organizer_id", sqldb.IsSlackEvent("id")},
+		} {
+			query := "UPDATE " + column.table + " SET " + column.name + " = ? WHERE " + column.name + " = ? AND " + column.slackEvent
+			if _, err := q.ExecContext(ctx, query, to.String(), from.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -1,66 +1,171 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/mattn/go-sqlite3"
-	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/kakudo415/warikan-bot/internal/domain/entity"
 	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
 )
 
 type PaymentRepository struct {
-	db *sql.DB
+	q sqldb.Querier
 }
 
-func NewPaymentRepository(filename string) (*PaymentRepository, error) {
-	db, err := sql.Open("sqlite3", filename)
+func newPaymentRepository(q sqldb.Querier) *PaymentRepository {
+	return &PaymentRepository{
+		q: q,
+	}
+}
+
+func (r *PaymentRepository) Create(ctx context.Context, payment *entity.Payment) error {
+	return sqldb.Transact(ctx, r.q, func(q sqldb.Querier) error {
+		// 時刻はUTCで記録し、サーバーのタイムゾーンにかかわらず比べられるようにする
+		_, err := q.ExecContext(ctx, "INSERT INTO payments (id, event_id, payer_id, amount, memo, created_at) VALUES (?, ?, ?, ?, ?, DATETIME('now'))",
+			payment.ID.String(),
+			payment.EventID.String(),
+			payment.PayerID.String(),
+			payment.Amount.Int64(),
+			payment.Memo,
+		)
+		if sqliteErr := new(sqlite3.Error); errors.As(err, sqliteErr) {
+			if sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+				return valueobject.NewErrorAlreadyExists("payment already exists", err)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		for _, beneficiary := range payment.Beneficiaries {
+			_, err := q.ExecContext(ctx, "INSERT OR IGNORE INTO payment_beneficiaries (payment_id, payer_id) VALUES (?, ?)",
+				payment.ID.String(),
+				beneficiary.String(),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *PaymentRepository) Update(ctx context.Context, payment *entity.Payment) error {
+	result, err := r.q.ExecContext(ctx, "UPDATE payments SET amount = ?, memo = ? WHERE id = ? AND deleted_at IS NULL",
+		payment.Amount.Int64(),
+		payment.Memo,
+		payment.ID.String(),
+	)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return valueobject.NewErrorNotFound("payment not found", nil)
+	}
+	return nil
+}
 
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS payments (
-			id TEXT PRIMARY KEY,
-			event_id TEXT NOT NULL,
-			payer_id TEXT NOT NULL,
-			amount INTEGER NOT NULL,
-			created_at TEXT NOT NULL DEFAULT (DATETIME('now', 'localtime'))
-		);
-	`)
+func (r *PaymentRepository) Delete(ctx context.Context, paymentID valueobject.PaymentID) error {
+	// 取り消せるように論理削除する
+	_, err := r.q.ExecContext(ctx, "UPDATE payments SET deleted_at = DATETIME('now') WHERE id = ? AND deleted_at IS NULL", paymentID.String())
+	return err
+}
+
+func (r *PaymentRepository) Restore(ctx context.Context, paymentID valueobject.PaymentID) error {
+	result, err := r.q.ExecContext(ctx, "UPDATE payments SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", paymentID.String())
 	if err != nil {
-		return nil, err
+		return err
 	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return valueobject.NewErrorNotFound("deleted payment not found", nil)
+	}
+	return nil
+}
 
-	return &PaymentRepository{
-		db: db,
-	}, nil
+func (r *PaymentRepository) FindByID(ctx context.Context, paymentID valueobject.PaymentID) (*entity.Payment, error) {
+	row := r.q.QueryRowContext(ctx, "SELECT id, event_id, payer_id, amount, memo FROM payments WHERE id = ? AND deleted_at IS NULL", paymentID.String())
+	return r.scanPayment(ctx, row)
 }
 
-func (r *PaymentRepository) Create(payment *entity.Payment) error {
-	_, err := r.db.Exec("INSERT INTO payments (id, event_id, payer_id, amount) VALUES (?, ?, ?, ?)",
-		payment.ID.String(),
-		payment.EventID.String(),
-		payment.PayerID.String(),
-		payment.Amount.Int64(),
-	)
-	if sqliteErr := new(sqlite3.Error); errors.As(err, sqliteErr) {
-		if sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
-			return valueobject.NewErrorAlreadyExists("payment already exists", err)
+func (r *PaymentRepository) FindLastDeleted(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID, since time.Time) (*entity.Payment, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT id, event_id, payer_id, amount, memo FROM payments
+		WHERE event_id = ? AND payer_id = ? AND deleted_at >= ?
+		ORDER BY deleted_at DESC
+		LIMIT 1
+	`, eventID.String(), payerID.String(), since.UTC().Format(time.DateTime))
+	return r.scanPayment(ctx, row)
+}
+
+func (r *PaymentRepository) FindLastSimilar(ctx context.Context, payment *entity.Payment, since time.Time) (*entity.Payment, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT id, event_id, payer_id, amount, memo FROM payments
+		WHERE event_id = ? AND payer_id = ? AND amount = ? AND memo = ? AND created_at >= ? AND deleted_at IS NULL
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, payment.EventID.String(), payment.PayerID.String(), payment.Amount.Int64(), payment.Memo, since.UTC().Format(time.DateTime))
+	return r.scanPayment(ctx, row)
+}
+
+func (r *PaymentRepository) scanPayment(ctx context.Context, row *sql.Row) (*entity.Payment, error) {
+	var rawID, rawEventID, rawPayerID, memo string
+	var rawAmount int
+	err := row.Scan(&rawID, &rawEventID, &rawPayerID, &rawAmount, &memo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, valueobject.NewErrorNotFound("payment not found", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var payment entity.Payment
+	payment.ID, err = valueobject.NewPaymentIDFromString(rawID)
+	if err != nil {
+		return nil, err
+	}
+	payment.EventID = valueobject.NewEventID(rawEventID)
+	payment.PayerID = valueobject.NewPayerID(rawPayerID)
+	payment.Amount, err = valueobject.NewYen(rawAmount)
+	if err != nil {
+		return nil, err
+	}
+	payment.Memo = memo
+
+	rows, err := r.q.QueryContext(ctx, "SELECT payer_id FROM payment_beneficiaries WHERE payment_id = ?", rawID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rawBeneficiaryID string
+		if err := rows.Scan(&rawBeneficiaryID); err != nil {
+			return nil, err
 		}
+		payment.Beneficiaries = append(payment.Beneficiaries, valueobject.NewPayerID(rawBeneficiaryID))
 	}
-	return err
-}
 
-func (r *PaymentRepository) Delete(paymentID valueobject.PaymentID) error {
-	_, err := r.db.Exec("DELETE FROM payments WHERE id = ?", paymentID.String())
-	return err
+	return &payment, nil
 }
 
-func (r *PaymentRepository) FindByEventID(eventID valueobject.EventID) ([]*entity.Payment, error) {
-	rows, err := r.db.Query("SELECT id, event_id, payer_id, amount FROM payments WHERE event_id = ? ORDER BY created_at ASC", eventID.String())
+func (r *PaymentRepository) FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.Payment, error) {
+	beneficiaries, err := r.findBeneficiariesByEventID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, "SELECT id, event_id, payer_id, amount, memo FROM payments WHERE event_id = ? AND deleted_at IS NULL ORDER BY created_at ASC", eventID.String())
 	if err != nil {
 		return nil, err
 	}
@@ -68,10 +173,10 @@ func (r *PaymentRepository) FindByEventID(eventID valueobject.EventID) ([]*entit
 
 	var payments []*entity.Payment
 	for rows.Next() {
-		var rawID, rawEventID, rawPayerID string
+		var rawID, rawEventID, rawPayerID, memo string
 		var rawAmount int
 		var payment entity.Payment
-		err := rows.Scan(&rawID, &rawEventID, &rawPayerID, &rawAmount)
+		err := rows.Scan(&rawID, &rawEventID, &rawPayerID, &rawAmount, &memo)
 		if err != nil {
 			return nil, err
 		}
@@ -85,8 +190,33 @@ func (r *PaymentRepository) FindByEventID(eventID valueobject.EventID) ([]*entit
 		if err != nil {
 			return nil, err
 		}
+		payment.Memo = memo
+		payment.Beneficiaries = beneficiaries[rawID]
 		payments = append(payments, &payment)
 	}
 
 	return payments, nil
 }
+
+func (r *PaymentRepository) findBeneficiariesByEventID(ctx context.Context, eventID valueobject.EventID) (map[string][]valueobject.PayerID, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT payment_beneficiaries.payment_id, payment_beneficiaries.payer_id
+		FROM payment_beneficiaries
+		INNER JOIN payments ON payments.id = payment_beneficiaries.payment_id
+		WHERE payments.event_id = ?
+	`, eventID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	beneficiaries := make(map[string][]valueobject.PayerID)
+	for rows.Next() {
+		var rawPaymentID, rawPayerID string
+		if err := rows.Scan(&rawPaymentID, &rawPayerID); err != nil {
+			return nil, err
+		}
+		beneficiaries[rawPaymentID] = append(beneficiaries[rawPaymentID], valueobject.NewPayerID(rawPayerID))
+	}
+	return beneficiaries, nil
+}
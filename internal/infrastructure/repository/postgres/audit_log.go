@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
+)
+
+type AuditLogRepository struct {
+	q sqldb.Querier
+}
+
+func newAuditLogRepository(q sqldb.Querier) *AuditLogRepository {
+	return &AuditLogRepository{
+		q: q,
+	}
+}
+
+func (r *AuditLogRepository) Append(ctx context.Context, log *entity.AuditLog) error {
+	_, err := r.q.ExecContext(ctx, "INSERT INTO audit_logs (event_id, actor_id, payer_id, action, before, after, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		log.EventID.String(),
+		log.ActorID.String(),
+		log.PayerID.String(),
+		log.Action.String(),
+		log.Before,
+		log.After,
+		log.CreatedAt,
+	)
+	return err
+}
+
+func (r *AuditLogRepository) FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.AuditLog, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT event_id, actor_id, payer_id, action, before, after, created_at FROM audit_logs WHERE event_id = $1 ORDER BY id ASC", eventID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []*entity.AuditLog
+	for rows.Next() {
+		var rawEventID, rawActorID, rawPayerID, rawAction, before, after string
+		var createdAt time.Time
+		if err := rows.Scan(&rawEventID, &rawActorID, &rawPayerID, &rawAction, &before, &after, &createdAt); err != nil {
+			return nil, err
+		}
+		logs = append(logs, &entity.AuditLog{
+			EventID:   valueobject.NewEventID(rawEventID),
+			ActorID:   valueobject.NewPayerID(rawActorID),
+			PayerID:   valueobject.NewPayerID(rawPayerID),
+			Action:    valueobject.AuditAction(rawAction),
+			Before:    before,
+			After:     after,
+			CreatedAt: createdAt,
+		})
+	}
+	return logs, nil
+}
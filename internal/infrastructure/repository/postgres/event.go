@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
+)
+
+type EventRepository struct {
+	q sqldb.Querier
+}
+
+func newEventRepository(q sqldb.Querier) *EventRepository {
+	return &EventRepository{
+		q: q,
+	}
+}
+
+func (r *EventRepository) CreateIfNotExists(ctx context.Context, event *entity.Event) error {
+	_, err := r.q.ExecContext(ctx, "INSERT INTO events (id, organizer_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		event.ID.String(),
+		event.OrganizerID.String(),
+	)
+	return err
+}
+
+func (r *EventRepository) FindByID(ctx context.Context, eventID valueobject.EventID) (*entity.Event, error) {
+	var rawID, rawOrganizerID string
+	err := r.q.QueryRowContext(ctx, "SELECT id, organizer_id FROM events WHERE id = $1", eventID.String()).Scan(&rawID, &rawOrganizerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, valueobject.NewErrorNotFound("event not found", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entity.Event{
+		ID:          valueobject.NewEventID(rawID),
+		OrganizerID: valueobject.NewPayerID(rawOrganizerID),
+	}, nil
+}
+
+func (r *EventRepository) FindAll(ctx context.Context) ([]*entity.Event, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT id, organizer_id FROM events ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*entity.Event
+	for rows.Next() {
+		var rawID, rawOrganizerID string
+		if err := rows.Scan(&rawID, &rawOrganizerID); err != nil {
+			return nil, err
+		}
+		events = append(events, &entity.Event{
+			ID:          valueobject.NewEventID(rawID),
+			OrganizerID: valueobject.NewPayerID(rawOrganizerID),
+		})
+	}
+	return events, nil
+}
+
+func (r *EventRepository) AssignTeam(ctx context.Context, teamID valueobject.TeamID) (int, error) {
+	var assigned int64
+	err := sqldb.Transact(ctx, r.q, func(q sqldb.Querier) error {
+		// ワークスペースを区別する前のIDはチャンネルIDだけで、区切りの ":" を含まない
+		result, err := q.ExecContext(ctx, "UPDATE events SET id = $1 || ':' || id WHERE id NOT LIKE '%:%'", teamID.Namespace())
+		if err != nil {
+			return err
+		}
+		assigned, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		for _, table := range []string{"payers", "payments", "audit_logs", "api_tokens"} {
+			_, err := q.ExecContext(ctx, "UPDATE "+table+" SET event_id = $1 || ':' || event_id WHERE event_id NOT LIKE '%:%'", teamID.Namespace())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return int(assigned), err
+}
+
+func (r *EventRepository) Move(ctx context.Context, from valueobject.EventID, to valueobject.EventID) error {
+	return sqldb.Transact(ctx, r.q, func(q sqldb.Querier) error {
+		for _, table := range []string{"payers", "payments", "audit_logs", "api_tokens"} {
+			if _, err := q.ExecContext(ctx, "UPDATE "+table+" SET event_id = $1 WHERE event_id = $2", to.String(), from.String()); err != nil {
+				return err
+			}
+		}
+		_, err := q.ExecContext(ctx, "UPDATE events SET id = $1 WHERE id = $2", to.String(), from.String())
+		return err
+	})
+}
@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
+)
+
+type InstallationRepository struct {
+	q sqldb.Querier
+}
+
+func newInstallationRepository(q sqldb.Querier) *InstallationRepository {
+	return &InstallationRepository{
+		q: q,
+	}
+}
+
+func (r *InstallationRepository) Save(ctx context.Context, installation *entity.Installation) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO installations (team_id, enterprise_id, team_name, bot_user_id, bot_token, installer_id, installed_at) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (team_id) DO UPDATE SET
+			enterprise_id = excluded.enterprise_id,
+			team_name = excluded.team_name,
+			bot_user_id = excluded.bot_user_id,
+			bot_token = excluded.bot_token,
+			installer_id = excluded.installer_id,
+			installed_at = excluded.installed_at
+	`,
+		installation.TeamID.String(),
+		installation.TeamID.EnterpriseID(),
+		installation.TeamName,
+		installation.BotUserID,
+		installation.BotToken,
+		installation.InstallerID.String(),
+		installation.InstalledAt,
+	)
+	return err
+}
+
+func (r *InstallationRepository) FindByTeamID(ctx context.Context, teamID valueobject.TeamID) (*entity.Installation, error) {
+	var rawTeamID, enterpriseID, teamName, botUserID, botToken, rawInstallerID string
+	var installedAt time.Time
+	err := r.q.QueryRowContext(ctx, "SELECT team_id, enterprise_id, team_name, bot_user_id, bot_token, installer_id, installed_at FROM installations WHERE team_id = $1", teamID.String()).
+		Scan(&rawTeamID, &enterpriseID, &teamName, &botUserID, &botToken, &rawInstallerID, &installedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, valueobject.NewErrorNotFound("installation not found", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 組織全体に追加したときは、ワークスペースの代わりに組織のIDで保存している
+	installedTeamID := valueobject.NewEnterpriseTeamID(enterpriseID, rawTeamID)
+	if rawTeamID == enterpriseID {
+		installedTeamID = installedTeamID.Organization()
+	}
+	return &entity.Installation{
+		TeamID:      installedTeamID,
+		TeamName:    teamName,
+		BotUserID:   botUserID,
+		BotToken:    botToken,
+		InstallerID: valueobject.NewPayerID(rawInstallerID),
+		InstalledAt: installedAt,
+	}, nil
+}
+
+func (r *InstallationRepository) Delete(ctx context.Context, teamID valueobject.TeamID) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM installations WHERE team_id = $1", teamID.String())
+	return err
+}
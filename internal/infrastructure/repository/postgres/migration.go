@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var dialect = sqldb.Dialect{
+	CreateTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+	`,
+	Exists: "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)",
+	Insert: "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)",
+	// 複数のインスタンスが同時に起動してもマイグレーションが重複しないようにする
+	Lock: "SELECT pg_advisory_xact_lock(5272)",
+}
+
+func Migrate(db *sql.DB) error {
+	migrations, err := sqldb.LoadMigrations(migrationFiles)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return sqldb.Migrate(db, dialect, migrations)
+}
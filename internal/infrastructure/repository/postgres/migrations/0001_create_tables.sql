@@ -0,0 +1,43 @@
+CREATE TABLE events (
+	id TEXT PRIMARY KEY,
+	organizer_id TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE payers (
+	id TEXT NOT NULL,
+	event_id TEXT NOT NULL,
+	weight INTEGER NOT NULL,
+	-- 同じ利用者が複数の割り勘に参加できるように、割り勘ごとに参加者を区別する
+	PRIMARY KEY (event_id, id)
+);
+
+CREATE TABLE payments (
+	id TEXT PRIMARY KEY,
+	event_id TEXT NOT NULL,
+	payer_id TEXT NOT NULL,
+	amount BIGINT NOT NULL,
+	memo TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	deleted_at TIMESTAMPTZ
+);
+
+CREATE INDEX payments_event_id ON payments (event_id);
+
+CREATE TABLE payment_beneficiaries (
+	payment_id TEXT NOT NULL,
+	payer_id TEXT NOT NULL,
+	PRIMARY KEY (payment_id, payer_id)
+);
+
+CREATE TABLE audit_logs (
+	id BIGSERIAL PRIMARY KEY,
+	event_id TEXT NOT NULL,
+	actor_id TEXT NOT NULL,
+	payer_id TEXT NOT NULL,
+	action TEXT NOT NULL,
+	before TEXT NOT NULL,
+	after TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX audit_logs_event_id ON audit_logs (event_id);
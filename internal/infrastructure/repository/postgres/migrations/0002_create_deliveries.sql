@@ -0,0 +1,6 @@
+CREATE TABLE deliveries (
+	key TEXT PRIMARY KEY,
+	received_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX deliveries_received_at ON deliveries (received_at);
@@ -0,0 +1,7 @@
+CREATE TABLE api_tokens (
+	hash TEXT PRIMARY KEY,
+	event_id TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX api_tokens_event_id ON api_tokens (event_id);
@@ -0,0 +1,5 @@
+-- 処理中のリクエストが再送されたときは、処理し終えたものと区別してやり直してもらう
+ALTER TABLE deliveries ADD COLUMN completed BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- それまでに記録したものは処理し終えたものとして扱う
+UPDATE deliveries SET completed = TRUE;
@@ -0,0 +1,15 @@
+-- Discord・LINEの参加者のIDにも名前空間を付けて、Slackの組織を区別する前のIDと重ならないようにする
+-- それまではユーザーIDだけで記録していたので、割り勘の名前空間から付け直す
+UPDATE payers SET id = 'discord:' || id WHERE (event_id LIKE 'discord:%' OR event_id LIKE 'discord-%') AND id NOT LIKE '%:%';
+UPDATE payments SET payer_id = 'discord:' || payer_id WHERE (event_id LIKE 'discord:%' OR event_id LIKE 'discord-%') AND payer_id NOT LIKE '%:%';
+UPDATE payment_beneficiaries SET payer_id = 'discord:' || payer_id WHERE payment_id IN (SELECT id FROM payments WHERE (event_id LIKE 'discord:%' OR event_id LIKE 'discord-%')) AND payer_id NOT LIKE '%:%';
+UPDATE audit_logs SET actor_id = 'discord:' || actor_id WHERE (event_id LIKE 'discord:%' OR event_id LIKE 'discord-%') AND actor_id <> '' AND actor_id NOT LIKE '%:%';
+UPDATE audit_logs SET payer_id = 'discord:' || payer_id WHERE (event_id LIKE 'discord:%' OR event_id LIKE 'discord-%') AND payer_id <> '' AND payer_id NOT LIKE '%:%';
+UPDATE events SET organizer_id = 'discord:' || organizer_id WHERE (id LIKE 'discord:%' OR id LIKE 'discord-%') AND organizer_id <> '' AND organizer_id NOT LIKE '%:%';
+
+UPDATE payers SET id = 'line:' || id WHERE event_id LIKE 'line:%' AND id NOT LIKE '%:%';
+UPDATE payments SET payer_id = 'line:' || payer_id WHERE event_id LIKE 'line:%' AND payer_id NOT LIKE '%:%';
+UPDATE payment_beneficiaries SET payer_id = 'line:' || payer_id WHERE payment_id IN (SELECT id FROM payments WHERE event_id LIKE 'line:%') AND payer_id NOT LIKE '%:%';
+UPDATE audit_logs SET actor_id = 'line:' || actor_id WHERE event_id LIKE 'line:%' AND actor_id <> '' AND actor_id NOT LIKE '%:%';
+UPDATE audit_logs SET payer_id = 'line:' || payer_id WHERE event_id LIKE 'line:%' AND payer_id <> '' AND payer_id NOT LIKE '%:%';
+UPDATE events SET organizer_id = 'line:' || organizer_id WHERE id LIKE 'line:%' AND organizer_id <> '' AND organizer_id NOT LIKE '%:%';
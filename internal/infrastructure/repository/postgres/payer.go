@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
+)
+
+type PayerRepository struct {
+	q sqldb.Querier
+}
+
+func newPayerRepository(q sqldb.Querier) *PayerRepository {
+	return &PayerRepository{
+		q: q,
+	}
+}
+
+func (r *PayerRepository) Create(ctx context.Context, payer *entity.Payer) error {
+	_, err := r.q.ExecContext(ctx, "INSERT INTO payers (id, event_id, weight) VALUES ($1, $2, $3)",
+		payer.ID.String(),
+		payer.EventID.String(),
+		payer.Weight.Int(),
+	)
+	if isUniqueViolation(err) {
+		return valueobject.NewErrorAlreadyExists("payer already exists", err)
+	}
+	return err
+}
+
+func (r *PayerRepository) CreateIfNotExists(ctx context.Context, payer *entity.Payer) error {
+	_, err := r.q.ExecContext(ctx, "INSERT INTO payers (id, event_id, weight) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		payer.ID.String(),
+		payer.EventID.String(),
+		payer.Weight.Int(),
+	)
+	return err
+}
+
+func (r *PayerRepository) Update(ctx context.Context, payer *entity.Payer) error {
+	result, err := r.q.ExecContext(ctx, "UPDATE payers SET weight = $1 WHERE id = $2 AND event_id = $3",
+		payer.Weight.Int(),
+		payer.ID.String(),
+		payer.EventID.String(),
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return valueobject.NewErrorNotFound("payer not found", nil)
+	}
+	return nil
+}
+
+func (r *PayerRepository) Delete(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM payers WHERE id = $1 AND event_id = $2", payerID.String(), eventID.String())
+	return err
+}
+
+func (r *PayerRepository) FindByID(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID) (*entity.Payer, error) {
+	var rawID, rawEventID string
+	var weight int
+	err := r.q.QueryRowContext(ctx, "SELECT id, event_id, weight FROM payers WHERE id = $1 AND event_id = $2", payerID.String(), eventID.String()).
+		Scan(&rawID, &rawEventID, &weight)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, valueobject.NewErrorNotFound("payer not found", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var payer entity.Payer
+	payer.ID = valueobject.NewPayerID(rawID)
+	payer.EventID = valueobject.NewEventID(rawEventID)
+	payer.Weight, err = valueobject.NewPercent(weight)
+	if err != nil {
+		return nil, err
+	}
+	return &payer, nil
+}
+
+func (r *PayerRepository) FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.Payer, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT id, event_id, weight FROM payers WHERE event_id = $1", eventID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payers []*entity.Payer
+	for rows.Next() {
+		var rawID, rawEventID string
+		var weight int
+		if err := rows.Scan(&rawID, &rawEventID, &weight); err != nil {
+			return nil, err
+		}
+		var payer entity.Payer
+		payer.ID = valueobject.NewPayerID(rawID)
+		payer.EventID = valueobject.NewEventID(rawEventID)
+		payer.Weight, err = valueobject.NewPercent(weight)
+		if err != nil {
+			return nil, err
+		}
+		payers = append(payers, &payer)
+	}
+	return payers, nil
+}
+
+func (r *PayerRepository) Rename(ctx context.Context, from valueobject.PayerID, to valueobject.PayerID) error {
+	return sqldb.Transact(ctx, r.q, func(q sqldb.Querier) error {
+		for _, column := range []struct{ table, name, slackEvent string }{
+			{"payers", "id", sqldb.IsSlackEvent("event_id")},
+			{"payments", "payer_id", sqldb.IsSlackEvent("event_id")},
+			{"payment_beneficiaries", "payer_id", "payment_id IN (SELECT id FROM payments WHERE " + sqldb.IsSlackEvent("event_id") + ")"},
+			{"audit_logs", "actor_id", sqldb.IsSlackEvent("event_id")},
+			{"audit_logs", "payer_id", sqldb.IsSlackEvent("event_id")},
+			{"events", "organizer_id", sqldb.IsSlackEvent("id")},
+		} {
+			query := "UPDATE " + column.table + " SET " + column.name + " = $1 WHERE " + column.name + " = $2 AND " + column.slackEvent
+			if _, err := q.ExecContext(ctx, query, to.String(), from.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
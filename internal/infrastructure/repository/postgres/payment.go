@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
+)
+
+type PaymentRepository struct {
+	q sqldb.Querier
+}
+
+func newPaymentRepository(q sqldb.Querier) *PaymentRepository {
+	return &PaymentRepository{
+		q: q,
+	}
+}
+
+func (r *PaymentRepository) Create(ctx context.Context, payment *entity.Payment) error {
+	return sqldb.Transact(ctx, r.q, func(q sqldb.Querier) error {
+		_, err := q.ExecContext(ctx, "INSERT INTO payments (id, event_id, payer_id, amount, memo) VALUES ($1, $2, $3, $4, $5)",
+			payment.ID.String(),
+			payment.EventID.String(),
+			payment.PayerID.String(),
+			payment.Amount.Int64(),
+			payment.Memo,
+		)
+		if isUniqueViolation(err) {
+			return valueobject.NewErrorAlreadyExists("payment already exists", err)
+		}
+		if err != nil {
+			return err
+		}
+		for _, beneficiary := range payment.Beneficiaries {
+			_, err := q.ExecContext(ctx, "INSERT INTO payment_beneficiaries (payment_id, payer_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+				payment.ID.String(),
+				beneficiary.String(),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *PaymentRepository) Update(ctx context.Context, payment *entity.Payment) error {
+	result, err := r.q.ExecContext(ctx, "UPDATE payments SET amount = $1, memo = $2 WHERE id = $3 AND deleted_at IS NULL",
+		payment.Amount.Int64(),
+		payment.Memo,
+		payment.ID.String(),
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return valueobject.NewErrorNotFound("payment not found", nil)
+	}
+	return nil
+}
+
+func (r *PaymentRepository) Delete(ctx context.Context, paymentID valueobject.PaymentID) error {
+	// 取り消せるように論理削除する
+	_, err := r.q.ExecContext(ctx, "UPDATE payments SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL", paymentID.String())
+	return err
+}
+
+func (r *PaymentRepository) Restore(ctx context.Context, paymentID valueobject.PaymentID) error {
+	result, err := r.q.ExecContext(ctx, "UPDATE payments SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", paymentID.String())
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return valueobject.NewErrorNotFound("deleted payment not found", nil)
+	}
+	return nil
+}
+
+func (r *PaymentRepository) FindByID(ctx context.Context, paymentID valueobject.PaymentID) (*entity.Payment, error) {
+	row := r.q.QueryRowContext(ctx, "SELECT id, event_id, payer_id, amount, memo FROM payments WHERE id = $1 AND deleted_at IS NULL", paymentID.String())
+	return r.scanPayment(ctx, row)
+}
+
+func (r *PaymentRepository) FindLastDeleted(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID, since time.Time) (*entity.Payment, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT id, event_id, payer_id, amount, memo FROM payments
+		WHERE event_id = $1 AND payer_id = $2 AND deleted_at >= $3
+		ORDER BY deleted_at DESC
+		LIMIT 1
+	`, eventID.String(), payerID.String(), since)
+	return r.scanPayment(ctx, row)
+}
+
+func (r *PaymentRepository) FindLastSimilar(ctx context.Context, payment *entity.Payment, since time.Time) (*entity.Payment, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT id, event_id, payer_id, amount, memo FROM payments
+		WHERE event_id = $1 AND payer_id = $2 AND amount = $3 AND memo = $4 AND created_at >= $5 AND deleted_at IS NULL
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, payment.EventID.String(), payment.PayerID.String(), payment.Amount.Int64(), payment.Memo, since)
+	return r.scanPayment(ctx, row)
+}
+
+func (r *PaymentRepository) scanPayment(ctx context.Context, row *sql.Row) (*entity.Payment, error) {
+	var rawID, rawEventID, rawPayerID, memo string
+	var rawAmount int
+	err := row.Scan(&rawID, &rawEventID, &rawPayerID, &rawAmount, &memo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, valueobject.NewErrorNotFound("payment not found", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var payment entity.Payment
+	payment.ID, err = valueobject.NewPaymentIDFromString(rawID)
+	if err != nil {
+		return nil, err
+	}
+	payment.EventID = valueobject.NewEventID(rawEventID)
+	payment.PayerID = valueobject.NewPayerID(rawPayerID)
+	payment.Amount, err = valueobject.NewYen(rawAmount)
+	if err != nil {
+		return nil, err
+	}
+	payment.Memo = memo
+
+	rows, err := r.q.QueryContext(ctx, "SELECT payer_id FROM payment_beneficiaries WHERE payment_id = $1", rawID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rawBeneficiaryID string
+		if err := rows.Scan(&rawBeneficiaryID); err != nil {
+			return nil, err
+		}
+		payment.Beneficiaries = append(payment.Beneficiaries, valueobject.NewPayerID(rawBeneficiaryID))
+	}
+
+	return &payment, nil
+}
+
+func (r *PaymentRepository) FindByEventID(ctx context.Context, eventID valueobject.EventID) ([]*entity.Payment, error) {
+	beneficiaries, err := r.findBeneficiariesByEventID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, "SELECT id, event_id, payer_id, amount, memo FROM payments WHERE event_id = $1 AND deleted_at IS NULL ORDER BY created_at ASC", eventID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []*entity.Payment
+	for rows.Next() {
+		var rawID, rawEventID, rawPayerID, memo string
+		var rawAmount int
+		var payment entity.Payment
+		err := rows.Scan(&rawID, &rawEventID, &rawPayerID, &rawAmount, &memo)
+		if err != nil {
+			return nil, err
+		}
+		payment.ID, err = valueobject.NewPaymentIDFromString(rawID)
+		if err != nil {
+			return nil, err
+		}
+		payment.EventID = valueobject.NewEventID(rawEventID)
+		payment.PayerID = valueobject.NewPayerID(rawPayerID)
+		payment.Amount, err = valueobject.NewYen(rawAmount)
+		if err != nil {
+			return nil, err
+		}
+		payment.Memo = memo
+		payment.Beneficiaries = beneficiaries[rawID]
+		payments = append(payments, &payment)
+	}
+
+	return payments, nil
+}
+
+func (r *PaymentRepository) findBeneficiariesByEventID(ctx context.Context, eventID valueobject.EventID) (map[string][]valueobject.PayerID, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT payment_beneficiaries.payment_id, payment_beneficiaries.payer_id
+		FROM payment_beneficiaries
+		INNER JOIN payments ON payments.id = payment_beneficiaries.payment_id
+		WHERE payments.event_id = $1
+	`, eventID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	beneficiaries := make(map[string][]valueobject.PayerID)
+	for rows.Next() {
+		var rawPaymentID, rawPayerID string
+		if err := rows.Scan(&rawPaymentID, &rawPayerID); err != nil {
+			return nil, err
+		}
+		beneficiaries[rawPaymentID] = append(beneficiaries[rawPaymentID], valueobject.NewPayerID(rawPayerID))
+	}
+	return beneficiaries, nil
+}
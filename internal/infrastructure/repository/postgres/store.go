@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/repository"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
+)
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+type Store struct {
+	db *sql.DB
+	q  sqldb.Querier
+}
+
+func NewStore(dsn string) (*Store, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{
+		db: db,
+		q:  db,
+	}, nil
+}
+
+// Ping はデータベースに接続できるか確かめる
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close は接続を閉じる。処理中のトランザクションがないときに呼ぶ
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Events() repository.EventRepository {
+	return newEventRepository(s.q)
+}
+
+func (s *Store) Payers() repository.PayerRepository {
+	return newPayerRepository(s.q)
+}
+
+func (s *Store) Payments() repository.PaymentRepository {
+	return newPaymentRepository(s.q)
+}
+
+func (s *Store) AuditLogs() repository.AuditLogRepository {
+	return newAuditLogRepository(s.q)
+}
+
+func (s *Store) Deliveries() repository.DeliveryRepository {
+	return newDeliveryRepository(s.q)
+}
+
+func (s *Store) Installations() repository.InstallationRepository {
+	return newInstallationRepository(s.q)
+}
+
+func (s *Store) APITokens() repository.APITokenRepository {
+	return newAPITokenRepository(s.q)
+}
+
+func (s *Store) Transaction(ctx context.Context, fn func(store repository.Store) error) error {
+	return sqldb.Transact(ctx, s.q, func(q sqldb.Querier) error {
+		return fn(&Store{
+			db: s.db,
+			q:  q,
+		})
+	})
+}
@@ -0,0 +1,518 @@
+// Package repositorytest は、すべてのデータベース実装が同じように振る舞うことを確かめるテストをまとめる
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/repository"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+// Run はnewStoreが返す空のStoreに対して共通のテストを実行する
+func Run(t *testing.T, newStore func(t *testing.T) repository.Store) {
+	t.Run("Events", func(t *testing.T) { testEvents(t, newStore(t)) })
+	t.Run("Payers", func(t *testing.T) { testPayers(t, newStore(t)) })
+	t.Run("Payments", func(t *testing.T) { testPayments(t, newStore(t)) })
+	t.Run("AuditLogs", func(t *testing.T) { testAuditLogs(t, newStore(t)) })
+	t.Run("Deliveries", func(t *testing.T) { testDeliveries(t, newStore(t)) })
+	t.Run("Installations", func(t *testing.T) { testInstallations(t, newStore(t)) })
+	t.Run("APITokens", func(t *testing.T) { testAPITokens(t, newStore(t)) })
+	t.Run("AssignTeam", func(t *testing.T) { testAssignTeam(t, newStore(t)) })
+	t.Run("MoveEvent", func(t *testing.T) { testMoveEvent(t, newStore(t)) })
+	t.Run("RenamePayer", func(t *testing.T) { testRenamePayer(t, newStore(t)) })
+	t.Run("Transaction", func(t *testing.T) { testTransaction(t, newStore(t)) })
+	t.Run("Canceled", func(t *testing.T) { testCanceled(t, newStore(t)) })
+}
+
+func assertNotFound(t *testing.T, err error) {
+	t.Helper()
+
+	e := new(valueobject.ErrorNotFound)
+	assert.ErrorAs(t, err, &e)
+}
+
+func assertAlreadyExists(t *testing.T, err error) {
+	t.Helper()
+
+	e := new(valueobject.ErrorAlreadyExists)
+	assert.ErrorAs(t, err, &e)
+}
+
+func testEvents(t *testing.T, store repository.Store) {
+	ctx := t.Context()
+	eventID := valueobject.NewEventID("C0001")
+
+	_, err := store.Events().FindByID(ctx, eventID)
+	assertNotFound(t, err)
+
+	require.NoError(t, store.Events().CreateIfNotExists(ctx, &entity.Event{ID: eventID, OrganizerID: valueobject.NewPayerID("U0001")}))
+	// 2回目以降は幹事を上書きしない
+	require.NoError(t, store.Events().CreateIfNotExists(ctx, &entity.Event{ID: eventID, OrganizerID: valueobject.NewPayerID("U0002")}))
+
+	event, err := store.Events().FindByID(ctx, eventID)
+	require.NoError(t, err)
+	assert.Equal(t, eventID, event.ID)
+	assert.Equal(t, valueobject.NewPayerID("U0001"), event.OrganizerID)
+
+	// Enterprise Gridのチャンネルは組織で区別する
+	gridEventID := valueobject.NewChannelEventID(valueobject.NewEnterpriseTeamID("E0001", "T0001"), "C0001")
+	require.NoError(t, store.Events().CreateIfNotExists(ctx, &entity.Event{ID: gridEventID, OrganizerID: valueobject.NewPayerID("W0001")}))
+	event, err = store.Events().FindByID(ctx, valueobject.NewChannelEventID(valueobject.NewEnterpriseTeamID("E0001", "T0002"), "C0001"))
+	require.NoError(t, err)
+	assert.Equal(t, gridEventID, event.ID)
+	assert.Equal(t, "C0001", event.ID.ChannelID())
+	assert.Equal(t, valueobject.NewPayerID("W0001"), event.OrganizerID)
+
+	events, err := store.Events().FindAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, eventID, events[0].ID)
+	assert.Equal(t, gridEventID, events[1].ID)
+}
+
+func testPayers(t *testing.T, store repository.Store) {
+	ctx := t.Context()
+	eventID := valueobject.NewEventID("C0001")
+	payer1 := &entity.Payer{ID: valueobject.NewPayerID("U0001"), EventID: eventID, Weight: valueobject.Percent(100)}
+	payer2 := &entity.Payer{ID: valueobject.NewPayerID("U0002"), EventID: eventID, Weight: valueobject.Percent(50)}
+
+	require.NoError(t, store.Payers().Create(ctx, payer1))
+	assertAlreadyExists(t, store.Payers().Create(ctx, payer1))
+	require.NoError(t, store.Payers().CreateIfNotExists(ctx, payer2))
+	require.NoError(t, store.Payers().CreateIfNotExists(ctx, &entity.Payer{ID: payer2.ID, EventID: eventID, Weight: valueobject.Percent(0)}))
+
+	payers, err := store.Payers().FindByEventID(ctx, eventID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []*entity.Payer{payer1, payer2}, payers)
+
+	require.NoError(t, store.Payers().Update(ctx, &entity.Payer{ID: payer1.ID, EventID: eventID, Weight: valueobject.Percent(200)}))
+	payer, err := store.Payers().FindByID(ctx, eventID, payer1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, valueobject.Percent(200), payer.Weight)
+
+	assertNotFound(t, store.Payers().Update(ctx, &entity.Payer{ID: valueobject.NewPayerID("U9999"), EventID: eventID}))
+
+	// 同じ利用者は別の割り勘にも参加でき、重みも割り勘ごとに持つ
+	otherEventID := valueobject.NewEventID("C0002")
+	require.NoError(t, store.Payers().Create(ctx, &entity.Payer{ID: payer1.ID, EventID: otherEventID, Weight: valueobject.Percent(50)}))
+	payer, err = store.Payers().FindByID(ctx, otherEventID, payer1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, valueobject.Percent(50), payer.Weight)
+	require.NoError(t, store.Payers().Delete(ctx, otherEventID, payer1.ID))
+	payer, err = store.Payers().FindByID(ctx, eventID, payer1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, valueobject.Percent(200), payer.Weight)
+
+	require.NoError(t, store.Payers().Delete(ctx, eventID, payer2.ID))
+	_, err = store.Payers().FindByID(ctx, eventID, payer2.ID)
+	assertNotFound(t, err)
+
+	payers, err = store.Payers().FindByEventID(ctx, valueobject.NewEventID("C9999"))
+	require.NoError(t, err)
+	assert.Empty(t, payers)
+}
+
+func testPayments(t *testing.T, store repository.Store) {
+	ctx := t.Context()
+	eventID := valueobject.NewEventID("C0001")
+	payerID := valueobject.NewPayerID("U0001")
+	payment1 := &entity.Payment{
+		ID: valueobject.NewPaymentID(), EventID: eventID, PayerID: payerID, Amount: valueobject.Yen(3000), Memo: "ランチ",
+		Beneficiaries: []valueobject.PayerID{valueobject.NewPayerID("U0002"), valueobject.NewPayerID("U0003")},
+	}
+	payment2 := &entity.Payment{ID: valueobject.NewPaymentID(), EventID: eventID, PayerID: payerID, Amount: valueobject.Yen(1500)}
+
+	require.NoError(t, store.Payments().Create(ctx, payment1))
+	assertAlreadyExists(t, store.Payments().Create(ctx, payment1))
+	require.NoError(t, store.Payments().Create(ctx, payment2))
+
+	payment, err := store.Payments().FindByID(ctx, payment1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, payment1.Amount, payment.Amount)
+	assert.Equal(t, payment1.Memo, payment.Memo)
+	assert.ElementsMatch(t, payment1.Beneficiaries, payment.Beneficiaries)
+
+	_, err = store.Payments().FindByID(ctx, valueobject.NewPaymentID())
+	assertNotFound(t, err)
+
+	require.NoError(t, store.Payments().Update(ctx, &entity.Payment{ID: payment2.ID, Amount: valueobject.Yen(1800), Memo: "タクシー"}))
+	payment, err = store.Payments().FindByID(ctx, payment2.ID)
+	require.NoError(t, err)
+	assert.Equal(t, valueobject.Yen(1800), payment.Amount)
+	assert.Equal(t, "タクシー", payment.Memo)
+
+	assertNotFound(t, store.Payments().Update(ctx, &entity.Payment{ID: valueobject.NewPaymentID()}))
+
+	payments, err := store.Payments().FindByEventID(ctx, eventID)
+	require.NoError(t, err)
+	require.Len(t, payments, 2)
+
+	since := time.Now().Add(-time.Minute)
+	_, err = store.Payments().FindLastDeleted(ctx, eventID, payerID, since)
+	assertNotFound(t, err)
+
+	similar, err := store.Payments().FindLastSimilar(ctx, &entity.Payment{EventID: eventID, PayerID: payerID, Amount: valueobject.Yen(3000), Memo: "ランチ"}, since)
+	require.NoError(t, err)
+	assert.Equal(t, payment1.ID, similar.ID)
+	_, err = store.Payments().FindLastSimilar(ctx, &entity.Payment{EventID: eventID, PayerID: payerID, Amount: valueobject.Yen(3000), Memo: "ディナー"}, since)
+	assertNotFound(t, err)
+	_, err = store.Payments().FindLastSimilar(ctx, &entity.Payment{EventID: eventID, PayerID: payerID, Amount: valueobject.Yen(3000), Memo: "ランチ"}, time.Now().Add(time.Minute))
+	assertNotFound(t, err)
+
+	require.NoError(t, store.Payments().Delete(ctx, payment1.ID))
+	_, err = store.Payments().FindByID(ctx, payment1.ID)
+	assertNotFound(t, err)
+	assertNotFound(t, store.Payments().Update(ctx, &entity.Payment{ID: payment1.ID, Amount: valueobject.Yen(1)}))
+	_, err = store.Payments().FindLastSimilar(ctx, payment1, since)
+	assertNotFound(t, err)
+	payments, err = store.Payments().FindByEventID(ctx, eventID)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, payment2.ID, payments[0].ID)
+
+	deleted, err := store.Payments().FindLastDeleted(ctx, eventID, payerID, since)
+	require.NoError(t, err)
+	assert.Equal(t, payment1.ID, deleted.ID)
+	assert.ElementsMatch(t, payment1.Beneficiaries, deleted.Beneficiaries)
+	_, err = store.Payments().FindLastDeleted(ctx, eventID, payerID, time.Now().Add(time.Minute))
+	assertNotFound(t, err)
+
+	require.NoError(t, store.Payments().Restore(ctx, payment1.ID))
+	assertNotFound(t, store.Payments().Restore(ctx, payment1.ID))
+	payments, err = store.Payments().FindByEventID(ctx, eventID)
+	require.NoError(t, err)
+	assert.Len(t, payments, 2)
+}
+
+func testAuditLogs(t *testing.T, store repository.Store) {
+	ctx := t.Context()
+	eventID := valueobject.NewEventID("C0001")
+	createdAt := time.Date(2025, 5, 1, 19, 0, 0, 0, time.UTC)
+	logs := []*entity.AuditLog{
+		{EventID: eventID, ActorID: valueobject.NewPayerID("U0001"), PayerID: valueobject.NewPayerID("U0001"), Action: valueobject.AuditActionPaymentCreated, After: "3,000円", CreatedAt: createdAt},
+		{EventID: eventID, ActorID: valueobject.NewPayerID("U0002"), PayerID: valueobject.NewPayerID("U0001"), Action: valueobject.AuditActionPaymentUpdated, Before: "3,000円", After: "300円", CreatedAt: createdAt.Add(time.Minute)},
+	}
+	for _, log := range logs {
+		require.NoError(t, store.AuditLogs().Append(ctx, log))
+	}
+	require.NoError(t, store.AuditLogs().Append(ctx, &entity.AuditLog{EventID: valueobject.NewEventID("C0002"), Action: valueobject.AuditActionPayerJoined, CreatedAt: createdAt}))
+
+	found, err := store.AuditLogs().FindByEventID(ctx, eventID)
+	require.NoError(t, err)
+	require.Len(t, found, len(logs))
+	for i, log := range logs {
+		assert.Equal(t, log.ActorID, found[i].ActorID)
+		assert.Equal(t, log.Action, found[i].Action)
+		assert.Equal(t, log.Before, found[i].Before)
+		assert.Equal(t, log.After, found[i].After)
+		assert.True(t, log.CreatedAt.Equal(found[i].CreatedAt), "created_at mismatch")
+	}
+}
+
+func testDeliveries(t *testing.T, store repository.Store) {
+	ctx := t.Context()
+	receivedAt := time.Now()
+
+	require.NoError(t, store.Deliveries().Create(ctx, "event:Ev0001", receivedAt.Add(-time.Hour)))
+	assertAlreadyExists(t, store.Deliveries().Create(ctx, "event:Ev0001", receivedAt))
+	require.NoError(t, store.Deliveries().Create(ctx, "event:Ev0002", receivedAt))
+
+	// 処理し終えたものだけを区別する
+	completed, err := store.Deliveries().IsCompleted(ctx, "event:Ev0001")
+	require.NoError(t, err)
+	assert.False(t, completed)
+	require.NoError(t, store.Deliveries().Complete(ctx, "event:Ev0001"))
+	completed, err = store.Deliveries().IsCompleted(ctx, "event:Ev0001")
+	require.NoError(t, err)
+	assert.True(t, completed)
+	_, err = store.Deliveries().IsCompleted(ctx, "event:Ev0003")
+	assertNotFound(t, err)
+
+	// 削除すると同じキーをもう一度記録できる
+	require.NoError(t, store.Deliveries().Delete(ctx, "event:Ev0002"))
+	require.NoError(t, store.Deliveries().Create(ctx, "event:Ev0002", receivedAt))
+
+	require.NoError(t, store.Deliveries().DeleteBefore(ctx, receivedAt.Add(-time.Minute)))
+	require.NoError(t, store.Deliveries().Create(ctx, "event:Ev0001", receivedAt))
+	assertAlreadyExists(t, store.Deliveries().Create(ctx, "event:Ev0002", receivedAt))
+}
+
+func testInstallations(t *testing.T, store repository.Store) {
+	ctx := t.Context()
+	teamID := valueobject.NewTeamID("T0001")
+	installation := &entity.Installation{
+		TeamID: teamID, TeamName: "warikan", BotUserID: "U0BOT", BotToken: "xoxb-1",
+		InstallerID: valueobject.NewPayerID("U0001"), InstalledAt: time.Date(2025, 5, 1, 19, 0, 0, 0, time.UTC),
+	}
+
+	_, err := store.Installations().FindByTeamID(ctx, teamID)
+	assertNotFound(t, err)
+
+	require.NoError(t, store.Installations().Save(ctx, installation))
+	// 追加し直したときはトークンを上書きする
+	installation.BotToken = "xoxb-2"
+	require.NoError(t, store.Installations().Save(ctx, installation))
+
+	found, err := store.Installations().FindByTeamID(ctx, teamID)
+	require.NoError(t, err)
+	assert.Equal(t, installation.TeamName, found.TeamName)
+	assert.Equal(t, installation.BotUserID, found.BotUserID)
+	assert.Equal(t, "xoxb-2", found.BotToken)
+	assert.Equal(t, installation.InstallerID, found.InstallerID)
+	assert.True(t, installation.InstalledAt.Equal(found.InstalledAt), "installed_at mismatch")
+
+	require.NoError(t, store.Installations().Delete(ctx, teamID))
+	_, err = store.Installations().FindByTeamID(ctx, teamID)
+	assertNotFound(t, err)
+
+	// Enterprise Gridのワークスペースと、組織全体への追加
+	gridTeamID := valueobject.NewEnterpriseTeamID("E0001", "T0002")
+	require.NoError(t, store.Installations().Save(ctx, &entity.Installation{TeamID: gridTeamID, BotToken: "xoxb-3", InstalledAt: installation.InstalledAt}))
+	require.NoError(t, store.Installations().Save(ctx, &entity.Installation{TeamID: gridTeamID.Organization(), BotToken: "xoxb-4", InstalledAt: installation.InstalledAt}))
+	found, err = store.Installations().FindByTeamID(ctx, gridTeamID)
+	require.NoError(t, err)
+	assert.Equal(t, gridTeamID, found.TeamID)
+	assert.Equal(t, "xoxb-3", found.BotToken)
+	found, err = store.Installations().FindByTeamID(ctx, valueobject.NewEnterpriseTeamID("E0001", "T0003").Organization())
+	require.NoError(t, err)
+	assert.Equal(t, gridTeamID.Organization(), found.TeamID)
+	assert.Equal(t, "xoxb-4", found.BotToken)
+	_, err = store.Installations().FindByTeamID(ctx, valueobject.NewEnterpriseTeamID("E0001", "T0003"))
+	assertNotFound(t, err)
+}
+
+func testAPITokens(t *testing.T, store repository.Store) {
+	ctx := t.Context()
+	eventID := valueobject.NewChannelEventID(valueobject.NewTeamID("T0001"), "C0001")
+	otherEventID := valueobject.NewChannelEventID(valueobject.NewTeamID("T0001"), "C0002")
+	token := &entity.APIToken{Hash: "hash-1", EventID: eventID, CreatedAt: time.Date(2025, 5, 1, 19, 0, 0, 0, time.UTC)}
+
+	_, err := store.APITokens().FindByHash(ctx, token.Hash)
+	assertNotFound(t, err)
+
+	require.NoError(t, store.APITokens().Create(ctx, token))
+	require.NoError(t, store.APITokens().Create(ctx, &entity.APIToken{Hash: "hash-2", EventID: otherEventID, CreatedAt: token.CreatedAt}))
+
+	found, err := store.APITokens().FindByHash(ctx, token.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, eventID, found.EventID)
+	assert.True(t, token.CreatedAt.Equal(found.CreatedAt), "created_at mismatch")
+
+	// 他の割り勘のトークンは残す
+	require.NoError(t, store.APITokens().DeleteByEventID(ctx, eventID))
+	_, err = store.APITokens().FindByHash(ctx, token.Hash)
+	assertNotFound(t, err)
+	_, err = store.APITokens().FindByHash(ctx, "hash-2")
+	require.NoError(t, err)
+}
+
+func testAssignTeam(t *testing.T, store repository.Store) {
+	ctx := t.Context()
+	teamID := valueobject.NewTeamID("T0001")
+	legacyEventID := valueobject.NewEventID("C0001")
+	eventID := valueobject.NewChannelEventID(teamID, "C0001")
+	otherEventID := valueobject.NewChannelEventID(valueobject.NewTeamID("T0002"), "C0002")
+	payerID := valueobject.NewPayerID("U0001")
+
+	for _, id := range []valueobject.EventID{legacyEventID, otherEventID} {
+		require.NoError(t, store.Events().CreateIfNotExists(ctx, &entity.Event{ID: id, OrganizerID: payerID}))
+		require.NoError(t, store.Payments().Create(ctx, &entity.Payment{ID: valueobject.NewPaymentID(), EventID: id, PayerID: payerID, Amount: valueobject.Yen(1000)}))
+		require.NoError(t, store.AuditLogs().Append(ctx, &entity.AuditLog{EventID: id, ActorID: payerID, PayerID: payerID, Action: valueobject.AuditActionPaymentCreated, CreatedAt: time.Now()}))
+	}
+	for _, id := range []valueobject.EventID{legacyEventID, otherEventID} {
+		require.NoError(t, store.Payers().Create(ctx, &entity.Payer{ID: payerID, EventID: id, Weight: valueobject.Percent(100)}))
+	}
+
+	assigned, err := store.Events().AssignTeam(ctx, teamID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, assigned)
+
+	_, err = store.Events().FindByID(ctx, legacyEventID)
+	assertNotFound(t, err)
+	event, err := store.Events().FindByID(ctx, eventID)
+	require.NoError(t, err)
+	assert.Equal(t, payerID, event.OrganizerID)
+	payers, err := store.Payers().FindByEventID(ctx, eventID)
+	require.NoError(t, err)
+	assert.Len(t, payers, 1)
+	payments, err := store.Payments().FindByEventID(ctx, eventID)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, eventID, payments[0].EventID)
+	logs, err := store.AuditLogs().FindByEventID(ctx, eventID)
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+
+	// 他のワークスペースの割り勘はそのまま
+	payments, err = store.Payments().FindByEventID(ctx, otherEventID)
+	require.NoError(t, err)
+	assert.Len(t, payments, 1)
+	payers, err = store.Payers().FindByEventID(ctx, otherEventID)
+	require.NoError(t, err)
+	assert.Len(t, payers, 1)
+
+	assigned, err = store.Events().AssignTeam(ctx, teamID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, assigned)
+}
+
+func testMoveEvent(t *testing.T, store repository.Store) {
+	ctx := t.Context()
+	from := valueobject.NewChannelEventID(valueobject.NewEnterpriseTeamID("E0001", "T0001"), "C0001")
+	to := valueobject.NewChannelEventID(valueobject.NewTeamID("T0001"), "C0001")
+	otherEventID := valueobject.NewChannelEventID(valueobject.NewTeamID("T0002"), "C0002")
+	payerID := valueobject.NewPayerID("U0001")
+
+	for _, id := range []valueobject.EventID{from, otherEventID} {
+		require.NoError(t, store.Events().CreateIfNotExists(ctx, &entity.Event{ID: id, OrganizerID: payerID}))
+		require.NoError(t, store.Payers().Create(ctx, &entity.Payer{ID: payerID, EventID: id, Weight: valueobject.Percent(100)}))
+		require.NoError(t, store.Payments().Create(ctx, &entity.Payment{ID: valueobject.NewPaymentID(), EventID: id, PayerID: payerID, Amount: valueobject.Yen(1000)}))
+		require.NoError(t, store.AuditLogs().Append(ctx, &entity.AuditLog{EventID: id, ActorID: payerID, PayerID: payerID, Action: valueobject.AuditActionPaymentCreated, CreatedAt: time.Now()}))
+		require.NoError(t, store.APITokens().Create(ctx, &entity.APIToken{Hash: "hash-" + id.String(), EventID: id, CreatedAt: time.Now()}))
+	}
+
+	require.NoError(t, store.Events().Move(ctx, from, to))
+
+	_, err := store.Events().FindByID(ctx, from)
+	assertNotFound(t, err)
+	event, err := store.Events().FindByID(ctx, to)
+	require.NoError(t, err)
+	assert.Equal(t, payerID, event.OrganizerID)
+	payer, err := store.Payers().FindByID(ctx, to, payerID)
+	require.NoError(t, err)
+	assert.Equal(t, to, payer.EventID)
+	payments, err := store.Payments().FindByEventID(ctx, to)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, to, payments[0].EventID)
+	logs, err := store.AuditLogs().FindByEventID(ctx, to)
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+	token, err := store.APITokens().FindByHash(ctx, "hash-"+from.String())
+	require.NoError(t, err)
+	assert.Equal(t, to, token.EventID)
+
+	// 他の割り勘はそのまま
+	payers, err := store.Payers().FindByEventID(ctx, otherEventID)
+	require.NoError(t, err)
+	assert.Len(t, payers, 1)
+	payments, err = store.Payments().FindByEventID(ctx, otherEventID)
+	require.NoError(t, err)
+	assert.Len(t, payments, 1)
+}
+
+func testRenamePayer(t *testing.T, store repository.Store) {
+	ctx := t.Context()
+	eventID := valueobject.NewEventID("T0001:C0001")
+	from := valueobject.NewPayerID("U0001")
+	to := valueobject.NewSlackPayerID(valueobject.NewTeamID("T0001"), "U0001")
+	otherID := valueobject.NewPayerID("U0002")
+	// Slack以外の割り勘に同じユーザーIDがあっても、付け替えない
+	discordEventID := valueobject.NewDiscordEventID("G0001", "C0001")
+
+	require.NoError(t, store.Events().CreateIfNotExists(ctx, &entity.Event{ID: eventID, OrganizerID: from}))
+	for _, payerID := range []valueobject.PayerID{from, otherID} {
+		require.NoError(t, store.Payers().Create(ctx, &entity.Payer{ID: payerID, EventID: eventID, Weight: valueobject.Percent(100)}))
+	}
+	require.NoError(t, store.Events().CreateIfNotExists(ctx, &entity.Event{ID: discordEventID, OrganizerID: from}))
+	require.NoError(t, store.Payers().Create(ctx, &entity.Payer{ID: from, EventID: discordEventID, Weight: valueobject.Percent(100)}))
+	require.NoError(t, store.Payments().Create(ctx, &entity.Payment{
+		ID: valueobject.NewPaymentID(), EventID: discordEventID, PayerID: from, Amount: valueobject.Yen(1000),
+		Beneficiaries: []valueobject.PayerID{from},
+	}))
+	require.NoError(t, store.Payments().Create(ctx, &entity.Payment{
+		ID: valueobject.NewPaymentID(), EventID: eventID, PayerID: from, Amount: valueobject.Yen(1000),
+		Beneficiaries: []valueobject.PayerID{from, otherID},
+	}))
+	require.NoError(t, store.AuditLogs().Append(ctx, &entity.AuditLog{EventID: eventID, ActorID: from, PayerID: from, Action: valueobject.AuditActionPaymentCreated, CreatedAt: time.Now()}))
+
+	require.NoError(t, store.Payers().Rename(ctx, from, to))
+
+	_, err := store.Payers().FindByID(ctx, eventID, from)
+	assertNotFound(t, err)
+	_, err = store.Payers().FindByID(ctx, eventID, to)
+	require.NoError(t, err)
+	_, err = store.Payers().FindByID(ctx, eventID, otherID)
+	require.NoError(t, err)
+	payments, err := store.Payments().FindByEventID(ctx, eventID)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, to, payments[0].PayerID)
+	assert.ElementsMatch(t, []valueobject.PayerID{to, otherID}, payments[0].Beneficiaries)
+	logs, err := store.AuditLogs().FindByEventID(ctx, eventID)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, to, logs[0].ActorID)
+	assert.Equal(t, to, logs[0].PayerID)
+	event, err := store.Events().FindByID(ctx, eventID)
+	require.NoError(t, err)
+	assert.Equal(t, to, event.OrganizerID)
+
+	_, err = store.Payers().FindByID(ctx, discordEventID, from)
+	require.NoError(t, err)
+	payments, err = store.Payments().FindByEventID(ctx, discordEventID)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, from, payments[0].PayerID)
+	assert.Equal(t, []valueobject.PayerID{from}, payments[0].Beneficiaries)
+	event, err = store.Events().FindByID(ctx, discordEventID)
+	require.NoError(t, err)
+	assert.Equal(t, from, event.OrganizerID)
+}
+
+func testTransaction(t *testing.T, store repository.Store) {
+	ctx := t.Context()
+	eventID := valueobject.NewEventID("C0001")
+	errRollback := errors.New("rollback")
+
+	err := store.Transaction(ctx, func(store repository.Store) error {
+		if err := store.Events().CreateIfNotExists(ctx, &entity.Event{ID: eventID}); err != nil {
+			return err
+		}
+		if err := store.Payers().Create(ctx, &entity.Payer{ID: valueobject.NewPayerID("U0001"), EventID: eventID}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+	_, err = store.Events().FindByID(ctx, eventID)
+	assertNotFound(t, err)
+	payers, err := store.Payers().FindByEventID(ctx, eventID)
+	require.NoError(t, err)
+	assert.Empty(t, payers)
+
+	err = store.Transaction(ctx, func(store repository.Store) error {
+		return store.Events().CreateIfNotExists(ctx, &entity.Event{ID: eventID})
+	})
+	require.NoError(t, err)
+	_, err = store.Events().FindByID(ctx, eventID)
+	require.NoError(t, err)
+}
+
+func testCanceled(t *testing.T, store repository.Store) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	eventID := valueobject.NewEventID("C0001")
+
+	assert.ErrorIs(t, store.Events().CreateIfNotExists(ctx, &entity.Event{ID: eventID}), context.Canceled)
+	_, err := store.Payments().FindByEventID(ctx, eventID)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	called := false
+	err = store.Transaction(ctx, func(store repository.Store) error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, called)
+
+	_, err = store.Events().FindByID(t.Context(), eventID)
+	assertNotFound(t, err)
+}
@@ -0,0 +1,115 @@
+package sqldb
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Query   string
+}
+
+// Dialect はデータベースごとに異なるマイグレーション用のSQL
+type Dialect struct {
+	// CreateTable は適用済みのマイグレーションを記録する schema_migrations を作る
+	CreateTable string
+	// Exists は version を受け取り、適用済みかどうかを返す
+	Exists string
+	// Insert は version と name を受け取り、適用済みとして記録する
+	Insert string
+	// Lock は空でなければ、複数のインスタンスが同時にマイグレーションしないよう各トランザクションの最初に実行する
+	Lock string
+}
+
+// LoadMigrations は fsys の migrations/ 以下の "<番号>_<名前>.sql" を番号順に読み込む
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	for _, entry := range entries {
+		rawVersion, name, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration filename: %s", entry.Name())
+		}
+		version, err := strconv.Atoi(rawVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version: %s", entry.Name())
+		}
+		query, err := fs.ReadFile(fsys, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    name,
+			Query:   string(query),
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version: %d", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// Migrate はまだ適用していないマイグレーションを1つずつトランザクションで適用する
+func Migrate(db *sql.DB, d Dialect, migrations []Migration) error {
+	if _, err := db.Exec(d.CreateTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		if err := applyMigration(db, d, m); err != nil {
+			return fmt.Errorf("failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, d Dialect, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if d.Lock != "" {
+		if _, err := tx.Exec(d.Lock); err != nil {
+			return err
+		}
+	}
+	var applied bool
+	if err := tx.QueryRow(d.Exists, m.Version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err := tx.Exec(m.Query); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(d.Insert, m.Version, m.Name); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	slog.Info("applied migration", slog.Int("version", m.Version), slog.String("name", m.Name))
+	return nil
+}
@@ -0,0 +1,38 @@
+// Package sqldb は database/sql を使うリポジトリで共通のトランザクションとマイグレーションの処理をまとめる
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+)
+
+// Querier は *sql.DB と *sql.Tx のどちらでもリポジトリを動かせるようにする
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Transact はトランザクション外で呼ばれたときだけ新しくトランザクションを張る
+func Transact(ctx context.Context, q Querier, fn func(q Querier) error) error {
+	db, ok := q.(*sql.DB)
+	if !ok {
+		return fn(q)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		slog.DebugContext(ctx, "rolled back transaction", slog.Any("error", err))
+		return err
+	}
+	return tx.Commit()
+}
+
+// IsSlackEvent はcolumnの割り勘のIDがSlackのものかどうかを確かめる条件を返す。valueobject.EventID.IsSlack と同じ判定をする
+func IsSlackEvent(column string) string {
+	return column + " NOT LIKE 'discord:%' AND " + column + " NOT LIKE 'discord-%' AND " + column + " NOT LIKE 'line:%'"
+}
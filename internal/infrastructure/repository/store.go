@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/repository"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/sqldb"
+)
+
+type Store struct {
+	db *sql.DB
+	q  sqldb.Querier
+}
+
+func NewStore(filename string) (*Store, error) {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	// SQLiteは書き込みを同時に1つしか受け付けないので、接続を共有してロック競合を避ける
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{
+		db: db,
+		q:  db,
+	}, nil
+}
+
+// Ping はデータベースに接続できるか確かめる
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close は接続を閉じる。処理中のトランザクションがないときに呼ぶ
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Events() repository.EventRepository {
+	return newEventRepository(s.q)
+}
+
+func (s *Store) Payers() repository.PayerRepository {
+	return newPayerRepository(s.q)
+}
+
+func (s *Store) Payments() repository.PaymentRepository {
+	return newPaymentRepository(s.q)
+}
+
+func (s *Store) AuditLogs() repository.AuditLogRepository {
+	return newAuditLogRepository(s.q)
+}
+
+func (s *Store) Deliveries() repository.DeliveryRepository {
+	return newDeliveryRepository(s.q)
+}
+
+func (s *Store) Installations() repository.InstallationRepository {
+	return newInstallationRepository(s.q)
+}
+
+func (s *Store) APITokens() repository.APITokenRepository {
+	return newAPITokenRepository(s.q)
+}
+
+func (s *Store) Transaction(ctx context.Context, fn func(store repository.Store) error) error {
+	return sqldb.Transact(ctx, s.q, func(q sqldb.Querier) error {
+		return fn(&Store{
+			db: s.db,
+			q:  q,
+		})
+	})
+}
@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/repository"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/repositorytest"
+	"github.com/kakudo415/warikan-bot/internal/usecase"
+)
+
+var errInjected = errors.New("injected failure")
+
+// faultyStore は指定したリポジトリへの書き込みを途中で失敗させる
+type faultyStore struct {
+	repository.Store
+	failPayments  bool
+	failAuditLogs bool
+}
+
+func (s *faultyStore) Payments() repository.PaymentRepository {
+	if s.failPayments {
+		return &failingPaymentRepository{s.Store.Payments()}
+	}
+	return s.Store.Payments()
+}
+
+func (s *faultyStore) AuditLogs() repository.AuditLogRepository {
+	if s.failAuditLogs {
+		return &failingAuditLogRepository{s.Store.AuditLogs()}
+	}
+	return s.Store.AuditLogs()
+}
+
+func (s *faultyStore) Transaction(ctx context.Context, fn func(store repository.Store) error) error {
+	return s.Store.Transaction(ctx, func(store repository.Store) error {
+		return fn(&faultyStore{store, s.failPayments, s.failAuditLogs})
+	})
+}
+
+type failingPaymentRepository struct {
+	repository.PaymentRepository
+}
+
+func (r *failingPaymentRepository) Create(ctx context.Context, payment *entity.Payment) error {
+	return errInjected
+}
+
+func (r *failingPaymentRepository) Delete(ctx context.Context, paymentID valueobject.PaymentID) error {
+	return errInjected
+}
+
+type failingAuditLogRepository struct {
+	repository.AuditLogRepository
+}
+
+func (r *failingAuditLogRepository) Append(ctx context.Context, log *entity.AuditLog) error {
+	return errInjected
+}
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	store, err := NewStore(filepath.Join(t.TempDir(), "database.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func countRows(t *testing.T, store *Store, table string) int {
+	t.Helper()
+
+	var count int
+	require.NoError(t, store.db.QueryRow("SELECT COUNT(*) FROM "+table).Scan(&count))
+	return count
+}
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	repositorytest.Run(t, func(t *testing.T) repository.Store {
+		return openTestStore(t)
+	})
+}
+
+func TestPaymentTimesInUTC(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	store := openTestStore(t)
+	payment := &entity.Payment{ID: valueobject.NewPaymentID(), EventID: valueobject.NewEventID("C0001"), PayerID: valueobject.NewPayerID("U0001"), Amount: valueobject.Yen(3000)}
+	require.NoError(t, store.Payments().Create(ctx, payment))
+	require.NoError(t, store.Payments().Delete(ctx, payment.ID))
+
+	// サーバーのタイムゾーンにかかわらずUTCで記録する
+	var rawCreatedAt, rawDeletedAt string
+	require.NoError(t, store.db.QueryRow("SELECT created_at, deleted_at FROM payments WHERE id = ?", payment.ID.String()).Scan(&rawCreatedAt, &rawDeletedAt))
+	for _, raw := range []string{rawCreatedAt, rawDeletedAt} {
+		recordedAt, err := time.Parse(time.DateTime, raw)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), recordedAt, time.Minute)
+	}
+
+	// 別のタイムゾーンの時刻で探しても、取り消しの期限はずれない
+	since := time.Now().Add(-time.Minute).In(time.FixedZone("UTC-10", -10*60*60))
+	deleted, err := store.Payments().FindLastDeleted(ctx, payment.EventID, payment.PayerID, since)
+	require.NoError(t, err)
+	assert.Equal(t, payment.ID, deleted.ID)
+	_, err = store.Payments().FindLastDeleted(ctx, payment.EventID, payment.PayerID, time.Now().Add(time.Minute).In(time.FixedZone("UTC+14", 14*60*60)))
+	e := new(valueobject.ErrorNotFound)
+	assert.ErrorAs(t, err, &e)
+}
+
+func TestStoreTransaction(t *testing.T) {
+	t.Parallel()
+
+	eventID := valueobject.NewEventID("C0001")
+	payer := &entity.Payer{ID: valueobject.NewPayerID("U0001"), EventID: eventID, Weight: valueobject.Percent(100)}
+
+	t.Run("OK: commit", func(t *testing.T) {
+		t.Parallel()
+
+		store := openTestStore(t)
+		err := store.Transaction(t.Context(), func(store repository.Store) error {
+			if err := store.Events().CreateIfNotExists(t.Context(), &entity.Event{ID: eventID}); err != nil {
+				return err
+			}
+			return store.Payers().Create(t.Context(), payer)
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, countRows(t, store, "events"))
+		assert.Equal(t, 1, countRows(t, store, "payers"))
+	})
+
+	t.Run("NG: rollback when failing midway", func(t *testing.T) {
+		t.Parallel()
+
+		store := openTestStore(t)
+		err := store.Transaction(t.Context(), func(store repository.Store) error {
+			if err := store.Events().CreateIfNotExists(t.Context(), &entity.Event{ID: eventID}); err != nil {
+				return err
+			}
+			if err := store.Payers().Create(t.Context(), payer); err != nil {
+				return err
+			}
+			return errInjected
+		})
+		require.ErrorIs(t, err, errInjected)
+		assert.Equal(t, 0, countRows(t, store, "events"))
+		assert.Equal(t, 0, countRows(t, store, "payers"))
+	})
+}
+
+func TestPaymentUsecaseAtomicity(t *testing.T) {
+	t.Parallel()
+
+	eventID := valueobject.NewEventID("C0001")
+	payerID := valueobject.NewPayerID("U0001")
+	beneficiaries := []valueobject.PayerID{valueobject.NewPayerID("U0002"), valueobject.NewPayerID("U0003")}
+	// 割り勘の対象者は参加済みでなければならない
+	joinBeneficiaries := func(t *testing.T, store repository.Store) {
+		t.Helper()
+		for _, beneficiaryID := range beneficiaries {
+			_, err := usecase.NewPayment(store, nil).Join(t.Context(), eventID, beneficiaryID, valueobject.Percent(100))
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("NG: create fails on payment insert", func(t *testing.T) {
+		t.Parallel()
+
+		store := openTestStore(t)
+		joinBeneficiaries(t, store)
+		paymentUsecase := usecase.NewPayment(&faultyStore{Store: store, failPayments: true}, nil)
+		_, err := paymentUsecase.Create(t.Context(), eventID, payerID, payerID, valueobject.Yen(3000), "", beneficiaries)
+		require.ErrorIs(t, err, errInjected)
+		assert.Equal(t, 1, countRows(t, store, "events"))
+		assert.Equal(t, len(beneficiaries), countRows(t, store, "payers"))
+		assert.Equal(t, 0, countRows(t, store, "payments"))
+	})
+
+	t.Run("NG: create fails on audit log", func(t *testing.T) {
+		t.Parallel()
+
+		store := openTestStore(t)
+		joinBeneficiaries(t, store)
+		paymentUsecase := usecase.NewPayment(&faultyStore{Store: store, failAuditLogs: true}, nil)
+		_, err := paymentUsecase.Create(t.Context(), eventID, payerID, payerID, valueobject.Yen(3000), "", beneficiaries)
+		require.ErrorIs(t, err, errInjected)
+		assert.Equal(t, 1, countRows(t, store, "events"))
+		assert.Equal(t, len(beneficiaries), countRows(t, store, "payers"))
+		assert.Equal(t, 0, countRows(t, store, "payments"))
+		assert.Equal(t, 0, countRows(t, store, "payment_beneficiaries"))
+	})
+
+	t.Run("NG: join fails on audit log", func(t *testing.T) {
+		t.Parallel()
+
+		store := openTestStore(t)
+		paymentUsecase := usecase.NewPayment(&faultyStore{Store: store, failAuditLogs: true}, nil)
+		_, err := paymentUsecase.Join(t.Context(), eventID, payerID, valueobject.Percent(100))
+		require.ErrorIs(t, err, errInjected)
+		assert.Equal(t, 0, countRows(t, store, "payers"))
+	})
+
+	t.Run("NG: delete fails on audit log", func(t *testing.T) {
+		t.Parallel()
+
+		store := openTestStore(t)
+		payment, err := usecase.NewPayment(store, nil).Create(t.Context(), eventID, payerID, payerID, valueobject.Yen(3000), "", nil)
+		require.NoError(t, err)
+
+		paymentUsecase := usecase.NewPayment(&faultyStore{Store: store, failAuditLogs: true}, nil)
+		err = paymentUsecase.Delete(t.Context(), payment.ID, payerID)
+		require.ErrorIs(t, err, errInjected)
+
+		payments, err := store.Payments().FindByEventID(t.Context(), eventID)
+		require.NoError(t, err)
+		assert.Len(t, payments, 1, "payment must not be deleted")
+	})
+}
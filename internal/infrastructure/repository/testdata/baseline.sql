@@ -0,0 +1,23 @@
+-- マイグレーション導入前のスキーマとデータ
+CREATE TABLE events (
+	id TEXT PRIMARY KEY
+);
+
+CREATE TABLE payers (
+	id TEXT PRIMARY KEY,
+	event_id TEXT NOT NULL,
+	weight INTEGER NOT NULL
+);
+
+CREATE TABLE payments (
+	id TEXT PRIMARY KEY,
+	event_id TEXT NOT NULL,
+	payer_id TEXT NOT NULL,
+	amount INTEGER NOT NULL,
+	created_at TEXT NOT NULL DEFAULT (DATETIME('now', 'localtime'))
+);
+
+INSERT INTO events (id) VALUES ('C0001');
+INSERT INTO payers (id, event_id, weight) VALUES ('U0001', 'C0001', 100);
+INSERT INTO payers (id, event_id, weight) VALUES ('U0002', 'C0001', 50);
+INSERT INTO payments (id, event_id, payer_id, amount, created_at) VALUES ('6f1c0d7e-8a3b-4c59-9a57-3c1b2f0e4d21', 'C0001', 'U0001', 3000, '2025-05-01 19:00:00');
@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/repository"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+// APITokenUsecase は割り勘ごとにAPIのトークンを発行し、リクエストがその割り勘を操作してよいか確かめる
+type APITokenUsecase struct {
+	store repository.Store
+}
+
+func NewAPIToken(store repository.Store) *APITokenUsecase {
+	return &APITokenUsecase{
+		store,
+	}
+}
+
+// hashAPIToken はトークンを保存するときと照合するときに使うハッシュを作る
+// トークンは十分に長い乱数なので、ソルトや遅いハッシュ関数は使わない
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue は割り勘を操作するためのトークンを発行する。トークンは保存しないので、このときにしか受け取れない
+func (u *APITokenUsecase) Issue(ctx context.Context, eventID valueobject.EventID) (string, error) {
+	if _, err := u.store.Events().FindByID(ctx, eventID); err != nil {
+		return "", fmt.Errorf("failed to find event: %w", err)
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate api token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	err := u.store.APITokens().Create(ctx, &entity.APIToken{
+		Hash:      hashAPIToken(token),
+		EventID:   eventID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create api token: %w", err)
+	}
+	slog.InfoContext(ctx, "api token issued", slog.String("event_id", eventID.String()))
+	return token, nil
+}
+
+// Authorize はトークンがeventIDの割り勘に発行されたものか確かめる
+// 知らないトークンは ErrorNotFound、他の割り勘のトークンは ErrorForbidden を返す
+func (u *APITokenUsecase) Authorize(ctx context.Context, token string, eventID valueobject.EventID) error {
+	if token == "" {
+		return valueobject.NewErrorNotFound("api token is empty", nil)
+	}
+	found, err := u.store.APITokens().FindByHash(ctx, hashAPIToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to find api token: %w", err)
+	}
+	if found.EventID != eventID {
+		return valueobject.NewErrorForbidden("api token is not issued for the event", nil)
+	}
+	return nil
+}
+
+// Revoke は割り勘に発行したトークンをすべて無効にする
+func (u *APITokenUsecase) Revoke(ctx context.Context, eventID valueobject.EventID) error {
+	if err := u.store.APITokens().DeleteByEventID(ctx, eventID); err != nil {
+		return fmt.Errorf("failed to delete api tokens: %w", err)
+	}
+	slog.InfoContext(ctx, "api tokens revoked", slog.String("event_id", eventID.String()))
+	return nil
+}
@@ -0,0 +1,43 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/memory"
+)
+
+func TestAPIToken(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	store := memory.NewStore()
+	usecase := NewAPIToken(store)
+	eventID := valueobject.NewEventID("T0001:C0001")
+	otherEventID := valueobject.NewEventID("T0001:C0002")
+
+	// まだない割り勘には発行しない
+	_, err := usecase.Issue(ctx, eventID)
+	notFound := new(valueobject.ErrorNotFound)
+	require.ErrorAs(t, err, &notFound)
+
+	require.NoError(t, store.Events().CreateIfNotExists(ctx, &entity.Event{ID: eventID, OrganizerID: valueobject.NewPayerID("U0001")}))
+	token, err := usecase.Issue(ctx, eventID)
+	require.NoError(t, err)
+	require.NoError(t, usecase.Authorize(ctx, token, eventID))
+
+	// トークンそのものは保存しない
+	_, err = store.APITokens().FindByHash(ctx, token)
+	assert.ErrorAs(t, err, &notFound)
+
+	forbidden := new(valueobject.ErrorForbidden)
+	assert.ErrorAs(t, usecase.Authorize(ctx, token, otherEventID), &forbidden)
+	assert.ErrorAs(t, usecase.Authorize(ctx, "", eventID), &notFound)
+
+	require.NoError(t, usecase.Revoke(ctx, eventID))
+	assert.ErrorAs(t, usecase.Authorize(ctx, token, eventID), &notFound)
+}
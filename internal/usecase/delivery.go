@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/repository"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+// Slackが再送するのは数分以内なので、それより長く記録しておけば足りる
+const deliveryRetention = 24 * time.Hour
+
+// DeliveryUsecase はSlackから同じリクエストが再送されたときに、二重に処理しないようにする
+type DeliveryUsecase struct {
+	store repository.Store
+}
+
+func NewDelivery(store repository.Store) *DeliveryUsecase {
+	return &DeliveryUsecase{
+		store,
+	}
+}
+
+// Begin はkeyを初めて受け取ったときだけtrueを返す。処理し終えたら Complete し、失敗したら Abort して、再送されたときにやり直せるようにする
+// まだ処理している途中で再送されたときは ErrorAlreadyExists を返す
+func (u *DeliveryUsecase) Begin(ctx context.Context, key string) (bool, error) {
+	err := u.store.Deliveries().Create(ctx, key, time.Now())
+	if e := new(valueobject.ErrorAlreadyExists); errors.As(err, &e) {
+		completed, err := u.store.Deliveries().IsCompleted(ctx, key)
+		if err != nil {
+			return false, fmt.Errorf("failed to find delivery: %w", err)
+		}
+		if !completed {
+			return false, valueobject.NewErrorAlreadyExists("delivery is in progress: "+key, nil)
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create delivery: %w", err)
+	}
+	return true, nil
+}
+
+func (u *DeliveryUsecase) Complete(ctx context.Context, key string) error {
+	if err := u.store.Deliveries().Complete(ctx, key); err != nil {
+		return fmt.Errorf("failed to complete delivery: %w", err)
+	}
+	return nil
+}
+
+func (u *DeliveryUsecase) Abort(ctx context.Context, key string) error {
+	if err := u.store.Deliveries().Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to delete delivery: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired は再送されることのない古い記録を削除する
+func (u *DeliveryUsecase) DeleteExpired(ctx context.Context) error {
+	if err := u.store.Deliveries().DeleteBefore(ctx, time.Now().Add(-deliveryRetention)); err != nil {
+		return fmt.Errorf("failed to delete old deliveries: %w", err)
+	}
+	return nil
+}
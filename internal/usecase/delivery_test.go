@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/memory"
+)
+
+func TestDelivery(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	usecase := NewDelivery(memory.NewStore())
+
+	first, err := usecase.Begin(ctx, "event:Ev0001")
+	require.NoError(t, err)
+	assert.True(t, first)
+
+	// 処理している途中で再送されたものは、後でもう一度送ってもらう
+	_, err = usecase.Begin(ctx, "event:Ev0001")
+	e := new(valueobject.ErrorAlreadyExists)
+	assert.ErrorAs(t, err, &e)
+
+	// 処理し終えてから再送されたものは処理しない
+	require.NoError(t, usecase.Complete(ctx, "event:Ev0001"))
+	first, err = usecase.Begin(ctx, "event:Ev0001")
+	require.NoError(t, err)
+	assert.False(t, first)
+
+	// 処理に失敗したものは、再送されたときにやり直す
+	first, err = usecase.Begin(ctx, "event:Ev0002")
+	require.NoError(t, err)
+	assert.True(t, first)
+	require.NoError(t, usecase.Abort(ctx, "event:Ev0002"))
+	first, err = usecase.Begin(ctx, "event:Ev0002")
+	require.NoError(t, err)
+	assert.True(t, first)
+
+	// 最近受け取ったものは古い記録を消しても残る
+	require.NoError(t, usecase.DeleteExpired(ctx))
+	first, err = usecase.Begin(ctx, "event:Ev0001")
+	require.NoError(t, err)
+	assert.False(t, first)
+}
@@ -0,0 +1,63 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/entity"
+	"github.com/kakudo415/warikan-bot/internal/domain/repository"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+// InstallationUsecase はwarikan-botを追加したワークスペースと、そのボットトークンを管理する
+type InstallationUsecase struct {
+	store repository.Store
+}
+
+func NewInstallation(store repository.Store) *InstallationUsecase {
+	return &InstallationUsecase{
+		store,
+	}
+}
+
+func (u *InstallationUsecase) Install(ctx context.Context, installation *entity.Installation) error {
+	if installation.TeamID.IsUnknown() || installation.BotToken == "" {
+		return valueobject.NewErrorInvalid("team ID and bot token are required", nil)
+	}
+	if err := u.store.Installations().Save(ctx, installation); err != nil {
+		return fmt.Errorf("failed to save installation: %w", err)
+	}
+	slog.InfoContext(ctx, "installed", slog.String("team", installation.TeamID.String()), slog.String("installer", installation.InstallerID.String()))
+	return nil
+}
+
+func (u *InstallationUsecase) Find(ctx context.Context, teamID valueobject.TeamID) (*entity.Installation, error) {
+	installation, err := u.store.Installations().FindByTeamID(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find installation: %w", err)
+	}
+	return installation, nil
+}
+
+// Uninstall はワークスペースから削除されたときに、使えなくなったトークンを消す。割り勘の記録は残す
+func (u *InstallationUsecase) Uninstall(ctx context.Context, teamID valueobject.TeamID) error {
+	if err := u.store.Installations().Delete(ctx, teamID); err != nil {
+		return fmt.Errorf("failed to delete installation: %w", err)
+	}
+	slog.InfoContext(ctx, "uninstalled", slog.String("team", teamID.String()))
+	return nil
+}
+
+// AssignLegacyEvents はワークスペースを区別する前に作られた割り勘を、teamIDのワークスペースのものとして引き継ぐ
+// 1つのワークスペースだけで動かしていたときの記録を、更新後も使えるようにする
+func (u *InstallationUsecase) AssignLegacyEvents(ctx context.Context, teamID valueobject.TeamID) error {
+	assigned, err := u.store.Events().AssignTeam(ctx, teamID)
+	if err != nil {
+		return fmt.Errorf("failed to assign events to team: %w", err)
+	}
+	if assigned > 0 {
+		slog.InfoContext(ctx, "assigned legacy events to team", slog.String("team", teamID.String()), slog.Int("events", assigned))
+	}
+	return nil
+}
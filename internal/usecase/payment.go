@@ -1,24 +1,51 @@
 package usecase
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/kakudo415/warikan-bot/internal/domain/entity"
 	"github.com/kakudo415/warikan-bot/internal/domain/repository"
 	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
 )
 
+// 削除した立替えを元に戻せる期間
+const undoWindow = 10 * time.Minute
+
+// 同じ内容の立替えが続けて登録されたときに、二重登録を疑う期間
+const duplicateWindow = 30 * time.Second
+
+// PaymentMetrics は立替えと精算の件数や所要時間を記録する
+type PaymentMetrics interface {
+	PaymentCreated()
+	PaymentDeleted()
+	PaymentRestored()
+	SettleObserved(duration time.Duration)
+}
+
+type nopPaymentMetrics struct{}
+
+func (nopPaymentMetrics) PaymentCreated()              {}
+func (nopPaymentMetrics) PaymentDeleted()              {}
+func (nopPaymentMetrics) PaymentRestored()             {}
+func (nopPaymentMetrics) SettleObserved(time.Duration) {}
+
 type PaymentUsecase struct {
-	events   repository.EventRepository
-	payers   repository.PayerRepository
-	payments repository.PaymentRepository
+	store   repository.Store
+	metrics PaymentMetrics
 }
 
-func NewPayment(events repository.EventRepository, payers repository.PayerRepository, payments repository.PaymentRepository) *PaymentUsecase {
+// NewPayment はmetricsがnilなら何も記録しない
+func NewPayment(store repository.Store, metrics PaymentMetrics) *PaymentUsecase {
+	if metrics == nil {
+		metrics = nopPaymentMetrics{}
+	}
 	return &PaymentUsecase{
-		events,
-		payers,
-		payments,
+		store,
+		metrics,
 	}
 }
 
@@ -35,80 +62,378 @@ type SettlementInstruction struct {
 	Amount valueobject.Yen
 }
 
-func (u *PaymentUsecase) Create(eventID valueobject.EventID, payerID valueobject.PayerID, amount valueobject.Yen) (*entity.Payment, error) {
+// Create は直前に同じ人が同じ金額とメモで立替えを登録していたら、登録せずに valueobject.ErrorDuplicatePayment を返す
+func (u *PaymentUsecase) Create(ctx context.Context, eventID valueobject.EventID, actorID valueobject.PayerID, payerID valueobject.PayerID, amount valueobject.Yen, memo string, beneficiaries []valueobject.PayerID) (*entity.Payment, error) {
+	return u.create(ctx, eventID, actorID, payerID, amount, memo, beneficiaries, true)
+}
+
+// CreateConfirmed は二重登録ではないと確認された立替えを登録する
+func (u *PaymentUsecase) CreateConfirmed(ctx context.Context, eventID valueobject.EventID, actorID valueobject.PayerID, payerID valueobject.PayerID, amount valueobject.Yen, memo string, beneficiaries []valueobject.PayerID) (*entity.Payment, error) {
+	return u.create(ctx, eventID, actorID, payerID, amount, memo, beneficiaries, false)
+}
+
+func (u *PaymentUsecase) create(ctx context.Context, eventID valueobject.EventID, actorID valueobject.PayerID, payerID valueobject.PayerID, amount valueobject.Yen, memo string, beneficiaries []valueobject.PayerID, checkDuplicate bool) (*entity.Payment, error) {
 	if eventID.IsUnknown() {
 		return nil, valueobject.NewErrorNotFound("eventID is unknown", nil)
 	}
-	event := &entity.Event{
-		ID: eventID,
-	}
-	if err := u.events.CreateIfNotExists(event); err != nil {
-		return nil, fmt.Errorf("failed to create event: %w", err)
-	}
-
 	if payerID.IsUnknown() {
 		return nil, valueobject.NewErrorNotFound("payerID is unknown", nil)
 	}
-	payer := &entity.Payer{
-		ID:      payerID,
-		EventID: eventID,
-	}
-	if err := u.payers.CreateIfNotExists(payer); err != nil {
-		return nil, fmt.Errorf("failed to create payer: %w", err)
+	for _, beneficiaryID := range beneficiaries {
+		if beneficiaryID.IsUnknown() {
+			return nil, valueobject.NewErrorNotFound("beneficiaryID is unknown", nil)
+		}
 	}
 
 	payment := &entity.Payment{
-		ID:      valueobject.NewPaymentID(),
-		EventID: eventID,
-		PayerID: payerID,
-		Amount:  amount,
+		ID:            valueobject.NewPaymentID(),
+		EventID:       eventID,
+		PayerID:       payerID,
+		Amount:        amount,
+		Memo:          memo,
+		Beneficiaries: beneficiaries,
 	}
-	if err := u.payments.Create(payment); err != nil {
-		return nil, fmt.Errorf("failed to create payment: %w", err)
+	err := u.store.Transaction(ctx, func(store repository.Store) error {
+		event := &entity.Event{
+			ID:          eventID,
+			OrganizerID: actorID,
+		}
+		if err := store.Events().CreateIfNotExists(ctx, event); err != nil {
+			return fmt.Errorf("failed to create event: %w", err)
+		}
+
+		payer := &entity.Payer{
+			ID:      payerID,
+			EventID: eventID,
+		}
+		if err := store.Payers().CreateIfNotExists(ctx, payer); err != nil {
+			return fmt.Errorf("failed to create payer: %w", err)
+		}
+
+		for _, beneficiaryID := range beneficiaries {
+			// 対象者を参加者に加えると他の立替えまで割り勘することになるので、参加済みの人だけを受け付ける
+			_, err := store.Payers().FindByID(ctx, eventID, beneficiaryID)
+			if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
+				return valueobject.NewErrorInvalid("beneficiary has not joined the event: "+beneficiaryID.String(), err)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to find beneficiary: %w", err)
+			}
+		}
+
+		if checkDuplicate {
+			// 通信が不安定なクライアントはコマンドを二重に送ることがある
+			similar, err := store.Payments().FindLastSimilar(ctx, payment, time.Now().Add(-duplicateWindow))
+			if err == nil {
+				return valueobject.NewErrorDuplicatePayment("similar payment was created just before: "+similar.ID.String(), nil)
+			}
+			if e := new(valueobject.ErrorNotFound); !errors.As(err, &e) {
+				return fmt.Errorf("failed to find similar payment: %w", err)
+			}
+		}
+		if err := store.Payments().Create(ctx, payment); err != nil {
+			return fmt.Errorf("failed to create payment: %w", err)
+		}
+
+		return appendAuditLog(ctx, store, eventID, actorID, payerID, valueobject.AuditActionPaymentCreated, "", describePayment(payment))
+	})
+	if err != nil {
+		return nil, err
 	}
+	u.metrics.PaymentCreated()
+	slog.InfoContext(ctx, "payment created", slog.String("event_id", eventID.String()), slog.String("payment_id", payment.ID.String()), slog.Int("amount", int(amount)))
 
 	return payment, nil
 }
 
-func (u *PaymentUsecase) Delete(paymentID valueobject.PaymentID) error {
-	if err := u.payments.Delete(paymentID); err != nil {
-		return fmt.Errorf("failed to delete payment: %w", err)
+func (u *PaymentUsecase) Find(ctx context.Context, paymentID valueobject.PaymentID) (*entity.Payment, error) {
+	payment, err := u.store.Payments().FindByID(ctx, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payment: %w", err)
 	}
-	return nil
+	return payment, nil
 }
 
-func (u *PaymentUsecase) Join(eventID valueobject.EventID, payerID valueobject.PayerID, weight valueobject.Percent) (*entity.Payer, error) {
-	if eventID.IsUnknown() {
-		return nil, valueobject.NewErrorNotFound("eventID is unknown", nil)
+func (u *PaymentUsecase) Update(ctx context.Context, paymentID valueobject.PaymentID, editorID valueobject.PayerID, amount valueobject.Yen, memo string) (*entity.Payment, error) {
+	var payment *entity.Payment
+	err := u.store.Transaction(ctx, func(store repository.Store) error {
+		var err error
+		payment, err = store.Payments().FindByID(ctx, paymentID)
+		if err != nil {
+			return fmt.Errorf("failed to find payment: %w", err)
+		}
+		event, err := store.Events().FindByID(ctx, payment.EventID)
+		if err != nil {
+			return fmt.Errorf("failed to find event: %w", err)
+		}
+		if editorID != payment.PayerID && editorID != event.OrganizerID {
+			return valueobject.NewErrorForbidden("only the payer or the organizer can edit the payment", nil)
+		}
+
+		before := describePayment(payment)
+		payment.Amount = amount
+		payment.Memo = memo
+		if err := store.Payments().Update(ctx, payment); err != nil {
+			return fmt.Errorf("failed to update payment: %w", err)
+		}
+
+		return appendAuditLog(ctx, store, payment.EventID, editorID, payment.PayerID, valueobject.AuditActionPaymentUpdated, before, describePayment(payment))
+	})
+	if err != nil {
+		return nil, err
 	}
-	event := &entity.Event{
-		ID: eventID,
+	slog.InfoContext(ctx, "payment updated", slog.String("payment_id", paymentID.String()), slog.Int("amount", int(amount)))
+
+	return payment, nil
+}
+
+func (u *PaymentUsecase) Delete(ctx context.Context, paymentID valueobject.PaymentID, actorID valueobject.PayerID) error {
+	_, err := u.delete(ctx, paymentID, actorID, false)
+	return err
+}
+
+// DeleteByEditor は立替えた本人か幹事だけが削除できる。ボタンのように誰でも操作できるところから削除するときに使う
+func (u *PaymentUsecase) DeleteByEditor(ctx context.Context, paymentID valueobject.PaymentID, editorID valueobject.PayerID) (*entity.Payment, error) {
+	return u.delete(ctx, paymentID, editorID, true)
+}
+
+func (u *PaymentUsecase) delete(ctx context.Context, paymentID valueobject.PaymentID, actorID valueobject.PayerID, checkEditor bool) (*entity.Payment, error) {
+	var payment *entity.Payment
+	err := u.store.Transaction(ctx, func(store repository.Store) error {
+		var err error
+		payment, err = store.Payments().FindByID(ctx, paymentID)
+		if err != nil {
+			return fmt.Errorf("failed to find payment: %w", err)
+		}
+		if checkEditor {
+			event, err := store.Events().FindByID(ctx, payment.EventID)
+			if err != nil {
+				return fmt.Errorf("failed to find event: %w", err)
+			}
+			if actorID != payment.PayerID && actorID != event.OrganizerID {
+				return valueobject.NewErrorForbidden("only the payer or the organizer can delete the payment", nil)
+			}
+		}
+		if err := store.Payments().Delete(ctx, paymentID); err != nil {
+			return fmt.Errorf("failed to delete payment: %w", err)
+		}
+
+		return appendAuditLog(ctx, store, payment.EventID, actorID, payment.PayerID, valueobject.AuditActionPaymentDeleted, describePayment(payment), "")
+	})
+	if err != nil {
+		return nil, err
 	}
-	if err := u.events.CreateIfNotExists(event); err != nil {
-		return nil, fmt.Errorf("failed to create event: %w", err)
+	u.metrics.PaymentDeleted()
+	slog.InfoContext(ctx, "payment deleted", slog.String("payment_id", paymentID.String()))
+	return payment, nil
+}
+
+func (u *PaymentUsecase) Undo(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID) (*entity.Payment, error) {
+	var payment *entity.Payment
+	err := u.store.Transaction(ctx, func(store repository.Store) error {
+		var err error
+		payment, err = store.Payments().FindLastDeleted(ctx, eventID, payerID, time.Now().Add(-undoWindow))
+		if err != nil {
+			return fmt.Errorf("failed to find deleted payment: %w", err)
+		}
+		if err := store.Payments().Restore(ctx, payment.ID); err != nil {
+			return fmt.Errorf("failed to restore payment: %w", err)
+		}
+
+		return appendAuditLog(ctx, store, eventID, payerID, payerID, valueobject.AuditActionPaymentRestored, "", describePayment(payment))
+	})
+	if err != nil {
+		return nil, err
 	}
+	u.metrics.PaymentRestored()
+	slog.InfoContext(ctx, "payment restored", slog.String("event_id", eventID.String()), slog.String("payment_id", payment.ID.String()))
 
+	return payment, nil
+}
+
+func (u *PaymentUsecase) Join(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID, weight valueobject.Percent) (*entity.Payer, error) {
+	if eventID.IsUnknown() {
+		return nil, valueobject.NewErrorNotFound("eventID is unknown", nil)
+	}
 	if payerID.IsUnknown() {
 		return nil, valueobject.NewErrorNotFound("payerID is unknown", nil)
 	}
+
 	payer := &entity.Payer{
 		ID:      payerID,
 		EventID: eventID,
 		Weight:  weight,
 	}
-	if err := u.payers.Create(payer); err != nil {
-		return nil, fmt.Errorf("failed to create payer: %w", err)
+	err := u.store.Transaction(ctx, func(store repository.Store) error {
+		event := &entity.Event{
+			ID:          eventID,
+			OrganizerID: payerID,
+		}
+		if err := store.Events().CreateIfNotExists(ctx, event); err != nil {
+			return fmt.Errorf("failed to create event: %w", err)
+		}
+
+		if err := store.Payers().Create(ctx, payer); err != nil {
+			return fmt.Errorf("failed to create payer: %w", err)
+		}
+
+		return appendAuditLog(ctx, store, eventID, payerID, payerID, valueobject.AuditActionPayerJoined, "", describePayer(payer))
+	})
+	if err != nil {
+		return nil, err
 	}
+	slog.InfoContext(ctx, "payer joined", slog.String("event_id", eventID.String()), slog.String("payer_id", payerID.String()))
 
 	return payer, nil
 }
 
-func (u *PaymentUsecase) Settle(eventID valueobject.EventID) (*Settlement, error) {
-	payments, err := u.payments.FindByEventID(eventID)
+func (u *PaymentUsecase) Leave(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID, actorID valueobject.PayerID) error {
+	err := u.store.Transaction(ctx, func(store repository.Store) error {
+		payer, err := store.Payers().FindByID(ctx, eventID, payerID)
+		if err != nil {
+			return fmt.Errorf("failed to find payer: %w", err)
+		}
+		if err := store.Payers().Delete(ctx, eventID, payerID); err != nil {
+			return fmt.Errorf("failed to delete payer: %w", err)
+		}
+
+		return appendAuditLog(ctx, store, eventID, actorID, payerID, valueobject.AuditActionPayerLeft, describePayer(payer), "")
+	})
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "payer left", slog.String("event_id", eventID.String()), slog.String("payer_id", payerID.String()))
+	return nil
+}
+
+func (u *PaymentUsecase) ChangeWeight(ctx context.Context, eventID valueobject.EventID, payerID valueobject.PayerID, weight valueobject.Percent) (*entity.Payer, error) {
+	var payer *entity.Payer
+	err := u.store.Transaction(ctx, func(store repository.Store) error {
+		var err error
+		payer, err = store.Payers().FindByID(ctx, eventID, payerID)
+		if err != nil {
+			return fmt.Errorf("failed to find payer: %w", err)
+		}
+
+		before := describePayer(payer)
+		payer.Weight = weight
+		if err := store.Payers().Update(ctx, payer); err != nil {
+			return fmt.Errorf("failed to update payer: %w", err)
+		}
+
+		return appendAuditLog(ctx, store, eventID, payerID, payerID, valueobject.AuditActionPayerWeightChanged, before, describePayer(payer))
+	})
 	if err != nil {
 		return nil, err
 	}
-	payers, err := u.payers.FindByEventID(eventID)
+	slog.InfoContext(ctx, "payer weight changed", slog.String("event_id", eventID.String()), slog.String("payer_id", payerID.String()))
+	return payer, nil
+}
+
+// Payments は割り勘の、削除されていない立替えを登録順に返す
+func (u *PaymentUsecase) Payments(ctx context.Context, eventID valueobject.EventID) ([]*entity.Payment, error) {
+	payments, err := u.store.Payments().FindByEventID(ctx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments: %w", err)
+	}
+	return payments, nil
+}
+
+func (u *PaymentUsecase) Payers(ctx context.Context, eventID valueobject.EventID) ([]*entity.Payer, error) {
+	payers, err := u.store.Payers().FindByEventID(ctx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payers: %w", err)
+	}
+	return payers, nil
+}
+
+func (u *PaymentUsecase) History(ctx context.Context, eventID valueobject.EventID) ([]*entity.AuditLog, error) {
+	logs, err := u.store.AuditLogs().FindByEventID(ctx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find audit logs: %w", err)
+	}
+	return logs, nil
+}
+
+// MoveEvent はチャンネルの共有が始まったときなどに、fromの割り勘をtoのIDに付け替えて、付け替えたかどうかを返す
+// toの割り勘がすでにあるときは、どちらの記録も混ぜないように付け替えない
+func (u *PaymentUsecase) MoveEvent(ctx context.Context, from valueobject.EventID, to valueobject.EventID) (bool, error) {
+	moved := false
+	err := u.store.Transaction(ctx, func(store repository.Store) error {
+		_, err := store.Events().FindByID(ctx, to)
+		if err == nil {
+			return nil
+		}
+		if e := new(valueobject.ErrorNotFound); !errors.As(err, &e) {
+			return fmt.Errorf("failed to find event: %w", err)
+		}
+		_, err = store.Events().FindByID(ctx, from)
+		if e := new(valueobject.ErrorNotFound); errors.As(err, &e) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find event: %w", err)
+		}
+		if err := store.Events().Move(ctx, from, to); err != nil {
+			return fmt.Errorf("failed to move event: %w", err)
+		}
+		moved = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if moved {
+		slog.InfoContext(ctx, "event moved", slog.String("from", from.String()), slog.String("to", to.String()))
+	}
+	return moved, nil
+}
+
+// RenamePayer は所属する組織を区別する前のIDで記録されたSlackの利用者を、Slackのすべての割り勘でtoのIDに付け替える
+// Discord・LINEの割り勘は参加者のIDに最初から名前空間を付けているので、付け替えない
+func (u *PaymentUsecase) RenamePayer(ctx context.Context, from valueobject.PayerID, to valueobject.PayerID) error {
+	if err := u.store.Payers().Rename(ctx, from, to); err != nil {
+		return fmt.Errorf("failed to rename payer: %w", err)
+	}
+	return nil
+}
+
+func appendAuditLog(ctx context.Context, store repository.Store, eventID valueobject.EventID, actorID valueobject.PayerID, payerID valueobject.PayerID, action valueobject.AuditAction, before string, after string) error {
+	log := &entity.AuditLog{
+		EventID:   eventID,
+		ActorID:   actorID,
+		PayerID:   payerID,
+		Action:    action,
+		Before:    before,
+		After:     after,
+		CreatedAt: time.Now(),
+	}
+	if err := store.AuditLogs().Append(ctx, log); err != nil {
+		return fmt.Errorf("failed to append audit log: %w", err)
+	}
+	return nil
+}
+
+func describePayment(payment *entity.Payment) string {
+	if payment.Memo == "" {
+		return payment.Amount.String()
+	}
+	return fmt.Sprintf("%s（%s）", payment.Amount.String(), payment.Memo)
+}
+
+func describePayer(payer *entity.Payer) string {
+	return fmt.Sprintf("%d%%", payer.Weight.Int())
+}
+
+func (u *PaymentUsecase) Settle(ctx context.Context, eventID valueobject.EventID) (*Settlement, error) {
+	start := time.Now()
+	defer func() { u.metrics.SettleObserved(time.Since(start)) }()
+
+	payments, err := u.store.Payments().FindByEventID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	payers, err := u.store.Payers().FindByEventID(ctx, eventID)
 	if err != nil {
 		return nil, err
 	}
@@ -136,7 +461,13 @@ func (u *PaymentUsecase) Settle(eventID valueobject.EventID) (*Settlement, error
 		}
 		numeratorSum += numerator
 	}
-	if numeratorSum.Int64()%int64(denominator.Int()) == 0 {
+	hasBeneficiaries := false
+	for _, payment := range payments {
+		if len(payment.Beneficiaries) > 0 {
+			hasBeneficiaries = true
+		}
+	}
+	if !hasBeneficiaries && numeratorSum.Int64()%int64(denominator.Int()) == 0 {
 		// 綺麗に割り切れる場合
 		reimbursement, err := numeratorSum.CeilDivideBy(denominator.Int())
 		if err != nil {
@@ -156,11 +487,21 @@ func (u *PaymentUsecase) Settle(eventID valueobject.EventID) (*Settlement, error
 			}
 		}
 	} else {
-		// 割り切れない場合は、立替者優先で端数を計算する
+		// 割り切れない場合や対象者が指定されている場合は、立替者優先で端数を計算する
 		for _, payment := range payments {
 			settlement.Total += payment.Amount
 			settlement.AmountsAdvanced[payment.PayerID] += payment.Amount
 
+			paymentDenominator := valueobject.Percent(0)
+			for _, payer := range payers {
+				if payment.IsSharedBy(payer.ID) {
+					paymentDenominator += payer.Weight
+				}
+			}
+			if paymentDenominator <= 0 {
+				return nil, fmt.Errorf("no payers share paymentID: %s", payment.ID)
+			}
+
 			paymentOwnerIndex := 0
 			othersDebt := valueobject.Yen(0)
 			for i, payer := range payers {
@@ -168,11 +509,14 @@ func (u *PaymentUsecase) Settle(eventID valueobject.EventID) (*Settlement, error
 					paymentOwnerIndex = i
 					continue
 				}
+				if !payment.IsSharedBy(payer.ID) {
+					continue
+				}
 				numerator, err := payment.Amount.MultiplyBy(payer.Weight.Int())
 				if err != nil {
 					return nil, fmt.Errorf("failed to multiply payment amount: %w", err)
 				}
-				debt, err := numerator.CeilDivideBy(denominator.Int())
+				debt, err := numerator.CeilDivideBy(paymentDenominator.Int())
 				if err != nil {
 					return nil, fmt.Errorf("failed to divide payment amount: %w", err)
 				}
@@ -4,47 +4,27 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/kakudo415/warikan-bot/internal/domain/entity"
 	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository/memory"
 )
 
-type MockEventRepository struct{}
+// newTestStore は参加者と支払いを登録済みのインメモリストアを返す
+func newTestStore(t *testing.T, event *entity.Event, payers []*entity.Payer, payments []*entity.Payment) *memory.Store {
+	t.Helper()
 
-func (m *MockEventRepository) CreateIfNotExists(event *entity.Event) error {
-	return nil
-}
-
-type MockPayerRepository struct {
-	Payers []*entity.Payer
-}
-
-func (m *MockPayerRepository) Create(payer *entity.Payer) error {
-	return nil
-}
-
-func (m *MockPayerRepository) CreateIfNotExists(payer *entity.Payer) error {
-	return nil
-}
-
-func (m *MockPayerRepository) FindByEventID(eventID valueobject.EventID) ([]*entity.Payer, error) {
-	return m.Payers, nil
-}
-
-type MockPaymentRepository struct {
-	Payments []*entity.Payment
-}
-
-func (m *MockPaymentRepository) Create(payment *entity.Payment) error {
-	return nil
-}
-
-func (m *MockPaymentRepository) Delete(paymentID valueobject.PaymentID) error {
-	return nil
-}
-
-func (m *MockPaymentRepository) FindByEventID(eventID valueobject.EventID) ([]*entity.Payment, error) {
-	return m.Payments, nil
+	ctx := t.Context()
+	store := memory.NewStore()
+	require.NoError(t, store.Events().CreateIfNotExists(ctx, event))
+	for _, payer := range payers {
+		require.NoError(t, store.Payers().Create(ctx, payer))
+	}
+	for _, payment := range payments {
+		require.NoError(t, store.Payments().Create(ctx, payment))
+	}
+	return store
 }
 
 func MustYen(amount int) valueobject.Yen {
@@ -162,18 +142,63 @@ func TestSettle(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:    "OK: 3 payers, 2 payments (1 shared by 2 payers)",
+			eventID: valueobject.NewEventID("event4"),
+			payers: []*entity.Payer{
+				{ID: valueobject.NewPayerID("payer1"), EventID: valueobject.NewEventID("event4"), Weight: MustPercent(100)},
+				{ID: valueobject.NewPayerID("payer2"), EventID: valueobject.NewEventID("event4"), Weight: MustPercent(100)},
+				{ID: valueobject.NewPayerID("payer3"), EventID: valueobject.NewEventID("event4"), Weight: MustPercent(100)},
+			},
+			payments: []*entity.Payment{
+				{ID: valueobject.NewPaymentID(), EventID: valueobject.NewEventID("event4"), PayerID: valueobject.NewPayerID("payer1"), Amount: MustYen(3000)},
+				{
+					ID: valueobject.NewPaymentID(), EventID: valueobject.NewEventID("event4"), PayerID: valueobject.NewPayerID("payer2"), Amount: MustYen(1000),
+					Beneficiaries: []valueobject.PayerID{valueobject.NewPayerID("payer2"), valueobject.NewPayerID("payer3")},
+				},
+			},
+			expectedSettlement: &Settlement{
+				Total: MustYen(4000),
+				Instructions: []*SettlementInstruction{
+					{From: valueobject.NewPayerID("payer3"), To: valueobject.NewPayerID("payer1"), Amount: MustYen(1500)},
+					{From: valueobject.NewPayerID("payer2"), To: valueobject.NewPayerID("payer1"), Amount: MustYen(500)},
+				},
+			},
+		},
+		{
+			// 参加していない対象者は、他の立替えの割り勘に加わらない
+			name:    "OK: 3 payers, 2 payments (1 shared with a non-member)",
+			eventID: valueobject.NewEventID("event5"),
+			payers: []*entity.Payer{
+				{ID: valueobject.NewPayerID("payer1"), EventID: valueobject.NewEventID("event5"), Weight: MustPercent(100)},
+				{ID: valueobject.NewPayerID("payer2"), EventID: valueobject.NewEventID("event5"), Weight: MustPercent(100)},
+				{ID: valueobject.NewPayerID("payer3"), EventID: valueobject.NewEventID("event5"), Weight: MustPercent(100)},
+			},
+			payments: []*entity.Payment{
+				{ID: valueobject.NewPaymentID(), EventID: valueobject.NewEventID("event5"), PayerID: valueobject.NewPayerID("payer1"), Amount: MustYen(3000)},
+				{
+					ID: valueobject.NewPaymentID(), EventID: valueobject.NewEventID("event5"), PayerID: valueobject.NewPayerID("payer2"), Amount: MustYen(1000),
+					Beneficiaries: []valueobject.PayerID{valueobject.NewPayerID("payer3"), valueobject.NewPayerID("guest")},
+				},
+			},
+			expectedSettlement: &Settlement{
+				Total: MustYen(4000),
+				Instructions: []*SettlementInstruction{
+					{From: valueobject.NewPayerID("payer3"), To: valueobject.NewPayerID("payer1"), Amount: MustYen(2000)},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 
-			eventRepo := &MockEventRepository{}
-			payerRepo := &MockPayerRepository{Payers: test.payers}
-			paymentRepo := &MockPaymentRepository{Payments: test.payments}
-			usecase := NewPayment(eventRepo, payerRepo, paymentRepo)
+			ctx := t.Context()
+			store := newTestStore(t, &entity.Event{ID: test.eventID}, test.payers, test.payments)
+			usecase := NewPayment(store, nil)
 
-			settlement, err := usecase.Settle(test.eventID)
+			settlement, err := usecase.Settle(ctx, test.eventID)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -183,3 +208,341 @@ func TestSettle(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		editorID    valueobject.PayerID
+		expectedErr bool
+	}{
+		{
+			name:     "OK: edited by the payer",
+			editorID: valueobject.NewPayerID("payer1"),
+		},
+		{
+			name:     "OK: edited by the organizer",
+			editorID: valueobject.NewPayerID("organizer"),
+		},
+		{
+			name:        "NG: edited by another payer",
+			editorID:    valueobject.NewPayerID("payer2"),
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			payment := &entity.Payment{ID: valueobject.NewPaymentID(), EventID: valueobject.NewEventID("event1"), PayerID: valueobject.NewPayerID("payer1"), Amount: MustYen(30000)}
+			event := &entity.Event{ID: payment.EventID, OrganizerID: valueobject.NewPayerID("organizer")}
+			store := newTestStore(t, event, nil, []*entity.Payment{payment})
+			usecase := NewPayment(store, nil)
+
+			updated, err := usecase.Update(ctx, payment.ID, test.editorID, MustYen(3000), "ランチ")
+			if test.expectedErr {
+				e := new(valueobject.ErrorForbidden)
+				assert.ErrorAs(t, err, &e)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assert.Equal(t, MustYen(3000), updated.Amount, "amount mismatch")
+			assert.Equal(t, "ランチ", updated.Memo, "memo mismatch")
+		})
+	}
+}
+
+func TestCreateAndSettle(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	eventID := valueobject.NewEventID("event1")
+	payer1 := valueobject.NewPayerID("payer1")
+	payer2 := valueobject.NewPayerID("payer2")
+	payer3 := valueobject.NewPayerID("payer3")
+	usecase := NewPayment(memory.NewStore(), nil)
+
+	for _, payerID := range []valueobject.PayerID{payer1, payer2, payer3} {
+		_, err := usecase.Join(ctx, eventID, payerID, MustPercent(100))
+		require.NoError(t, err)
+	}
+	_, err := usecase.Create(ctx, eventID, payer1, payer1, MustYen(3000), "ランチ", nil)
+	require.NoError(t, err)
+	mistake, err := usecase.Create(ctx, eventID, payer2, payer2, MustYen(60000), "", nil)
+	require.NoError(t, err)
+
+	// 誤った支払いを取り消してから登録し直す
+	require.NoError(t, usecase.Delete(ctx, mistake.ID, payer2))
+	restored, err := usecase.Undo(ctx, eventID, payer2)
+	require.NoError(t, err)
+	assert.Equal(t, mistake.ID, restored.ID)
+	_, err = usecase.Update(ctx, restored.ID, payer2, MustYen(600), "")
+	require.NoError(t, err)
+
+	// 参加していない人は対象者にできず、参加者も増えない
+	_, err = usecase.Create(ctx, eventID, payer1, payer1, MustYen(1000), "", []valueobject.PayerID{payer2, valueobject.NewPayerID("guest")})
+	e := new(valueobject.ErrorInvalid)
+	require.ErrorAs(t, err, &e)
+
+	settlement, err := usecase.Settle(ctx, eventID)
+	require.NoError(t, err)
+	assert.Equal(t, MustYen(3600), settlement.Total)
+	assert.Len(t, settlement.Payers, 3)
+	assert.ElementsMatch(t, []*SettlementInstruction{
+		{From: payer3, To: payer1, Amount: MustYen(1200)},
+		{From: payer2, To: payer1, Amount: MustYen(600)},
+	}, settlement.Instructions)
+
+	logs, err := usecase.History(ctx, eventID)
+	require.NoError(t, err)
+	assert.Len(t, logs, 8)
+}
+
+func TestCreateDuplicate(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	eventID := valueobject.NewEventID("event1")
+	payer1 := valueobject.NewPayerID("payer1")
+	store := memory.NewStore()
+	usecase := NewPayment(store, nil)
+
+	first, err := usecase.Create(ctx, eventID, payer1, payer1, MustYen(3000), "ランチ", nil)
+	require.NoError(t, err)
+
+	// 直前と同じ内容は二重登録を疑う
+	_, err = usecase.Create(ctx, eventID, payer1, payer1, MustYen(3000), "ランチ", nil)
+	e := new(valueobject.ErrorDuplicatePayment)
+	require.ErrorAs(t, err, &e)
+	assert.Contains(t, e.Error(), first.ID.String())
+
+	// 金額やメモが違えば別の立替え
+	_, err = usecase.Create(ctx, eventID, payer1, payer1, MustYen(3000), "ディナー", nil)
+	require.NoError(t, err)
+
+	// 確認されたものは登録する
+	_, err = usecase.CreateConfirmed(ctx, eventID, payer1, payer1, MustYen(3000), "ランチ", nil)
+	require.NoError(t, err)
+
+	payments, err := store.Payments().FindByEventID(ctx, eventID)
+	require.NoError(t, err)
+	assert.Len(t, payments, 3)
+}
+
+func TestLeave(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		payerID     valueobject.PayerID
+		actorID     valueobject.PayerID
+		expectedErr bool
+	}{
+		{
+			name:    "OK: left by the payer",
+			payerID: valueobject.NewPayerID("payer1"),
+			actorID: valueobject.NewPayerID("payer1"),
+		},
+		{
+			name:    "OK: removed by the organizer",
+			payerID: valueobject.NewPayerID("payer1"),
+			actorID: valueobject.NewPayerID("organizer"),
+		},
+		{
+			name:        "NG: payer has not joined",
+			payerID:     valueobject.NewPayerID("guest"),
+			actorID:     valueobject.NewPayerID("guest"),
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			eventID := valueobject.NewEventID("event1")
+			event := &entity.Event{ID: eventID, OrganizerID: valueobject.NewPayerID("organizer")}
+			payers := []*entity.Payer{
+				{ID: valueobject.NewPayerID("payer1"), EventID: eventID, Weight: MustPercent(150)},
+				{ID: valueobject.NewPayerID("payer2"), EventID: eventID, Weight: MustPercent(100)},
+			}
+			store := newTestStore(t, event, payers, nil)
+			usecase := NewPayment(store, nil)
+
+			err := usecase.Leave(ctx, eventID, test.payerID, test.actorID)
+			if test.expectedErr {
+				e := new(valueobject.ErrorNotFound)
+				assert.ErrorAs(t, err, &e)
+				return
+			}
+			require.NoError(t, err)
+
+			remaining, err := store.Payers().FindByEventID(ctx, eventID)
+			require.NoError(t, err)
+			require.Len(t, remaining, 1)
+			assert.Equal(t, valueobject.NewPayerID("payer2"), remaining[0].ID)
+
+			// 抜ける前の割合を履歴に残す
+			logs, err := usecase.History(ctx, eventID)
+			require.NoError(t, err)
+			require.Len(t, logs, 1)
+			assert.Equal(t, valueobject.AuditActionPayerLeft, logs[0].Action)
+			assert.Equal(t, test.actorID, logs[0].ActorID)
+			assert.Equal(t, test.payerID, logs[0].PayerID)
+			assert.Equal(t, "150%", logs[0].Before)
+			assert.Equal(t, "", logs[0].After)
+		})
+	}
+}
+
+func TestChangeWeight(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		payerID       valueobject.PayerID
+		weight        valueobject.Percent
+		expectedAfter string
+		expectedErr   bool
+	}{
+		{
+			name:          "OK: weight is raised",
+			payerID:       valueobject.NewPayerID("payer1"),
+			weight:        MustPercent(150),
+			expectedAfter: "150%",
+		},
+		{
+			name:          "OK: weight is lowered",
+			payerID:       valueobject.NewPayerID("payer1"),
+			weight:        MustPercent(50),
+			expectedAfter: "50%",
+		},
+		{
+			name:        "NG: payer has not joined",
+			payerID:     valueobject.NewPayerID("guest"),
+			weight:      MustPercent(150),
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			eventID := valueobject.NewEventID("event1")
+			event := &entity.Event{ID: eventID, OrganizerID: valueobject.NewPayerID("payer1")}
+			payers := []*entity.Payer{{ID: valueobject.NewPayerID("payer1"), EventID: eventID, Weight: MustPercent(100)}}
+			store := newTestStore(t, event, payers, nil)
+			usecase := NewPayment(store, nil)
+
+			payer, err := usecase.ChangeWeight(ctx, eventID, test.payerID, test.weight)
+			if test.expectedErr {
+				e := new(valueobject.ErrorNotFound)
+				assert.ErrorAs(t, err, &e)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.weight, payer.Weight)
+
+			stored, err := store.Payers().FindByID(ctx, eventID, test.payerID)
+			require.NoError(t, err)
+			assert.Equal(t, test.weight, stored.Weight)
+
+			logs, err := usecase.History(ctx, eventID)
+			require.NoError(t, err)
+			require.Len(t, logs, 1)
+			assert.Equal(t, valueobject.AuditActionPayerWeightChanged, logs[0].Action)
+			assert.Equal(t, "100%", logs[0].Before)
+			assert.Equal(t, test.expectedAfter, logs[0].After)
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	eventID := valueobject.NewEventID("event1")
+	payer1 := valueobject.NewPayerID("payer1")
+	payer2 := valueobject.NewPayerID("payer2")
+	tests := []struct {
+		name string
+		// 履歴を残す操作
+		operate         func(t *testing.T, usecase *PaymentUsecase)
+		expectedActions []valueobject.AuditAction
+		expectedActors  []valueobject.PayerID
+	}{
+		{
+			name:    "OK: no history",
+			operate: func(t *testing.T, usecase *PaymentUsecase) {},
+		},
+		{
+			name: "OK: join, change weight and leave",
+			operate: func(t *testing.T, usecase *PaymentUsecase) {
+				_, err := usecase.Join(t.Context(), eventID, payer1, MustPercent(100))
+				require.NoError(t, err)
+				_, err = usecase.Join(t.Context(), eventID, payer2, MustPercent(100))
+				require.NoError(t, err)
+				_, err = usecase.ChangeWeight(t.Context(), eventID, payer2, MustPercent(50))
+				require.NoError(t, err)
+				require.NoError(t, usecase.Leave(t.Context(), eventID, payer2, payer1))
+			},
+			expectedActions: []valueobject.AuditAction{
+				valueobject.AuditActionPayerJoined,
+				valueobject.AuditActionPayerJoined,
+				valueobject.AuditActionPayerWeightChanged,
+				valueobject.AuditActionPayerLeft,
+			},
+			expectedActors: []valueobject.PayerID{payer1, payer2, payer2, payer1},
+		},
+		{
+			name: "OK: create, update, delete and undo a payment",
+			operate: func(t *testing.T, usecase *PaymentUsecase) {
+				_, err := usecase.Join(t.Context(), eventID, payer1, MustPercent(100))
+				require.NoError(t, err)
+				payment, err := usecase.Create(t.Context(), eventID, payer1, payer1, MustYen(3000), "ランチ", nil)
+				require.NoError(t, err)
+				_, err = usecase.Update(t.Context(), payment.ID, payer1, MustYen(2000), "ランチ")
+				require.NoError(t, err)
+				require.NoError(t, usecase.Delete(t.Context(), payment.ID, payer1))
+				_, err = usecase.Undo(t.Context(), eventID, payer1)
+				require.NoError(t, err)
+			},
+			expectedActions: []valueobject.AuditAction{
+				valueobject.AuditActionPayerJoined,
+				valueobject.AuditActionPaymentCreated,
+				valueobject.AuditActionPaymentUpdated,
+				valueobject.AuditActionPaymentDeleted,
+				valueobject.AuditActionPaymentRestored,
+			},
+			expectedActors: []valueobject.PayerID{payer1, payer1, payer1, payer1, payer1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			usecase := NewPayment(memory.NewStore(), nil)
+			test.operate(t, usecase)
+
+			logs, err := usecase.History(t.Context(), eventID)
+			require.NoError(t, err)
+			// 古い順に並ぶ
+			var actions []valueobject.AuditAction
+			var actors []valueobject.PayerID
+			for _, log := range logs {
+				actions = append(actions, log.Action)
+				actors = append(actors, log.ActorID)
+			}
+			assert.Equal(t, test.expectedActions, actions)
+			assert.Equal(t, test.expectedActors, actors)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/kakudo415/warikan-bot/internal/domain/service"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
+)
+
+type ReceiptUsecase struct {
+	reader service.ReceiptReader
+}
+
+func NewReceipt(reader service.ReceiptReader) *ReceiptUsecase {
+	return &ReceiptUsecase{
+		reader,
+	}
+}
+
+func (u *ReceiptUsecase) ReadTotal(ctx context.Context, image io.Reader) (valueobject.Yen, error) {
+	total, err := u.reader.ReadTotal(ctx, image)
+	if err != nil {
+		return valueobject.Yen(0), fmt.Errorf("failed to read receipt: %w", err)
+	}
+	return total, nil
+}
@@ -1,37 +1,216 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/slack-go/slack"
+
+	"github.com/kakudo415/warikan-bot/internal/config"
+	"github.com/kakudo415/warikan-bot/internal/domain/valueobject"
 	"github.com/kakudo415/warikan-bot/internal/infrastructure/handler"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/logging"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/metrics"
+	"github.com/kakudo415/warikan-bot/internal/infrastructure/receipt"
 	"github.com/kakudo415/warikan-bot/internal/infrastructure/repository"
 	"github.com/kakudo415/warikan-bot/internal/usecase"
 )
 
 func main() {
-	eventRepository, err := repository.NewEventRepository("database.db")
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
 	if err != nil {
-		log.Fatalf("failed to create event repository: %v", err)
+		fatal("invalid configuration", err)
 	}
-	payerRepository, err := repository.NewPayerRepository("database.db")
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level)
 	if err != nil {
-		log.Fatalf("failed to create payer repository: %v", err)
+		fatal("invalid log configuration", err)
 	}
-	paymentRepository, err := repository.NewPaymentRepository("database.db")
+	slog.SetDefault(logger)
+
+	store, err := repository.Open(cfg.Database.URL)
 	if err != nil {
-		log.Fatalf("failed to create payment repository: %v", err)
+		fatal("failed to open database", err)
 	}
-	paymentUsecase := usecase.NewPayment(eventRepository, payerRepository, paymentRepository)
-	slackCommandHandler := handler.NewSlackCommandHandler(os.Getenv("SLACK_BOT_TOKEN"), os.Getenv("SLACK_SIGNING_SECRET"), paymentUsecase)
-	slackEventHandler := handler.NewSlackEventHandler(os.Getenv("SLACK_BOT_TOKEN"), os.Getenv("SLACK_SIGNING_SECRET"), paymentUsecase)
+	appMetrics := metrics.New()
+	slackHTTPClient := &http.Client{Transport: appMetrics.SlackTransport(http.DefaultTransport)}
+	receiptReader := receipt.NewReader(receipt.NewTesseract(cfg.Receipt.TesseractCommand, cfg.Receipt.TesseractLanguages))
+	instrumentedStore := metrics.NewStore(store, appMetrics)
+	paymentUsecase := usecase.NewPayment(instrumentedStore, appMetrics)
+	receiptUsecase := usecase.NewReceipt(receiptReader)
+	deliveryUsecase := usecase.NewDelivery(instrumentedStore)
+	installationUsecase := usecase.NewInstallation(instrumentedStore)
+	apiTokenUsecase := usecase.NewAPIToken(instrumentedStore)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cleanupDone := make(chan struct{})
+	go func() {
+		defer close(cleanupDone)
+		cleanUpDeliveries(ctx, deliveryUsecase)
+	}()
+
+	// OAuthで追加されたワークスペースごとのトークンか、設定した1つのトークンでSlack APIを呼び出す
+	var slackClients handler.SlackClients
+	if cfg.Slack.Distributed() {
+		slackClients = handler.NewInstalledSlackClients(installationUsecase, slack.OptionHTTPClient(slackHTTPClient))
+	} else {
+		slackClient := slack.New(cfg.Slack.BotToken, slack.OptionHTTPClient(slackHTTPClient))
+		slackClients = handler.NewStaticSlackClients(slackClient)
+		if err := assignLegacyEvents(ctx, slackClient, installationUsecase); err != nil {
+			// 次に起動したときにやり直せるので、起動は続ける
+			slog.Error("failed to assign legacy events", slog.Any("error", err))
+		}
+	}
+
+	botProfile := handler.BotProfile{Username: cfg.Bot.Username, IconEmoji: cfg.Bot.IconEmoji}
+	commandWorkers := handler.NewWorkerPool(cfg.Worker.Count, cfg.Worker.QueueSize)
+	slackChannels := handler.NewSlackChannels(paymentUsecase)
+	slackUsers := handler.NewSlackUsers(paymentUsecase)
+	slackCommandHandler := handler.NewSlackCommandHandler(slackClients, slackChannels, slackUsers, paymentUsecase, deliveryUsecase, commandWorkers, appMetrics, botProfile)
+	slackEventHandler := handler.NewSlackEventHandler(slackClients, slackChannels, slackUsers, paymentUsecase, receiptUsecase, deliveryUsecase, installationUsecase, botProfile)
+	slackInteractionHandler := handler.NewSlackInteractionHandler(slackClients, slackChannels, slackUsers, paymentUsecase, deliveryUsecase, botProfile)
+	healthHandler := handler.NewHealthHandler(store)
 
 	mux := http.NewServeMux()
-	mux.Handle("/slack/command", slackCommandHandler)
-	mux.Handle("/slack/event", slackEventHandler)
-	log.Println("Starting server on 0.0.0.0:5272")
-	if err := http.ListenAndServe("0.0.0.0:5272", mux); err != nil { // U+5272 = 割
-		log.Fatalf("server failed to start: %v", err)
+	mux.HandleFunc("/healthz", healthHandler.ServeLiveness)
+	mux.HandleFunc("/readyz", healthHandler.ServeReadiness)
+	mux.Handle("/metrics", appMetrics.Handler())
+	// APIは割り勘ごとに発行したトークンで認証するので、トークンを発行しなければ何も操作できない
+	mux.Handle("/api/", logging.Middleware(handler.NewAPIHandler(paymentUsecase, apiTokenUsecase)))
+	if cfg.Slack.Distributed() {
+		oauthHandler := handler.NewSlackOAuthHandler(cfg.Slack.ClientID, cfg.Slack.ClientSecret, cfg.Slack.RedirectURL, installationUsecase, slackHTTPClient)
+		mux.Handle("/slack/install", logging.Middleware(http.HandlerFunc(oauthHandler.ServeInstall)))
+		mux.Handle("/slack/oauth/callback", logging.Middleware(http.HandlerFunc(oauthHandler.ServeCallback)))
+	}
+
+	// DiscordとLINEはSlackの受け方にかかわらず、HTTPでリクエストを受け取る
+	if cfg.Discord.Enabled() {
+		// 設定の検証で形式は確かめてある
+		publicKey, _ := hex.DecodeString(cfg.Discord.PublicKey)
+		discordHandler := handler.NewDiscordInteractionHandler(ed25519.PublicKey(publicKey), paymentUsecase, appMetrics)
+		mux.Handle("/discord/interactions", logging.Middleware(discordHandler))
+		if cfg.Discord.BotToken != "" {
+			if err := registerDiscordCommands(ctx, cfg.Discord.ApplicationID, cfg.Discord.BotToken); err != nil {
+				// コマンドは登録済みのものがそのまま使えるので、起動は続ける
+				slog.Error("failed to register discord commands", slog.Any("error", err))
+			}
+		}
+	}
+
+	if cfg.LINE.Enabled() {
+		lineClient := handler.NewLINEClient(http.DefaultClient, cfg.LINE.ChannelAccessToken)
+		lineHandler := handler.NewLINEWebhookHandler(cfg.LINE.ChannelSecret, lineClient, paymentUsecase, deliveryUsecase, commandWorkers, appMetrics)
+		mux.Handle("/line/webhook", logging.Middleware(lineHandler))
+	}
+
+	// Socket Mode のときは公開URLを使わずにSlackにつなぎ、HTTPでは死活確認だけに答える
+	runnerDone := make(chan error, 1)
+	if cfg.Slack.Mode == config.SlackModeSocket {
+		// Socket Mode の接続にはアプリレベルトークンだけを使う
+		socketClient := slack.New(cfg.Slack.BotToken, slack.OptionAppLevelToken(cfg.Slack.AppToken), slack.OptionHTTPClient(slackHTTPClient))
+		runner := handler.NewSlackSocketModeRunner(socketClient, commandWorkers, slackCommandHandler, slackEventHandler, slackInteractionHandler)
+		slog.Info("starting socket mode runner")
+		go func() {
+			runnerDone <- runner.Run(ctx)
+			// Slackとの接続が切れたまま動き続けないように、全体を終了させる
+			stop()
+		}()
+	} else {
+		verifier := handler.NewSlackVerifier(cfg.Slack.SigningSecret, appMetrics)
+		mux.Handle("/slack/command", logging.Middleware(verifier.Middleware(slackCommandHandler)))
+		mux.Handle("/slack/event", logging.Middleware(verifier.Middleware(slackEventHandler)))
+		mux.Handle("/slack/interaction", logging.Middleware(verifier.Middleware(slackInteractionHandler)))
+		close(runnerDone)
+	}
+
+	server := &http.Server{
+		Addr:    cfg.Server.Addr,
+		Handler: mux,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("starting server", slog.String("addr", cfg.Server.Addr))
+		serverErr <- server.ListenAndServe()
+	}()
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	case err := <-serverErr:
+		slog.Error("server stopped", slog.Any("error", err))
+		exitCode = 1
 	}
+	stop()
+	healthHandler.ShutDown()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	// 新しいリクエストの受け付けをやめてから、処理中のコマンドを待ち、最後にデータベースを閉じる
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to shut down server", slog.Any("error", err))
+	}
+	if err := <-runnerDone; err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("socket mode runner failed", slog.Any("error", err))
+		exitCode = 1
+	}
+	<-cleanupDone
+	if err := commandWorkers.Close(shutdownCtx); err != nil {
+		// 処理中のコマンドがデータベースを使っているかもしれないので、閉じずに終了する
+		slog.Error("failed to drain commands", slog.Any("error", err))
+		os.Exit(1)
+	}
+	if err := store.Close(); err != nil {
+		slog.Error("failed to close database", slog.Any("error", err))
+	}
+	os.Exit(exitCode)
+}
+
+// assignLegacyEvents は1つのワークスペースだけで動かしていたときの割り勘を、ボットトークンのワークスペースのものとして引き継ぐ
+func assignLegacyEvents(ctx context.Context, client *slack.Client, installationUsecase *usecase.InstallationUsecase) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	auth, err := client.AuthTestContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to identify workspace: %w", err)
+	}
+	return installationUsecase.AssignLegacyEvents(ctx, valueobject.NewEnterpriseTeamID(auth.EnterpriseID, auth.TeamID))
+}
+
+// cleanUpDeliveries は終了するまで、再送されることのなくなった記録を定期的に削除する
+func cleanUpDeliveries(ctx context.Context, deliveryUsecase *usecase.DeliveryUsecase) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := deliveryUsecase.DeleteExpired(ctx); err != nil {
+				slog.ErrorContext(ctx, "failed to delete expired deliveries", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// registerDiscordCommands は /warikan コマンドをDiscordに登録する
+func registerDiscordCommands(ctx context.Context, applicationID string, botToken string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return handler.RegisterDiscordCommands(ctx, http.DefaultClient, applicationID, botToken)
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }